@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// envFlags maps the flags that default to an environment variable to that variable.
+// A set variable wins over the config file, just as it wins over the built-in default.
+var envFlags = map[string]string{
+	"tmdb-key":       "TMDB_API_KEY",
+	"admin-password": "MOVIE_JOURNAL_ADMIN_PASSWORD",
+}
+
+// loadConfigFile sets cmd's flags from a YAML file mapping flag names to values, e.g.
+// "port: 9090" or "trusted-proxies: [10.0.0.0/8]". Flags given on the command line and
+// flags whose environment variable is set keep their values. Values are never logged,
+// since the file may hold secrets such as the TMDB key.
+func loadConfigFile(cmd *cobra.Command, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	var settings map[string]any
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+
+	flags := cmd.Flags()
+	for name, value := range settings {
+		flag := flags.Lookup(name)
+		if flag == nil || name == "config" {
+			return fmt.Errorf("config file %s: unknown setting %q", path, name)
+		}
+		if flag.Changed {
+			continue
+		}
+		if env, ok := envFlags[name]; ok && os.Getenv(env) != "" {
+			continue
+		}
+		if err := flags.Set(name, configValue(value)); err != nil {
+			return fmt.Errorf("config file %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// configValue formats a YAML value the way it would be written on the command line.
+// Lists become comma-separated.
+func configValue(value any) string {
+	list, ok := value.([]any)
+	if !ok {
+		return fmt.Sprint(value)
+	}
+	items := make([]string, len(list))
+	for i, item := range list {
+		items[i] = fmt.Sprint(item)
+	}
+	return strings.Join(items, ",")
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// newConfigTestCmd returns a command with a few of serve's flags, defined the same way:
+// the TMDB key defaults to its environment variable, read when the flag is defined.
+func newConfigTestCmd() (cmd *cobra.Command, port *int, key *string, proxies *[]string) {
+	cmd = &cobra.Command{Use: "serve"}
+	cmd.Flags().String("config", "", "Config file")
+	port = cmd.Flags().Int("port", 8080, "Port")
+	key = cmd.Flags().String("tmdb-key", os.Getenv("TMDB_API_KEY"), "TMDB key")
+	proxies = cmd.Flags().StringSlice("trusted-proxies", nil, "Trusted proxies")
+	return cmd, port, key, proxies
+}
+
+// writeConfigFile writes a YAML config file with contents to a temporary directory.
+func writeConfigFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "movie-journal.yaml")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("writing config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigFilePrecedence(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		env      string
+		wantKey  string
+		args     []string
+		wantPort int
+	}{
+		{name: "default", wantPort: 8080},
+		{name: "file over default", file: "port: 9090\ntmdb-key: from-file\n", wantPort: 9090, wantKey: "from-file"},
+		{name: "env over file", file: "tmdb-key: from-file\n", env: "from-env", wantPort: 8080, wantKey: "from-env"},
+		{name: "env over default", env: "from-env", wantPort: 8080, wantKey: "from-env"},
+		{
+			name: "flag over env and file", file: "port: 9090\ntmdb-key: from-file\n", env: "from-env",
+			args: []string{"--port", "7070", "--tmdb-key", "from-flag"}, wantPort: 7070, wantKey: "from-flag",
+		},
+		{name: "flag over file", file: "port: 9090\n", args: []string{"--port", "7070"}, wantPort: 7070},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TMDB_API_KEY", tt.env)
+			cmd, port, key, _ := newConfigTestCmd()
+			if err := cmd.ParseFlags(tt.args); err != nil {
+				t.Fatalf("parsing flags: %v", err)
+			}
+
+			if tt.file != "" {
+				if err := loadConfigFile(cmd, writeConfigFile(t, tt.file)); err != nil {
+					t.Fatalf("loadConfigFile: %v", err)
+				}
+			}
+
+			if *port != tt.wantPort {
+				t.Errorf("port = %d, want %d", *port, tt.wantPort)
+			}
+			if *key != tt.wantKey {
+				t.Errorf("tmdb-key = %q, want %q", *key, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFileList(t *testing.T) {
+	cmd, _, _, proxies := newConfigTestCmd()
+
+	if err := loadConfigFile(cmd, writeConfigFile(t, "trusted-proxies: [10.0.0.0/8, 192.168.0.0/16]\n")); err != nil {
+		t.Fatalf("loadConfigFile: %v", err)
+	}
+
+	if want := []string{"10.0.0.0/8", "192.168.0.0/16"}; !slices.Equal(*proxies, want) {
+		t.Errorf("trusted-proxies = %v, want %v", *proxies, want)
+	}
+}
+
+func TestLoadConfigFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		wantErr string
+	}{
+		{name: "unknown setting", file: "colour: blue\n", wantErr: `unknown setting "colour"`},
+		{name: "nested config", file: "config: other.yaml\n", wantErr: `unknown setting "config"`},
+		{name: "bad value", file: "port: ninety\n", wantErr: "invalid argument"},
+		{name: "not YAML", file: "port: [\n", wantErr: "parsing config file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, _, _, _ := newConfigTestCmd()
+
+			err := loadConfigFile(cmd, writeConfigFile(t, tt.file))
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("loadConfigFile = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	cmd, _, _, _ := newConfigTestCmd()
+	if err := loadConfigFile(cmd, filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("loadConfigFile with a missing file = nil, want an error")
+	}
+}
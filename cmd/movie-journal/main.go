@@ -3,17 +3,31 @@ package main
 
 import (
 	"context"
+	"encoding/csv"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io/fs"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
+	"text/tabwriter"
 	"time"
 
+	"github.com/pavelanni/movie-journal/internal/answers"
 	"github.com/pavelanni/movie-journal/internal/database"
+	"github.com/pavelanni/movie-journal/internal/journal"
+	"github.com/pavelanni/movie-journal/internal/linkcheck"
+	"github.com/pavelanni/movie-journal/internal/models"
 	"github.com/pavelanni/movie-journal/internal/server"
+	"github.com/pavelanni/movie-journal/internal/telemetry"
+	"github.com/pavelanni/movie-journal/internal/tmdb"
+	"github.com/pavelanni/movie-journal/templates"
 	"github.com/spf13/cobra"
 )
 
@@ -27,10 +41,57 @@ var (
 )
 
 var (
-	port   int
-	dbPath string
+	host             string
+	appName          string
+	port             int
+	dbPath           string
+	tmdbKey          string
+	tmdbMaxAttempts  int
+	tmdbRetryDelay   time.Duration
+	otelEndpoint     string
+	trustedProxies   []string
+	logSamplePaths   []string
+	logSampleRate    float64
+	dateFormat       string
+	suggestAnswers   bool
+	notesMarkdown    bool
+	statsJSON        bool
+	optimize         bool
+	maxNotesLength   int
+	recentLimit      int
+	perPage          int
+	ratingColors     string
+	ratingSymbol     string
+	adminPassword    string
+	requestTimeout   time.Duration
+	slowQuery        time.Duration
+	dbCacheSize      int
+	dbTempInMemory   bool
+	draftTTL         time.Duration
+	csp              string
+	tlsCert          string
+	tlsKey           string
+	configPath       string
+	exportCategory   string
+	exportFormat     string
+	exportMovieID    int64
+	addTitle         string
+	addYear          int
+	addRating        string
+	addDate          string
+	addNotes         string
+	addWith          string
+	addLocation      string
+	addFormat        string
+	linksTimeout     time.Duration
+	linksInterval    time.Duration
+	linksConcurrency int
+	linksMark        bool
 )
 
+// defaultMaxNotesLength caps entry notes unless --max-notes-length says otherwise.
+const defaultMaxNotesLength = 5000
+
 var rootCmd = &cobra.Command{
 	Use:   "movie-journal",
 	Short: "Personal movie diary application",
@@ -50,6 +111,66 @@ var serveCmd = &cobra.Command{
 	RunE:  runServe,
 }
 
+var pruneMoviesCmd = &cobra.Command{
+	Use:   "prune-movies",
+	Short: "Remove movies without diary entries",
+	Long:  `Delete cached movies that are no longer referenced by any diary entry.`,
+	RunE:  runPruneMovies,
+}
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Apply database migrations and exit",
+	Long: `Bring the database schema up to date without starting the server.
+Safe to run repeatedly, e.g. as an init container step.`,
+	RunE: runMigrate,
+}
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Print diary statistics",
+	Long:  `Print the totals shown on the web stats page: films, entries, ratings, genres, and streaks.`,
+	RunE:  runStats,
+}
+
+var vacuumCmd = &cobra.Command{
+	Use:   "vacuum",
+	Short: "Reclaim unused space in the database file",
+	Long: `Rebuild the database file to reclaim space left by deleted rows and report
+its size before and after. VACUUM needs exclusive access, so stop the server first.`,
+	RunE: runVacuum,
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export diary data",
+}
+
+var exportLookupsCmd = &cobra.Command{
+	Use:   "lookups",
+	Short: "Export research moments as CSV or JSON",
+	Long: `Write lookups with the movie they came up in to standard output, optionally
+only those in one category or for one movie, e.g. every location to plan a trip.`,
+	RunE: runExportLookups,
+}
+
+var addCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Add a diary entry and print its ID",
+	Long: `Add a diary entry without opening the browser, e.g. from a script or shell alias.
+The movie is looked up in the library by title, and on TMDB if a key is set, just as
+when the new entry form is submitted. Values are validated the same way.`,
+	RunE: runAdd,
+}
+
+var checkLinksCmd = &cobra.Command{
+	Use:   "check-links",
+	Short: "Report lookup URLs that no longer work",
+	Long: `Request every lookup URL and list the dead ones: those answering with an error
+status, or not at all. Requests are spread out over time so no site is flooded.`,
+	RunE: runCheckLinks,
+}
+
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Print version information",
@@ -61,10 +182,102 @@ var versionCmd = &cobra.Command{
 }
 
 func init() {
+	serveCmd.Flags().StringVar(&configPath, "config", "",
+		"YAML file of serve flag names and values; command-line flags and environment variables take precedence")
+	serveCmd.Flags().StringVar(&appName, "app-name", "Movie Journal", "App name shown when installed to a home screen")
+	serveCmd.Flags().StringVar(&host, "host", "", "Host or IP address to bind to (default all interfaces)")
 	serveCmd.Flags().IntVarP(&port, "port", "p", 8080, "Port to listen on")
 	serveCmd.Flags().StringVarP(&dbPath, "db", "d", "movie-journal.db", "Path to SQLite database file")
+	serveCmd.Flags().StringVar(&tmdbKey, "tmdb-key", os.Getenv("TMDB_API_KEY"),
+		"TMDB API key for movie search (defaults to $TMDB_API_KEY)")
+	addTMDBRetryFlags(serveCmd)
+	serveCmd.Flags().StringVar(&otelEndpoint, "otel-endpoint", "",
+		"OTLP/HTTP endpoint to send traces to, e.g. http://localhost:4318 (tracing is off when empty)")
+	serveCmd.Flags().StringVar(&dateFormat, "date-format", "",
+		"How to display dates: iso, short, long, dmy, or mdy (default depends on the page)")
+	addMaxNotesLengthFlag(serveCmd)
+	serveCmd.Flags().IntVar(&recentLimit, "recent-limit", 20,
+		"Number of most recent entries the home page shows before linking to the full diary")
+	serveCmd.Flags().IntVar(&perPage, "per-page", 20, "Number of entries on each page of the diary")
+	serveCmd.Flags().BoolVar(&suggestAnswers, "suggest-answers", false,
+		"Suggest answers to lookup questions from Wikipedia")
+	serveCmd.Flags().BoolVar(&notesMarkdown, "notes-markdown", false,
+		"Render entry notes as Markdown on detail pages (raw HTML is left out); cards show plain text")
+	serveCmd.Flags().StringVar(&ratingColors, "rating-colors", "",
+		`Star colors as min=class pairs from high to low plus a fallback class, e.g. "4=text-green-400,3=text-yellow-400,text-red-400"`)
+	serveCmd.Flags().StringVar(&ratingSymbol, "rating-symbol", string(templates.SymbolStar),
+		"Symbol to draw ratings with: star, circle, or square")
+	serveCmd.Flags().StringVar(&adminPassword, "admin-password", os.Getenv("MOVIE_JOURNAL_ADMIN_PASSWORD"),
+		"Password for the admin endpoints, user \"admin\" (defaults to $MOVIE_JOURNAL_ADMIN_PASSWORD; admin is off when empty)")
+	serveCmd.Flags().DurationVar(&requestTimeout, "request-timeout", 10*time.Second,
+		"Maximum time to handle a request before responding 503 (0 for no limit)")
+	serveCmd.Flags().DurationVar(&slowQuery, "slow-query-threshold", 200*time.Millisecond,
+		"Log database queries that take longer than this (0 to turn off)")
+	serveCmd.Flags().IntVar(&dbCacheSize, "db-cache-size", 16*1024,
+		"SQLite page cache per database connection, in KiB (0 for SQLite's default of about 2 MiB)")
+	serveCmd.Flags().BoolVar(&dbTempInMemory, "db-temp-in-memory", true,
+		"Sort and group large query results in memory instead of temporary files")
+	serveCmd.Flags().DurationVar(&draftTTL, "draft-ttl", 7*24*time.Hour,
+		"How long to keep an untouched new entry draft (0 to keep drafts forever)")
+	serveCmd.Flags().StringVar(&csp, "csp", server.DefaultContentSecurityPolicy,
+		"Content-Security-Policy header, e.g. to allow images from a CDN (empty to send none)")
+	serveCmd.Flags().StringVar(&tlsCert, "tls-cert", "", "TLS certificate file; serves HTTPS together with --tls-key")
+	serveCmd.Flags().StringVar(&tlsKey, "tls-key", "", "TLS private key file; serves HTTPS together with --tls-cert")
+	serveCmd.Flags().Float64Var(&logSampleRate, "log-sample-rate", 1,
+		"Fraction of successful requests under --log-sample-paths to log, from 0 to 1")
+	serveCmd.Flags().StringSliceVar(&logSamplePaths, "log-sample-paths", []string{"/static/"},
+		"Comma-separated path prefixes whose successful requests are logged at --log-sample-rate")
+	serveCmd.Flags().StringSliceVar(&trustedProxies, "trusted-proxies", nil,
+		"Comma-separated CIDR ranges of reverse proxies whose X-Forwarded-For header is trusted")
+
+	pruneMoviesCmd.Flags().StringVarP(&dbPath, "db", "d", "movie-journal.db", "Path to SQLite database file")
+
+	migrateCmd.Flags().StringVarP(&dbPath, "db", "d", "movie-journal.db", "Path to SQLite database file")
+
+	statsCmd.Flags().StringVarP(&dbPath, "db", "d", "movie-journal.db", "Path to SQLite database file")
+	statsCmd.Flags().BoolVar(&statsJSON, "json", false, "Print the statistics as JSON")
+
+	vacuumCmd.Flags().StringVarP(&dbPath, "db", "d", "movie-journal.db", "Path to SQLite database file")
+	vacuumCmd.Flags().BoolVar(&optimize, "optimize", false, "Also run PRAGMA optimize to refresh query planner statistics")
+
+	exportLookupsCmd.Flags().StringVarP(&dbPath, "db", "d", "movie-journal.db", "Path to SQLite database file")
+	exportLookupsCmd.Flags().StringVar(&exportCategory, "category", "",
+		"Only export lookups in this category: actor, location, trivia, or other")
+	exportLookupsCmd.Flags().Int64Var(&exportMovieID, "movie-id", 0, "Only export lookups for the movie with this ID")
+	exportLookupsCmd.Flags().StringVar(&exportFormat, "format", "csv", "Output format: csv or json")
+	exportCmd.AddCommand(exportLookupsCmd)
+
+	addCmd.Flags().StringVarP(&dbPath, "db", "d", "movie-journal.db", "Path to SQLite database file")
+	addCmd.Flags().StringVar(&tmdbKey, "tmdb-key", os.Getenv("TMDB_API_KEY"),
+		"TMDB API key for adding movies not yet in the library (defaults to $TMDB_API_KEY)")
+	addTMDBRetryFlags(addCmd)
+	addMaxNotesLengthFlag(addCmd)
+	addCmd.Flags().StringVar(&addTitle, "title", "", "Title of the movie watched")
+	addCmd.Flags().IntVar(&addYear, "year", 0, "Release year, to pick between movies with the same title")
+	addCmd.Flags().StringVar(&addRating, "rating", "", "Rating from 1 to 5 (unrated when empty)")
+	addCmd.Flags().StringVar(&addDate, "date", "", "Date watched as YYYY-MM-DD (default today)")
+	addCmd.Flags().StringVar(&addNotes, "notes", "", "Notes about the viewing")
+	addCmd.Flags().StringVar(&addWith, "with", "", "Who you watched it with")
+	addCmd.Flags().StringVar(&addLocation, "location", "", "Where you watched it")
+	addCmd.Flags().StringVar(&addFormat, "format", "", "How you watched it, e.g. Cinema or 4K Blu-ray")
+	_ = addCmd.MarkFlagRequired("title")
+
+	checkLinksCmd.Flags().StringVarP(&dbPath, "db", "d", "movie-journal.db", "Path to SQLite database file")
+	checkLinksCmd.Flags().DurationVar(&linksTimeout, "timeout", 10*time.Second, "How long each link gets to answer")
+	checkLinksCmd.Flags().IntVar(&linksConcurrency, "concurrency", 4, "Number of links checked at once")
+	checkLinksCmd.Flags().DurationVar(&linksInterval, "interval", 250*time.Millisecond,
+		"Minimum time between starting two requests (0 for no limit)")
+	checkLinksCmd.Flags().BoolVar(&linksMark, "mark", false,
+		"Record in the database which lookup links work and which are dead")
 
 	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(addCmd)
+	rootCmd.AddCommand(pruneMoviesCmd)
+	rootCmd.AddCommand(migrateCmd)
+	rootCmd.AddCommand(statsCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(checkLinksCmd)
+	rootCmd.AddCommand(vacuumCmd)
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.SetVersionTemplate(fmt.Sprintf("movie-journal version %s\nBuilt: %s\nCommit: %s\n",
 		Version, BuildDate, Commit))
@@ -76,36 +289,184 @@ func main() {
 	}
 }
 
-func runServe(_ *cobra.Command, _ []string) error {
+// checkTLSFiles makes sure the TLS certificate and key are given together and exist.
+func checkTLSFiles(cert, key string) error {
+	if (cert == "") != (key == "") {
+		return errors.New("--tls-cert and --tls-key must be used together")
+	}
+	for _, file := range []string{cert, key} {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			return fmt.Errorf("reading TLS file: %w", err)
+		}
+	}
+	return nil
+}
+
+// addTMDBRetryFlags adds the flags that tune how newTMDBClient retries failed requests.
+func addTMDBRetryFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&tmdbMaxAttempts, "tmdb-max-attempts", 3, "Maximum attempts for rate-limited or failing TMDB requests")
+	cmd.Flags().DurationVar(&tmdbRetryDelay, "tmdb-retry-delay", 500*time.Millisecond,
+		"Initial delay between TMDB retries, doubled on each attempt")
+}
+
+// addMaxNotesLengthFlag adds the flag that caps the length of entry notes.
+func addMaxNotesLengthFlag(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&maxNotesLength, "max-notes-length", defaultMaxNotesLength,
+		"Maximum length of entry notes, in characters (0 for no limit)")
+}
+
+// newTMDBClient returns a TMDB client configured by the --tmdb-* flags, or nil when no
+// API key is set.
+func newTMDBClient() *tmdb.Client {
+	if tmdbKey == "" {
+		return nil
+	}
+	return tmdb.NewClient(tmdbKey, tmdb.WithRetry(tmdbMaxAttempts, tmdbRetryDelay))
+}
+
+func runServe(cmd *cobra.Command, _ []string) error {
 	// Setup logging
 	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
 		Level: slog.LevelInfo,
 	}))
 	slog.SetDefault(logger)
 
+	if configPath != "" {
+		if err := loadConfigFile(cmd, configPath); err != nil {
+			return err
+		}
+		slog.Info("Loaded config file", slog.String("path", configPath))
+	}
+
+	if recentLimit < 1 {
+		return fmt.Errorf("invalid --recent-limit %d: must be at least 1", recentLimit)
+	}
+	if perPage < 1 {
+		return fmt.Errorf("invalid --per-page %d: must be at least 1", perPage)
+	}
+
+	if logSampleRate < 0 || logSampleRate > 1 {
+		return fmt.Errorf("invalid --log-sample-rate %g: must be between 0 and 1", logSampleRate)
+	}
+
+	if err := checkTLSFiles(tlsCert, tlsKey); err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	if _, err := net.ResolveTCPAddr("tcp", addr); err != nil {
+		return fmt.Errorf("invalid listen address %q: %w", addr, err)
+	}
+
+	proxies, err := server.ParseTrustedProxies(trustedProxies)
+	if err != nil {
+		return err
+	}
+
+	var starColors *templates.RatingColors
+	if ratingColors != "" {
+		rc, err := templates.ParseRatingColors(ratingColors)
+		if err != nil {
+			return err
+		}
+		starColors = &rc
+	}
+
+	symbol, err := templates.ParseRatingSymbol(ratingSymbol)
+	if err != nil {
+		return err
+	}
+
+	var dateLayout string
+	if dateFormat != "" {
+		dateLayout, err = templates.DateFormatLayout(dateFormat)
+		if err != nil {
+			return err
+		}
+	}
+
 	slog.Info("Starting Movie Journal",
 		slog.String("version", Version),
+		slog.String("host", host),
 		slog.Int("port", port),
 		slog.String("database", dbPath),
+		slog.Bool("tmdb", tmdbKey != ""),
+		slog.Bool("tracing", otelEndpoint != ""),
 	)
 
+	shutdownTracing, err := telemetry.Setup(context.Background(), otelEndpoint, Version)
+	if err != nil {
+		return fmt.Errorf("setting up tracing: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("Failed to flush traces", slog.String("error", err.Error()))
+		}
+	}()
+
 	// Open database
-	db, err := database.Open(dbPath)
+	dbOptions := []database.OpenOption{database.WithCacheSize(dbCacheSize)}
+	if dbTempInMemory {
+		dbOptions = append(dbOptions, database.WithTempStoreMemory())
+	}
+	db, err := database.Open(dbPath, dbOptions...)
 	if err != nil {
 		return fmt.Errorf("opening database: %w", err)
 	}
 	defer func() { _ = db.Close() }()
+	db.SetSlowQueryThreshold(slowQuery)
+
+	tmdbClient := newTMDBClient()
+
+	var answerer answers.Answerer
+	if suggestAnswers {
+		answerer = answers.NewWikipedia()
+	}
 
 	// Create server
 	srv := server.New(server.Config{
-		Port: port,
-		DB:   db,
+		AppName:               appName,
+		Version:               Version,
+		AdminPassword:         adminPassword,
+		Host:                  host,
+		Port:                  port,
+		DB:                    db,
+		TMDB:                  tmdbClient,
+		TrustedProxies:        proxies,
+		LogSamplePaths:        logSamplePaths,
+		LogSampleRate:         logSampleRate,
+		DateFormat:            dateLayout,
+		RatingColors:          starColors,
+		RatingSymbol:          symbol,
+		NotesMarkdown:         notesMarkdown,
+		Answerer:              answerer,
+		MaxNotesLength:        maxNotesLength,
+		RecentLimit:           recentLimit,
+		PerPage:               perPage,
+		RequestTimeout:        requestTimeout,
+		DraftTTL:              draftTTL,
+		ContentSecurityPolicy: csp,
+		TLSCertFile:           tlsCert,
+		TLSKeyFile:            tlsKey,
 	})
 
 	// Start server in goroutine
 	errChan := make(chan error, 1)
 	go func() {
-		fmt.Printf("\nMovie Journal server running at http://localhost:%d\n", port)
+		displayHost := host
+		if displayHost == "" {
+			displayHost = "localhost"
+		}
+		scheme := "http"
+		if srv.TLS() {
+			scheme = "https"
+		}
+		fmt.Printf("\nMovie Journal server running at %s://%s\n", scheme, net.JoinHostPort(displayHost, strconv.Itoa(port)))
 		fmt.Println("Press Ctrl+C to stop")
 		if err := srv.Start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			errChan <- err
@@ -134,3 +495,270 @@ func runServe(_ *cobra.Command, _ []string) error {
 	slog.Info("Server stopped gracefully")
 	return nil
 }
+
+func runPruneMovies(_ *cobra.Command, _ []string) error {
+	db, err := database.Open(dbPath)
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	ctx, cancel := database.WithTimeout(30 * time.Second)
+	defer cancel()
+
+	n, err := db.PruneOrphanMovies(ctx)
+	if err != nil {
+		return fmt.Errorf("pruning movies: %w", err)
+	}
+
+	fmt.Printf("Removed %d orphaned movie(s)\n", n)
+	return nil
+}
+
+func runMigrate(cmd *cobra.Command, _ []string) error {
+	// Open applies any pending migrations
+	db, err := database.Open(dbPath)
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	ctx, cancel := database.WithTimeout(30 * time.Second)
+	defer cancel()
+
+	version, err := db.SchemaVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("getting schema version: %w", err)
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Database schema is at version %d\n", version)
+	return nil
+}
+
+func runVacuum(cmd *cobra.Command, _ []string) error {
+	before, err := databaseSize(dbPath)
+	if err != nil {
+		return fmt.Errorf("checking database size: %w", err)
+	}
+
+	db, err := database.Open(dbPath)
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	fmt.Fprintln(cmd.ErrOrStderr(), "Vacuuming; this needs exclusive access, so make sure the server isn't running")
+
+	ctx, cancel := database.WithTimeout(10 * time.Minute)
+	defer cancel()
+
+	if err := db.Vacuum(ctx, optimize); err != nil {
+		return fmt.Errorf("vacuuming database: %w", err)
+	}
+
+	after, err := databaseSize(dbPath)
+	if err != nil {
+		return fmt.Errorf("checking database size: %w", err)
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Database size: %d bytes before, %d bytes after\n", before, after)
+	return nil
+}
+
+// databaseSize returns the size of the database file plus its write-ahead log, if any.
+// The database file itself must exist, so vacuum doesn't create an empty one.
+func databaseSize(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	size := info.Size()
+
+	wal, err := os.Stat(path + "-wal")
+	if err == nil {
+		size += wal.Size()
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return 0, err
+	}
+	return size, nil
+}
+
+func runStats(cmd *cobra.Command, _ []string) error {
+	db, err := database.Open(dbPath)
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	ctx, cancel := database.WithTimeout(30 * time.Second)
+	defer cancel()
+
+	stats, err := db.GetStats(ctx, time.Now())
+	if err != nil {
+		return fmt.Errorf("getting stats: %w", err)
+	}
+
+	out := cmd.OutOrStdout()
+	if statsJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(stats)
+	}
+
+	topGenre := stats.TopGenre
+	if topGenre == "" {
+		topGenre = "-"
+	}
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Films\t%d\n", stats.TotalMovies)
+	fmt.Fprintf(tw, "Entries\t%d\n", stats.TotalEntries)
+	fmt.Fprintf(tw, "This year\t%d\n", stats.EntriesThisYear)
+	fmt.Fprintf(tw, "Average rating\t%.1f\n", stats.AverageRating)
+	fmt.Fprintf(tw, "Top genre\t%s\n", topGenre)
+	fmt.Fprintf(tw, "Words written\t%d\n", stats.TotalWords)
+	fmt.Fprintf(tw, "Current streak\t%d day(s)\n", stats.CurrentStreak)
+	return tw.Flush()
+}
+
+func runAdd(cmd *cobra.Command, _ []string) error {
+	db, err := database.Open(dbPath)
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	// Entries are validated and their movies resolved as when submitted on the web
+	entries := journal.New(journal.Config{
+		DB:             db,
+		TMDB:           newTMDBClient(),
+		MaxNotesLength: maxNotesLength,
+	})
+
+	form := url.Values{
+		"movie_title":      {addTitle},
+		"rating":           {addRating},
+		"watched_date":     {addDate},
+		"notes":            {addNotes},
+		"watched_with":     {addWith},
+		"watched_location": {addLocation},
+		"format":           {addFormat},
+	}
+	if addYear != 0 {
+		form.Set("movie_year", strconv.Itoa(addYear))
+	}
+
+	ctx, cancel := database.WithTimeout(30 * time.Second)
+	defer cancel()
+
+	id, _, err := entries.AddEntry(ctx, form, time.Now())
+	var verr *models.ValidationError
+	if errors.As(err, &verr) {
+		return fmt.Errorf("invalid entry: %w", err)
+	}
+	if err != nil {
+		return fmt.Errorf("adding entry: %w", err)
+	}
+
+	fmt.Fprintln(cmd.OutOrStdout(), id)
+	return nil
+}
+
+func runExportLookups(cmd *cobra.Command, _ []string) error {
+	category := models.LookupCategory(exportCategory)
+	if category != "" && !category.Valid() {
+		return fmt.Errorf("unknown category %q: use actor, location, trivia, or other", exportCategory)
+	}
+	if exportFormat != "csv" && exportFormat != "json" {
+		return fmt.Errorf("unknown format %q: use csv or json", exportFormat)
+	}
+
+	db, err := database.Open(dbPath)
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	ctx, cancel := database.WithTimeout(30 * time.Second)
+	defer cancel()
+
+	lookups, err := db.ExportLookups(ctx, category, exportMovieID)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if exportFormat == "json" {
+		if lookups == nil {
+			lookups = []models.ExportedLookup{}
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(lookups)
+	}
+
+	w := csv.NewWriter(out)
+	_ = w.Write([]string{"question", "answer", "url", "category", "movie"})
+	for _, l := range lookups {
+		_ = w.Write([]string{l.Question, l.Answer, l.URL, string(l.Category), l.Movie})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func runCheckLinks(cmd *cobra.Command, _ []string) error {
+	db, err := database.Open(dbPath)
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	ctx, cancel := database.WithTimeout(30 * time.Second)
+	defer cancel()
+
+	urls, err := db.LookupURLs(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Checking takes as long as it takes; stop early on Ctrl-C
+	checkCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	checker := linkcheck.New(
+		linkcheck.WithTimeout(linksTimeout),
+		linkcheck.WithConcurrency(linksConcurrency),
+		linkcheck.WithInterval(linksInterval),
+	)
+	results := checker.Check(checkCtx, urls)
+	if checkCtx.Err() != nil {
+		return errors.New("interrupted before all links were checked")
+	}
+
+	out := cmd.OutOrStdout()
+	linkOK := make(map[string]bool, len(results))
+	dead := 0
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, result := range results {
+		linkOK[result.URL] = result.OK()
+		if !result.OK() {
+			dead++
+			fmt.Fprintf(tw, "%s\t%s\n", result.URL, result.Problem())
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Checked %d link(s), %d dead\n", len(results), dead)
+
+	if linksMark {
+		ctx, cancel := database.WithTimeout(30 * time.Second)
+		defer cancel()
+
+		n, err := db.MarkLookupLinks(ctx, linkOK)
+		if err != nil {
+			return fmt.Errorf("marking lookup links: %w", err)
+		}
+		fmt.Fprintf(out, "Marked %d lookup(s)\n", n)
+	}
+	return nil
+}
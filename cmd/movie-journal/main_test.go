@@ -0,0 +1,510 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pavelanni/movie-journal/internal/database"
+	"github.com/pavelanni/movie-journal/internal/models"
+)
+
+func TestAddCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "diary.db")
+	db, err := database.Open(path)
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	if _, err := db.SaveMovie(context.Background(), models.Movie{TMDBID: 438631, Title: "Dune", Year: 2021}); err != nil {
+		t.Fatalf("saving movie: %v", err)
+	}
+	_ = db.Close()
+
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetArgs([]string{
+		"add", "--db", path, "--tmdb-key", "", "--title", "dune", "--rating", "4",
+		"--date", "2024-06-01", "--notes", "Rewatch", "--format", "IMAX",
+	})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+
+	id, err := strconv.ParseInt(strings.TrimSpace(out.String()), 10, 64)
+	if err != nil {
+		t.Fatalf("add printed %q, want the new entry's ID", out.String())
+	}
+	db, err = database.Open(path)
+	if err != nil {
+		t.Fatalf("reopening database: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	entry, err := db.GetDiaryEntry(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetDiaryEntry(%d): %v", id, err)
+	}
+	if entry.Movie.Title != "Dune" || entry.Rating != 4 || entry.Notes != "Rewatch" || entry.Format != "IMAX" ||
+		entry.WatchedDate.Format("2006-01-02") != "2024-06-01" {
+		t.Errorf("entry = %+v, want the viewing added", entry)
+	}
+}
+
+func TestServeRejectsUnknownDateFormat(t *testing.T) {
+	t.Cleanup(func() { dateFormat = "" })
+	rootCmd.SetArgs([]string{"serve", "--db", filepath.Join(t.TempDir(), "diary.db"), "--date-format", "02.01.2006"})
+
+	err := rootCmd.Execute()
+
+	if err == nil || !strings.Contains(err.Error(), "allowed: dmy, iso, long, mdy, short") {
+		t.Errorf("serve = %v, want an error listing the allowed formats", err)
+	}
+}
+
+func TestStatsCommand(t *testing.T) {
+	t.Cleanup(func() { statsJSON = false })
+	path := filepath.Join(t.TempDir(), "diary.db")
+	db, err := database.Open(path)
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	ctx := context.Background()
+	dune, err := db.SaveMovie(ctx, models.Movie{TMDBID: 438631, Title: "Dune", Year: 2021})
+	if err != nil {
+		t.Fatalf("saving movie: %v", err)
+	}
+	if err := db.SetMovieGenres(ctx, dune.ID, []string{"Science Fiction"}); err != nil {
+		t.Fatalf("setting genres: %v", err)
+	}
+	heat, err := db.SaveMovie(ctx, models.Movie{TMDBID: 949, Title: "Heat", Year: 1995})
+	if err != nil {
+		t.Fatalf("saving movie: %v", err)
+	}
+	longAgo := time.Date(2020, time.June, 1, 0, 0, 0, 0, time.UTC)
+	for _, input := range []models.DiaryEntryInput{
+		{MovieID: dune.ID, WatchedAt: longAgo, Rating: 5},
+		{MovieID: dune.ID, WatchedAt: time.Now(), Rating: 4},
+		{MovieID: heat.ID, WatchedAt: longAgo},
+	} {
+		if _, err := db.CreateDiaryEntry(ctx, input); err != nil {
+			t.Fatalf("creating entry: %v", err)
+		}
+	}
+	_ = db.Close()
+
+	run := func(args ...string) string {
+		t.Helper()
+		var out bytes.Buffer
+		rootCmd.SetOut(&out)
+		rootCmd.SetArgs(append([]string{"stats", "--db", path}, args...))
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("stats %v: %v", args, err)
+		}
+		return out.String()
+	}
+
+	table := run()
+	for _, want := range []string{
+		"Films           2\n",
+		"Entries         3\n",
+		"This year       1\n",
+		"Average rating  4.5\n",
+		"Top genre       Science Fiction\n",
+	} {
+		if !strings.Contains(table, want) {
+			t.Errorf("stats output doesn't contain %q:\n%s", want, table)
+		}
+	}
+
+	var stats models.Stats
+	if err := json.Unmarshal([]byte(run("--json")), &stats); err != nil {
+		t.Fatalf("decoding --json output: %v", err)
+	}
+	if stats.TotalMovies != 2 || stats.TotalEntries != 3 || stats.EntriesThisYear != 1 ||
+		stats.AverageRating != 4.5 || stats.TopGenre != "Science Fiction" {
+		t.Errorf("stats = %+v, want the seeded totals", stats)
+	}
+}
+
+func TestMigrateCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "diary.db")
+
+	run := func() string {
+		t.Helper()
+		var out bytes.Buffer
+		rootCmd.SetOut(&out)
+		rootCmd.SetArgs([]string{"migrate", "--db", path})
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("migrate: %v", err)
+		}
+		return out.String()
+	}
+
+	first := run()
+	// Running it again on an up-to-date database changes nothing
+	if again := run(); again != first {
+		t.Errorf("second run printed %q, want %q", again, first)
+	}
+
+	db, err := database.Open(path)
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	ctx := context.Background()
+	version, err := db.SchemaVersion(ctx)
+	if err != nil {
+		t.Fatalf("SchemaVersion: %v", err)
+	}
+	if want := fmt.Sprintf("Database schema is at version %d\n", version); first != want {
+		t.Errorf("migrate printed %q, want %q", first, want)
+	}
+	var rows int
+	err = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM schema_migrations WHERE version = ?", version).Scan(&rows)
+	if err != nil || rows != 1 {
+		t.Errorf("schema_migrations has %d rows (%v) for version %d, want 1", rows, err, version)
+	}
+}
+
+func TestVacuumCommand(t *testing.T) {
+	t.Cleanup(func() { optimize = false })
+	path := filepath.Join(t.TempDir(), "diary.db")
+	db, err := database.Open(path)
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	ctx := context.Background()
+	movie, err := db.SaveMovie(ctx, models.Movie{TMDBID: 438631, Title: "Dune", Year: 2021})
+	if err != nil {
+		t.Fatalf("saving movie: %v", err)
+	}
+	notes := strings.Repeat("The spice must flow. ", 200)
+	ids := make([]int64, 200)
+	for i := range ids {
+		ids[i], err = db.CreateDiaryEntry(ctx, models.DiaryEntryInput{
+			MovieID: movie.ID, WatchedAt: time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i), Notes: notes,
+		})
+		if err != nil {
+			t.Fatalf("creating entry: %v", err)
+		}
+	}
+	// Deleting the entries leaves their pages free in the file
+	if _, err := db.DeleteEntries(ctx, ids); err != nil {
+		t.Fatalf("deleting entries: %v", err)
+	}
+	_ = db.Close()
+
+	var out, errOut bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(&errOut)
+	t.Cleanup(func() { rootCmd.SetErr(nil) })
+	rootCmd.SetArgs([]string{"vacuum", "--db", path, "--optimize"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("vacuum: %v", err)
+	}
+
+	var before, after int64
+	if _, err := fmt.Sscanf(out.String(), "Database size: %d bytes before, %d bytes after", &before, &after); err != nil {
+		t.Fatalf("vacuum printed %q: %v", out.String(), err)
+	}
+	if after >= before {
+		t.Errorf("size went from %d to %d bytes, want it to shrink", before, after)
+	}
+	// Closing the database may fold what's left of the WAL back in, but never grows it
+	if size, err := databaseSize(path); err != nil || size > after {
+		t.Errorf("database is %d bytes (%v), want at most the reported %d", size, err, after)
+	}
+	if !strings.Contains(errOut.String(), "exclusive access") {
+		t.Errorf("vacuum doesn't warn about exclusive access: %q", errOut.String())
+	}
+}
+
+func TestCheckTLSFiles(t *testing.T) {
+	dir := t.TempDir()
+	cert := filepath.Join(dir, "cert.pem")
+	key := filepath.Join(dir, "key.pem")
+	for _, file := range []string{cert, key} {
+		if err := os.WriteFile(file, []byte("fixture"), 0o600); err != nil {
+			t.Fatalf("writing %s: %v", file, err)
+		}
+	}
+	missing := filepath.Join(dir, "missing.pem")
+
+	tests := []struct {
+		name    string
+		cert    string
+		key     string
+		wantErr string
+	}{
+		{name: "plain HTTP"},
+		{name: "both files", cert: cert, key: key},
+		{name: "certificate only", cert: cert, wantErr: "must be used together"},
+		{name: "key only", key: key, wantErr: "must be used together"},
+		{name: "missing certificate", cert: missing, key: key, wantErr: "reading TLS file"},
+		{name: "missing key", cert: cert, key: missing, wantErr: "reading TLS file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkTLSFiles(tt.cert, tt.key)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkTLSFiles() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkTLSFiles() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// seedLookups writes a diary with lookups of different categories for two films to path,
+// returning the ID of the second film.
+func seedLookups(t *testing.T, path string) int64 {
+	t.Helper()
+	db, err := database.Open(path)
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	ctx := context.Background()
+
+	var movieID int64
+	for _, film := range []struct {
+		title   string
+		lookups []models.LookupInput
+		tmdbID  int
+	}{
+		{title: "Dune", tmdbID: 438631, lookups: []models.LookupInput{
+			{Question: "Where was Arrakis filmed?", Answer: "Wadi Rum", URL: "https://example.com/wadi-rum", Category: models.LookupCategoryLocation},
+			{Question: "Who plays Paul?", Answer: "Timothée Chalamet", Category: models.LookupCategoryActor},
+		}},
+		{title: "Heat", tmdbID: 949, lookups: []models.LookupInput{
+			{Question: "Where is the diner?", Answer: "Kate Mantilini, Beverly Hills", Category: models.LookupCategoryLocation},
+			{Question: "How long was the shootout?", Answer: "About ten minutes", Category: models.LookupCategoryTrivia},
+		}},
+	} {
+		movie, err := db.SaveMovie(ctx, models.Movie{TMDBID: film.tmdbID, Title: film.title})
+		if err != nil {
+			t.Fatalf("saving %s: %v", film.title, err)
+		}
+		movieID = movie.ID
+		entryID, err := db.CreateDiaryEntry(ctx, models.DiaryEntryInput{
+			MovieID: movie.ID, WatchedAt: time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC), Rating: 4,
+		})
+		if err != nil {
+			t.Fatalf("creating entry for %s: %v", film.title, err)
+		}
+		if _, err := db.CreateLookups(ctx, entryID, film.lookups); err != nil {
+			t.Fatalf("creating lookups for %s: %v", film.title, err)
+		}
+	}
+	return movieID
+}
+
+func TestExportLookupsCommand(t *testing.T) {
+	t.Cleanup(func() {
+		exportCategory = ""
+		exportFormat = "csv"
+		exportMovieID = 0
+	})
+	path := filepath.Join(t.TempDir(), "diary.db")
+	heatID := seedLookups(t, path)
+
+	tests := []struct {
+		name    string
+		wantErr string
+		args    []string
+		want    [][]string
+	}{
+		{
+			name: "location as CSV",
+			args: []string{"--category", "location"},
+			want: [][]string{
+				{"question", "answer", "url", "category", "movie"},
+				{"Where was Arrakis filmed?", "Wadi Rum", "https://example.com/wadi-rum", "location", "Dune"},
+				{"Where is the diner?", "Kate Mantilini, Beverly Hills", "", "location", "Heat"},
+			},
+		},
+		{
+			name: "location for one movie",
+			args: []string{"--category", "location", "--movie-id", strconv.FormatInt(heatID, 10)},
+			want: [][]string{
+				{"question", "answer", "url", "category", "movie"},
+				{"Where is the diner?", "Kate Mantilini, Beverly Hills", "", "location", "Heat"},
+			},
+		},
+		{
+			name: "every category for one movie",
+			args: []string{"--movie-id", strconv.FormatInt(heatID, 10)},
+			want: [][]string{
+				{"question", "answer", "url", "category", "movie"},
+				{"Where is the diner?", "Kate Mantilini, Beverly Hills", "", "location", "Heat"},
+				{"How long was the shootout?", "About ten minutes", "", "trivia", "Heat"},
+			},
+		},
+		{name: "unknown category", args: []string{"--category", "places"}, wantErr: `unknown category "places"`},
+		{name: "unknown format", args: []string{"--format", "xml"}, wantErr: `unknown format "xml"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exportCategory, exportFormat, exportMovieID = "", "csv", 0
+			var out bytes.Buffer
+			rootCmd.SetOut(&out)
+			rootCmd.SetArgs(append([]string{"export", "lookups", "--db", path}, tt.args...))
+
+			err := rootCmd.Execute()
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("export lookups = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("export lookups: %v", err)
+			}
+			got, err := csv.NewReader(&out).ReadAll()
+			if err != nil {
+				t.Fatalf("parsing CSV: %v\n%s", err, out.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("export lookups =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportLookupsCommandJSON(t *testing.T) {
+	t.Cleanup(func() {
+		exportCategory = ""
+		exportFormat = "csv"
+	})
+	path := filepath.Join(t.TempDir(), "diary.db")
+	seedLookups(t, path)
+
+	tests := []struct {
+		category string
+		want     []models.ExportedLookup
+	}{
+		{category: "actor", want: []models.ExportedLookup{
+			{Question: "Who plays Paul?", Answer: "Timothée Chalamet", Category: models.LookupCategoryActor, Movie: "Dune"},
+		}},
+		// With nothing to export, the output is still a JSON array
+		{category: "other", want: []models.ExportedLookup{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.category, func(t *testing.T) {
+			var out bytes.Buffer
+			rootCmd.SetOut(&out)
+			rootCmd.SetArgs([]string{"export", "lookups", "--db", path, "--category", tt.category, "--format", "json"})
+			if err := rootCmd.Execute(); err != nil {
+				t.Fatalf("export lookups: %v", err)
+			}
+
+			var got []models.ExportedLookup
+			if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+				t.Fatalf("parsing JSON: %v\n%s", err, out.String())
+			}
+			if got == nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("export lookups = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServeRejectsUnknownRatingSymbol(t *testing.T) {
+	t.Cleanup(func() { ratingSymbol = "star" })
+	rootCmd.SetArgs([]string{"serve", "--db", filepath.Join(t.TempDir(), "diary.db"), "--rating-symbol", "heart"})
+
+	err := rootCmd.Execute()
+
+	if err == nil || !strings.Contains(err.Error(), "allowed: star, circle, square") {
+		t.Errorf("serve = %v, want an error listing the allowed symbols", err)
+	}
+}
+
+func TestCheckLinksCommand(t *testing.T) {
+	t.Cleanup(func() {
+		linksInterval = 250 * time.Millisecond
+		linksMark = false
+	})
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(site.Close)
+	live, gone := site.URL+"/ok", site.URL+"/gone"
+
+	path := filepath.Join(t.TempDir(), "diary.db")
+	db, err := database.Open(path)
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	ctx := context.Background()
+	movie, err := db.SaveMovie(ctx, models.Movie{TMDBID: 438631, Title: "Dune", Year: 2021})
+	if err != nil {
+		t.Fatalf("saving movie: %v", err)
+	}
+	entryID, err := db.CreateDiaryEntry(ctx, models.DiaryEntryInput{
+		MovieID: movie.ID, WatchedAt: time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("creating entry: %v", err)
+	}
+	if _, err := db.CreateLookups(ctx, entryID, []models.LookupInput{
+		{Question: "Where was Arrakis filmed?", URL: live},
+		{Question: "Who plays Paul?", URL: gone},
+		{Question: "What is the spice?"},
+	}); err != nil {
+		t.Fatalf("creating lookups: %v", err)
+	}
+	_ = db.Close()
+
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetArgs([]string{"check-links", "--db", path, "--interval", "0", "--mark"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("check-links: %v", err)
+	}
+
+	report := out.String()
+	for _, want := range []string{gone + "  404 Not Found", "Checked 2 link(s), 1 dead", "Marked 2 lookup(s)"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report doesn't contain %q:\n%s", want, report)
+		}
+	}
+	if strings.Contains(report, live+" ") {
+		t.Errorf("report lists the working link as dead:\n%s", report)
+	}
+
+	db, err = database.Open(path)
+	if err != nil {
+		t.Fatalf("reopening database: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	for url, want := range map[string]bool{live: true, gone: false} {
+		var ok bool
+		if err := db.QueryRowContext(ctx, "SELECT link_ok FROM lookups WHERE url = ?", url).Scan(&ok); err != nil {
+			t.Fatalf("reading link_ok for %s: %v", url, err)
+		}
+		if ok != want {
+			t.Errorf("link_ok for %s = %t, want %t", url, ok, want)
+		}
+	}
+}
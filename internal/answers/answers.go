@@ -0,0 +1,22 @@
+// Package answers suggests answers to lookup questions from external sources.
+package answers
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoAnswer is returned when a source has nothing relevant to suggest.
+var ErrNoAnswer = errors.New("no answer found")
+
+// Suggestion is a candidate answer together with where it came from.
+type Suggestion struct {
+	Answer string `json:"answer"`
+	URL    string `json:"url"`
+}
+
+// Answerer suggests an answer to a free-text question.
+type Answerer interface {
+	// Suggest returns the best candidate answer, or ErrNoAnswer if there is none.
+	Suggest(ctx context.Context, question string) (*Suggestion, error)
+}
@@ -0,0 +1,143 @@
+package answers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// defaultWikipediaURL is the English Wikipedia site.
+	defaultWikipediaURL = "https://en.wikipedia.org"
+	// userAgent identifies the app, as the Wikimedia API policy asks.
+	userAgent = "movie-journal (https://github.com/pavelanni/movie-journal)"
+	// maxSnippetLength caps the suggested answer, in runes.
+	maxSnippetLength = 300
+)
+
+// tracer creates spans for Wikipedia calls. It's a no-op unless tracing is configured.
+var tracer = otel.Tracer("github.com/pavelanni/movie-journal/internal/answers")
+
+// Wikipedia suggests answers from the summary of the best-matching Wikipedia article.
+// Matching uses the OpenSearch API, which searches article titles, so it works best
+// when the question names its subject.
+type Wikipedia struct {
+	httpClient *http.Client
+	baseURL    string
+}
+
+// NewWikipedia creates an Answerer backed by English Wikipedia.
+func NewWikipedia() *Wikipedia {
+	return &Wikipedia{
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		baseURL:    defaultWikipediaURL,
+	}
+}
+
+// Suggest finds the article that best matches the question and returns the start of its summary.
+func (wp *Wikipedia) Suggest(ctx context.Context, question string) (_ *Suggestion, err error) {
+	ctx, span := tracer.Start(ctx, "wikipedia suggest", trace.WithSpanKind(trace.SpanKindClient))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	query := strings.TrimRight(strings.TrimSpace(question), "?!. ")
+	if query == "" {
+		return nil, ErrNoAnswer
+	}
+
+	// OpenSearch answers with [query, [titles], [descriptions], [urls]]
+	var results []json.RawMessage
+	params := url.Values{
+		"action":    {"opensearch"},
+		"search":    {query},
+		"limit":     {"1"},
+		"namespace": {"0"},
+		"format":    {"json"},
+	}
+	if err := wp.get(ctx, "/w/api.php?"+params.Encode(), &results); err != nil {
+		return nil, err
+	}
+	var titles, urls []string
+	if len(results) < 4 {
+		return nil, fmt.Errorf("decoding opensearch response: got %d parts, want 4", len(results))
+	}
+	if err := json.Unmarshal(results[1], &titles); err != nil {
+		return nil, fmt.Errorf("decoding opensearch titles: %w", err)
+	}
+	if err := json.Unmarshal(results[3], &urls); err != nil {
+		return nil, fmt.Errorf("decoding opensearch URLs: %w", err)
+	}
+	if len(titles) == 0 || len(urls) == 0 {
+		return nil, ErrNoAnswer
+	}
+
+	var summary struct {
+		Extract string `json:"extract"`
+	}
+	path := "/api/rest_v1/page/summary/" + url.PathEscape(strings.ReplaceAll(titles[0], " ", "_"))
+	if err := wp.get(ctx, path, &summary); err != nil {
+		return nil, err
+	}
+	if summary.Extract == "" {
+		return nil, ErrNoAnswer
+	}
+
+	return &Suggestion{Answer: truncate(summary.Extract, maxSnippetLength), URL: urls[0]}, nil
+}
+
+// get requests a path on the Wikipedia site and decodes the JSON response into out.
+func (wp *Wikipedia) get(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wp.baseURL+path, http.NoBody)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := wp.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("requesting Wikipedia: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNoAnswer
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("requesting Wikipedia: unexpected status %s", resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding Wikipedia response: %w", err)
+	}
+	return nil
+}
+
+// truncate shortens s to at most n runes, cutting at the last sentence or word boundary.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	cut := string(runes[:n])
+	if i := strings.LastIndex(cut, ". "); i > 0 {
+		return cut[:i+1]
+	}
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return cut + "…"
+}
@@ -0,0 +1,47 @@
+package database
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/pavelanni/movie-journal/internal/models"
+)
+
+// lookupRules maps phrases found in lookup questions to the category they suggest.
+// Rules are tried in order and the first match wins, so put more specific phrases first.
+// Phrases match whole words of the question, ignoring case and punctuation, so "cast"
+// doesn't match "broadcast".
+var lookupRules = []struct {
+	category models.LookupCategory
+	phrases  []string
+}{
+	{models.LookupCategoryTrivia, []string{
+		"true story", "based on", "inspired by", "behind the scenes", "box office",
+		"how much did", "budget", "trivia", "easter egg", "deleted scene",
+	}},
+	{models.LookupCategoryLocation, []string{
+		"where was", "where is", "where were", "filmed", "shot in", "shot on location",
+		"filming location", "set in",
+	}},
+	{models.LookupCategoryActor, []string{
+		"who plays", "who played", "who is the actor", "who is the actress", "who voices",
+		"who voiced", "actor", "actress", "cast", "starring",
+	}},
+}
+
+// classifyLookup guesses a lookup's category from its question, defaulting to other.
+func classifyLookup(question string) models.LookupCategory {
+	// Padding the words with spaces lets a phrase only match from a word's start to a word's end
+	words := strings.FieldsFunc(strings.ToLower(question), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+	q := " " + strings.Join(words, " ") + " "
+	for _, rule := range lookupRules {
+		for _, phrase := range rule.phrases {
+			if strings.Contains(q, " "+phrase+" ") {
+				return rule.category
+			}
+		}
+	}
+	return models.LookupCategoryOther
+}
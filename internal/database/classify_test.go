@@ -0,0 +1,42 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/pavelanni/movie-journal/internal/models"
+)
+
+func TestClassifyLookup(t *testing.T) {
+	tests := []struct {
+		question string
+		want     models.LookupCategory
+	}{
+		{"Who plays the villain?", models.LookupCategoryActor},
+		{"Who voiced the robot", models.LookupCategoryActor},
+		{"Is the ACTOR also a singer?", models.LookupCategoryActor},
+		{"What else was the cast in?", models.LookupCategoryActor},
+		{"Where was the final scene filmed?", models.LookupCategoryLocation},
+		{"Is it set in Chicago?", models.LookupCategoryLocation},
+		{"Which city is this shot in", models.LookupCategoryLocation},
+		{"Is this based on a true story?", models.LookupCategoryTrivia},
+		{"How much did it make at the box office?", models.LookupCategoryTrivia},
+		{"What was the budget?", models.LookupCategoryTrivia},
+		// Specific phrases win over broader ones of later rules
+		{"Was the actor's house set in a true story?", models.LookupCategoryTrivia},
+		// Phrases only match whole words
+		{"When was it first broadcast?", models.LookupCategoryOther},
+		{"What's the X factor in this plot?", models.LookupCategoryOther},
+		{"Why did the clock reset in the last act?", models.LookupCategoryOther},
+		{"Is the castle real?", models.LookupCategoryOther},
+		{"What's that song called?", models.LookupCategoryOther},
+		{"", models.LookupCategoryOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.question, func(t *testing.T) {
+			if got := classifyLookup(tt.question); got != tt.want {
+				t.Errorf("classifyLookup(%q) = %q, want %q", tt.question, got, tt.want)
+			}
+		})
+	}
+}
@@ -4,35 +4,105 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/url"
+	"strings"
 	"time"
 
-	_ "modernc.org/sqlite" // SQLite driver
+	"github.com/pavelanni/movie-journal/internal/models"
+	"modernc.org/sqlite"
 )
 
+// Sentinel errors returned by DB methods.
+var (
+	// ErrNotFound is returned when a requested record doesn't exist.
+	ErrNotFound = errors.New("not found")
+	// ErrInvalidInput is returned when input fails validation before reaching the database.
+	ErrInvalidInput = errors.New("invalid input")
+	// ErrConflict is returned when a record changed since the copy being saved was loaded.
+	ErrConflict = errors.New("conflict")
+)
+
+// busyTimeout is how long a statement waits for another connection's write to finish.
+const busyTimeout = 5 * time.Second
+
 // DB wraps the SQL database connection with Movie Journal operations.
 type DB struct {
 	*sql.DB
+	// slowQuery is how long a query may take before it's logged as slow; zero logs none.
+	slowQuery time.Duration
+}
+
+// OpenOption tunes the SQLite connections opened by Open.
+type OpenOption func(*openOptions)
+
+// openOptions holds the settings applied to every connection.
+type openOptions struct {
+	// cacheSizeKiB is the page cache size per connection; zero keeps SQLite's default.
+	cacheSizeKiB    int
+	tempStoreMemory bool
+}
+
+// WithCacheSize gives each connection a page cache of kib kibibytes instead of SQLite's
+// default of about 2 MiB. A larger cache keeps more of a big diary in memory for sorting
+// and searching, at the cost of up to kib of memory for every open connection.
+func WithCacheSize(kib int) OpenOption {
+	return func(o *openOptions) {
+		o.cacheSizeKiB = kib
+	}
+}
+
+// WithTempStoreMemory keeps the temporary tables and indexes SQLite builds for sorting
+// and grouping in memory rather than in temporary files. Large sorts get faster but use
+// as much memory as the data being sorted.
+func WithTempStoreMemory() OpenOption {
+	return func(o *openOptions) {
+		o.tempStoreMemory = true
+	}
+}
+
+// dataSourceName adds the options to path as _pragma parameters, which the driver runs
+// on every new connection. PRAGMAs run with Exec would only reach one pooled connection.
+func (o openOptions) dataSourceName(path string) string {
+	params := url.Values{}
+	// Foreign keys are off by default and have to be turned on for each connection, or
+	// ON DELETE CASCADE and reference checks would depend on which connection runs
+	params.Add("_pragma", "foreign_keys(1)")
+	// Writers wait for each other instead of failing straight away with SQLITE_BUSY
+	params.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", busyTimeout.Milliseconds()))
+	if o.cacheSizeKiB > 0 {
+		// A negative cache_size is in KiB rather than pages
+		params.Add("_pragma", fmt.Sprintf("cache_size(-%d)", o.cacheSizeKiB))
+	}
+	if o.tempStoreMemory {
+		params.Add("_pragma", "temp_store(memory)")
+	}
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + params.Encode()
 }
 
 // Open opens a SQLite database at the given path.
 // It creates the database file if it doesn't exist and runs migrations.
-func Open(path string) (*DB, error) {
-	db, err := sql.Open("sqlite", path)
+func Open(path string, opts ...OpenOption) (*DB, error) {
+	var o openOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	db, err := sql.Open("sqlite", o.dataSourceName(path))
 	if err != nil {
 		return nil, fmt.Errorf("opening database: %w", err)
 	}
 
 	ctx := context.Background()
 
-	// Enable foreign keys
-	if _, err := db.ExecContext(ctx, "PRAGMA foreign_keys = ON"); err != nil {
-		_ = db.Close()
-		return nil, fmt.Errorf("enabling foreign keys: %w", err)
-	}
-
-	// Enable WAL mode for better concurrency
+	// Enable WAL mode for better concurrency. It's stored in the database file, so unlike
+	// the connection settings in the DSN it only has to be set once.
 	if _, err := db.ExecContext(ctx, "PRAGMA journal_mode = WAL"); err != nil {
 		_ = db.Close()
 		return nil, fmt.Errorf("enabling WAL mode: %w", err)
@@ -50,6 +120,12 @@ func Open(path string) (*DB, error) {
 	return wrapped, nil
 }
 
+// SetSlowQueryThreshold makes queries that take longer than d get logged as warnings.
+// Zero, the default, turns slow query logging off. Call it before using the database.
+func (db *DB) SetSlowQueryThreshold(d time.Duration) {
+	db.slowQuery = d
+}
+
 // Close closes the database connection.
 func (db *DB) Close() error {
 	return db.DB.Close()
@@ -59,3 +135,42 @@ func (db *DB) Close() error {
 func WithTimeout(timeout time.Duration) (context.Context, context.CancelFunc) {
 	return context.WithTimeout(context.Background(), timeout)
 }
+
+// Vacuum rebuilds the database file to reclaim free pages, then checkpoints the WAL so the
+// space is returned to the filesystem. With optimize set it also runs PRAGMA optimize to
+// refresh query planner statistics. VACUUM needs exclusive access to the database.
+func (db *DB) Vacuum(ctx context.Context, optimize bool) error {
+	if _, err := db.ExecContext(ctx, "VACUUM"); err != nil {
+		return fmt.Errorf("vacuuming: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return fmt.Errorf("checkpointing WAL: %w", err)
+	}
+	if optimize {
+		if _, err := db.ExecContext(ctx, "PRAGMA optimize"); err != nil {
+			return fmt.Errorf("optimizing: %w", err)
+		}
+	}
+	return nil
+}
+
+// isConstraintError reports whether err is a SQLite constraint violation with the given extended code.
+func isConstraintError(err error, code int) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == code
+}
+
+// checkViolation turns a CHECK constraint failure into a ValidationError. SQLite doesn't
+// name the column, so the field is guessed from the constraint text in the message.
+func checkViolation(err error) *models.ValidationError {
+	var verr models.ValidationError
+	switch msg := err.Error(); {
+	case strings.Contains(msg, "rating"):
+		verr.Add("rating", "rating must be between 1 and 5")
+	case strings.Contains(msg, "category"):
+		verr.Add("category", "unknown category")
+	default:
+		verr.Add("input", "value out of range")
+	}
+	return &verr
+}
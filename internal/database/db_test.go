@@ -0,0 +1,197 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pavelanni/movie-journal/internal/models"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// openTestDB opens a fresh database in a temporary directory.
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+// addTestEntry saves a movie and logs a viewing of it, returning the entry ID.
+func addTestEntry(t *testing.T, db *DB, tmdbID int, title string) int64 {
+	t.Helper()
+	ctx := context.Background()
+	movie, err := db.SaveMovie(ctx, models.Movie{TMDBID: tmdbID, Title: title, Year: 2021})
+	if err != nil {
+		t.Fatalf("saving movie: %v", err)
+	}
+	id, err := db.CreateDiaryEntry(ctx, models.DiaryEntryInput{
+		MovieID:   movie.ID,
+		WatchedAt: time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC),
+		Rating:    4,
+	})
+	if err != nil {
+		t.Fatalf("creating entry: %v", err)
+	}
+	return id
+}
+
+func TestOpenAppliesPragmasToEveryConnection(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"), WithCacheSize(8192), WithTempStoreMemory())
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	// Holding each connection makes the pool open a new one for the next
+	conns := make([]*sql.Conn, 5)
+	for i := range conns {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			t.Fatalf("getting connection %d: %v", i, err)
+		}
+		defer func() { _ = conn.Close() }()
+		conns[i] = conn
+	}
+
+	pragmas := []struct {
+		name string
+		want int
+	}{
+		{name: "foreign_keys", want: 1},
+		{name: "busy_timeout", want: int(busyTimeout.Milliseconds())},
+		{name: "cache_size", want: -8192},
+		{name: "temp_store", want: 2},
+	}
+	for i, conn := range conns {
+		for _, p := range pragmas {
+			var got int
+			if err := conn.QueryRowContext(ctx, "PRAGMA "+p.name).Scan(&got); err != nil {
+				t.Fatalf("reading %s: %v", p.name, err)
+			}
+			if got != p.want {
+				t.Errorf("connection %d: %s = %d, want %d", i, p.name, got, p.want)
+			}
+		}
+	}
+}
+
+// BenchmarkSortQuery sorts a large diary by a column without an index, with SQLite's
+// default cache and temp store and with the tuned ones.
+func BenchmarkSortQuery(b *testing.B) {
+	benchmarks := []struct {
+		name string
+		opts []OpenOption
+	}{
+		{name: "default"},
+		{name: "tuned", opts: []OpenOption{WithCacheSize(64 * 1024), WithTempStoreMemory()}},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			ctx := context.Background()
+			db, err := Open(filepath.Join(b.TempDir(), "bench.db"), bm.opts...)
+			if err != nil {
+				b.Fatalf("opening database: %v", err)
+			}
+			defer func() { _ = db.Close() }()
+			seedEntries(b, db, 20000)
+
+			for b.Loop() {
+				rows, err := db.QueryContext(ctx,
+					"SELECT id, notes FROM diary_entries ORDER BY notes, rating DESC, id")
+				if err != nil {
+					b.Fatalf("querying: %v", err)
+				}
+				for rows.Next() {
+				}
+				if err := rows.Close(); err != nil {
+					b.Fatalf("reading rows: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// seedEntries inserts n entries with varied notes for one movie in a single transaction.
+func seedEntries(tb testing.TB, db *DB, n int) {
+	tb.Helper()
+	ctx := context.Background()
+	movie, err := db.SaveMovie(ctx, models.Movie{TMDBID: 1, Title: "Seed", Year: 2000})
+	if err != nil {
+		tb.Fatalf("saving movie: %v", err)
+	}
+	_, err = db.ExecContext(ctx, `
+		WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < ?)
+		INSERT INTO diary_entries (movie_id, watched_at, rating, notes)
+		SELECT ?, date('2020-01-01', '+' || (i % 1500) || ' days'), i % 5 + 1,
+			hex(randomblob(16)) || ' notes about the film'
+		FROM n`, n, movie.ID)
+	if err != nil {
+		tb.Fatalf("seeding entries: %v", err)
+	}
+}
+
+func TestCheckViolation(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	entryID := addTestEntry(t, db, 438631, "Dune")
+
+	tests := []struct {
+		name      string
+		query     string
+		wantField string
+		args      []any
+	}{
+		{
+			name:      "rating out of range",
+			query:     "UPDATE diary_entries SET rating = ? WHERE id = ?",
+			args:      []any{9, entryID},
+			wantField: "rating",
+		},
+		{
+			name:      "unknown category",
+			query:     "INSERT INTO lookups (diary_entry_id, question, category) VALUES (?, 'Who?', ?)",
+			args:      []any{entryID, "gossip"},
+			wantField: "category",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := db.ExecContext(ctx, tt.query, tt.args...)
+			if !isConstraintError(err, sqlite3.SQLITE_CONSTRAINT_CHECK) {
+				t.Fatalf("exec = %v, want a CHECK constraint failure", err)
+			}
+			verr := checkViolation(err)
+			if _, ok := verr.Fields[tt.wantField]; !ok || len(verr.Fields) != 1 {
+				t.Errorf("fields = %v, want only %q", verr.Fields, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestCreateDiaryEntryInvalidInput(t *testing.T) {
+	db := openTestDB(t)
+
+	_, err := db.CreateDiaryEntry(context.Background(), models.DiaryEntryInput{Rating: 7})
+
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("err = %v, want ErrInvalidInput", err)
+	}
+	var verr *models.ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("err = %v, want a ValidationError", err)
+	}
+	for _, field := range []string{"movie_id", "watched_at", "rating"} {
+		if _, ok := verr.Fields[field]; !ok {
+			t.Errorf("fields = %v, missing %q", verr.Fields, field)
+		}
+	}
+}
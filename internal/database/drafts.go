@@ -0,0 +1,62 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// SaveDraft stores the in-progress new entry form under the given draft ID,
+// replacing any earlier draft with that ID.
+func (db *DB) SaveDraft(ctx context.Context, id string, form url.Values) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO drafts (id, form, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET form = excluded.form, updated_at = excluded.updated_at
+	`, id, form.Encode(), time.Now().UTC().Format(timestampLayout))
+	if err != nil {
+		return fmt.Errorf("saving draft: %w", err)
+	}
+	return nil
+}
+
+// GetDraft returns the draft with the given ID. Drafts last saved before notBefore count
+// as expired; a zero notBefore never expires them. It returns ErrNotFound when there's
+// no live draft.
+func (db *DB) GetDraft(ctx context.Context, id string, notBefore time.Time) (url.Values, error) {
+	var encoded string
+	err := db.QueryRowContext(ctx, `
+		SELECT form FROM drafts WHERE id = ? AND updated_at >= ?
+	`, id, notBefore.UTC().Format(timestampLayout)).Scan(&encoded)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting draft: %w", err)
+	}
+
+	form, err := url.ParseQuery(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decoding draft: %w", err)
+	}
+	return form, nil
+}
+
+// DeleteDraft removes the draft with the given ID, if there is one.
+func (db *DB) DeleteDraft(ctx context.Context, id string) error {
+	if _, err := db.ExecContext(ctx, "DELETE FROM drafts WHERE id = ?", id); err != nil {
+		return fmt.Errorf("deleting draft: %w", err)
+	}
+	return nil
+}
+
+// DeleteDraftsBefore removes drafts last saved before the cutoff and returns how many were removed.
+func (db *DB) DeleteDraftsBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := db.ExecContext(ctx, "DELETE FROM drafts WHERE updated_at < ?", cutoff.UTC().Format(timestampLayout))
+	if err != nil {
+		return 0, fmt.Errorf("deleting expired drafts: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestDrafts(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	if _, err := db.GetDraft(ctx, "missing", time.Time{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetDraft for an unknown ID = %v, want ErrNotFound", err)
+	}
+
+	// A later save replaces the earlier one
+	if err := db.SaveDraft(ctx, "a", url.Values{"notes": {"First thoughts"}}); err != nil {
+		t.Fatalf("SaveDraft: %v", err)
+	}
+	want := url.Values{"movie_title": {"Dune"}, "notes": {"Spice & sand\nline two"}}
+	if err := db.SaveDraft(ctx, "a", want); err != nil {
+		t.Fatalf("SaveDraft: %v", err)
+	}
+	got, err := db.GetDraft(ctx, "a", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("GetDraft: %v", err)
+	}
+	if got.Encode() != want.Encode() {
+		t.Errorf("draft = %v, want %v", got, want)
+	}
+
+	if err := db.DeleteDraft(ctx, "a"); err != nil {
+		t.Fatalf("DeleteDraft: %v", err)
+	}
+	if _, err := db.GetDraft(ctx, "a", time.Time{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetDraft after delete = %v, want ErrNotFound", err)
+	}
+}
+
+func TestDraftsExpire(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	for _, id := range []string{"stale", "fresh"} {
+		if err := db.SaveDraft(ctx, id, url.Values{"notes": {id}}); err != nil {
+			t.Fatalf("SaveDraft: %v", err)
+		}
+	}
+	stale := time.Now().Add(-48 * time.Hour).UTC().Format(timestampLayout)
+	if _, err := db.ExecContext(ctx, "UPDATE drafts SET updated_at = ? WHERE id = 'stale'", stale); err != nil {
+		t.Fatalf("aging draft: %v", err)
+	}
+	cutoff := time.Now().Add(-24 * time.Hour)
+
+	if _, err := db.GetDraft(ctx, "stale", cutoff); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetDraft for an expired draft = %v, want ErrNotFound", err)
+	}
+	// Without a cutoff nothing expires
+	if _, err := db.GetDraft(ctx, "stale", time.Time{}); err != nil {
+		t.Errorf("GetDraft without a cutoff: %v", err)
+	}
+
+	deleted, err := db.DeleteDraftsBefore(ctx, cutoff)
+	if err != nil {
+		t.Fatalf("DeleteDraftsBefore: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("deleted %d drafts, want 1", deleted)
+	}
+	if _, err := db.GetDraft(ctx, "stale", time.Time{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("stale draft is still stored: %v", err)
+	}
+	if _, err := db.GetDraft(ctx, "fresh", cutoff); err != nil {
+		t.Errorf("fresh draft was removed: %v", err)
+	}
+}
@@ -0,0 +1,743 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pavelanni/movie-journal/internal/models"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// dateLayout is the storage format for watched dates.
+const dateLayout = "2006-01-02"
+
+// updatedAtLayout is the storage format for updated_at. Trailing zero fractions are dropped,
+// so it also matches the whole-second created_at values the column was backfilled with.
+const updatedAtLayout = "2006-01-02 15:04:05.999999999"
+
+// maxSlugAttempts limits how many times slug generation is retried on collisions.
+const maxSlugAttempts = 5
+
+// slugRandom is the source of slug suffixes. Tests replace it to force collisions.
+var slugRandom io.Reader = rand.Reader
+
+// entryColumns lists the columns selected for a diary entry joined with its movie.
+const entryColumns = `
+	e.id, e.movie_id, e.watched_at, COALESCE(e.watched_location, ''), COALESCE(e.format, ''), COALESCE(e.rating, 0),
+	COALESCE(e.notes, ''), COALESCE(e.watched_with, ''), COALESCE(e.slug, ''), e.created_at, e.updated_at,
+	m.id, m.tmdb_id, m.title, COALESCE(m.year, 0), COALESCE(m.poster_url, ''),
+	COALESCE(m.director, ''), COALESCE(m.genre, ''), COALESCE(m.overview, '')`
+
+// scanner is implemented by both *Row and *Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// CreateDiaryEntry inserts a new diary entry with a unique slug and returns its ID. A new
+// movie in the input is saved in the same transaction, so it isn't left behind in the
+// library if the entry can't be created.
+func (db *DB) CreateDiaryEntry(ctx context.Context, input models.DiaryEntryInput) (int64, error) {
+	if err := input.Validate(); err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrInvalidInput, err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	id, err := createDiaryEntry(ctx, tx, &input)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing transaction: %w", err)
+	}
+	newMovieSaved(input)
+	return id, nil
+}
+
+// createDiaryEntry inserts a validated diary entry, first saving its new movie if it has
+// one, using q, which may be a transaction. The input's MovieID is set to the new movie's.
+func createDiaryEntry(ctx context.Context, q runner, input *models.DiaryEntryInput) (int64, error) {
+	title, err := resolveEntryMovie(ctx, q, input)
+	if err != nil {
+		return 0, err
+	}
+
+	format, err := canonicalFormat(ctx, q, input.Format, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	for range maxSlugAttempts {
+		slug, err := newSlug(title, input.WatchedAt)
+		if err != nil {
+			return 0, err
+		}
+
+		result, err := q.ExecContext(ctx, `
+			INSERT INTO diary_entries (movie_id, watched_at, watched_location, format, rating, notes, watched_with, slug, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, input.MovieID, input.WatchedAt.Format(dateLayout), input.Location, format,
+			nullableRating(input.Rating), input.Notes, input.WatchedWith, slug, time.Now().UTC().Format(updatedAtLayout))
+		if isConstraintError(err, sqlite3.SQLITE_CONSTRAINT_UNIQUE) {
+			continue
+		}
+		if isConstraintError(err, sqlite3.SQLITE_CONSTRAINT_CHECK) {
+			return 0, fmt.Errorf("%w: %w", ErrInvalidInput, checkViolation(err))
+		}
+		if err != nil {
+			return 0, fmt.Errorf("inserting diary entry: %w", err)
+		}
+
+		return result.LastInsertId()
+	}
+
+	return 0, fmt.Errorf("generating unique slug: %d attempts collided", maxSlugAttempts)
+}
+
+// resolveEntryMovie saves the input's new movie, if it has one, and sets the input's
+// MovieID to it. It returns the title of the movie the entry is for.
+func resolveEntryMovie(ctx context.Context, q runner, input *models.DiaryEntryInput) (string, error) {
+	if input.NewMovie != nil {
+		id, err := saveMovie(ctx, q, *input.NewMovie)
+		if err != nil {
+			return "", err
+		}
+		input.MovieID = id
+		return input.NewMovie.Title, nil
+	}
+
+	var title string
+	err := q.QueryRowContext(ctx, "SELECT title FROM movies WHERE id = ?", input.MovieID).Scan(&title)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("movie %d: %w", input.MovieID, ErrNotFound)
+	}
+	if err != nil {
+		return "", fmt.Errorf("getting movie: %w", err)
+	}
+	return title, nil
+}
+
+// newMovieSaved fills in the ID of the input's new movie, if it has one, once the
+// transaction that saved it has committed.
+func newMovieSaved(input models.DiaryEntryInput) {
+	if input.NewMovie != nil {
+		input.NewMovie.ID = input.MovieID
+	}
+}
+
+// GetDiaryEntry returns the diary entry with the given ID, including its movie and lookups.
+func (db *DB) GetDiaryEntry(ctx context.Context, id int64) (*models.DiaryEntry, error) {
+	entry, err := db.getDiaryEntry(ctx, "e.id = ?", id)
+	if errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("diary entry %d: %w", id, err)
+	}
+	return entry, err
+}
+
+// GetDiaryEntryBySlug returns the diary entry with the given slug, including its movie and lookups.
+func (db *DB) GetDiaryEntryBySlug(ctx context.Context, slug string) (*models.DiaryEntry, error) {
+	entry, err := db.getDiaryEntry(ctx, "e.slug = ?", slug)
+	if errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("diary entry %q: %w", slug, err)
+	}
+	return entry, err
+}
+
+// getDiaryEntry returns the single diary entry matching the condition, with its genres and lookups.
+// It takes two queries: the entry, movie and genres in one, the lookups in the other.
+func (db *DB) getDiaryEntry(ctx context.Context, condition string, arg any) (*models.DiaryEntry, error) {
+	row := db.QueryRowContext(ctx, `
+		SELECT `+entryColumns+`, (
+			SELECT json_group_array(g.name ORDER BY mg.position, g.name)
+			FROM genres g
+			JOIN movie_genres mg ON mg.genre_id = g.id
+			WHERE mg.movie_id = m.id
+		)
+		FROM diary_entries e
+		JOIN movies m ON m.id = e.movie_id
+		WHERE `+condition, arg)
+
+	var genres string
+	entry, err := scanEntry(row, &genres)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting diary entry: %w", err)
+	}
+	if genres != "[]" {
+		if err := json.Unmarshal([]byte(genres), &entry.Movie.Genres); err != nil {
+			return nil, fmt.Errorf("decoding movie genres: %w", err)
+		}
+	}
+
+	entry.Lookups, err = db.listLookups(ctx, entry.ID)
+	if err != nil {
+		return nil, err
+	}
+	entry.LookupCount = len(entry.Lookups)
+
+	return entry, nil
+}
+
+// Orders for diary entry lists. Both end with the entry ID, so entries that tie on
+// everything else, such as several watched on the same day, keep their order between
+// queries and never move from one page to another.
+const (
+	orderByWatched = "e.watched_at DESC, e.id DESC"
+	orderByRating  = "COALESCE(e.rating, 0) DESC, e.watched_at DESC, e.id DESC"
+)
+
+// ListDiaryEntries returns all diary entries with their movies, most recently watched first.
+// Lookups aren't loaded, but each entry's LookupCount is filled in.
+func (db *DB) ListDiaryEntries(ctx context.Context) ([]models.DiaryEntry, error) {
+	return db.listDiaryEntries(ctx, "ORDER BY "+orderByWatched)
+}
+
+// ListDiaryEntriesFiltered is like ListDiaryEntries, but only returns the entries matching
+// every filter set in filter, sorted by its Sort order. Watched dates have no time of day,
+// so the WatchedFrom and WatchedTo bounds are compared by their calendar dates. Filters
+// that don't parse, such as a non-numeric MinRating, aren't applied.
+func (db *DB) ListDiaryEntriesFiltered(ctx context.Context, filter models.EntryFilter) ([]models.DiaryEntry, error) {
+	entries, _, err := db.ListDiaryEntriesPage(ctx, filter, EntryPage{})
+	return entries, err
+}
+
+// EntryPage picks a page of a diary entry list. With After set, the page starts right
+// after that cursor; otherwise the first Offset entries are skipped. A zero Limit puts
+// every remaining entry on the page.
+type EntryPage struct {
+	After  *EntryCursor
+	Limit  int
+	Offset int
+}
+
+// EntryCursor marks a position in a list of diary entries by the sort key of the last
+// entry seen. Rating is only used by lists sorted by rating.
+type EntryCursor struct {
+	WatchedDate time.Time
+	ID          int64
+	Rating      int
+}
+
+// String encodes the cursor for use in a URL.
+func (c EntryCursor) String() string {
+	return fmt.Sprintf("%s.%d.%d", c.WatchedDate.Format(dateLayout), c.ID, c.Rating)
+}
+
+// ParseEntryCursor decodes a cursor produced by EntryCursor.String.
+func ParseEntryCursor(s string) (EntryCursor, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return EntryCursor{}, fmt.Errorf("%w: malformed cursor %q", ErrInvalidInput, s)
+	}
+	watched, err := time.Parse(dateLayout, parts[0])
+	if err != nil {
+		return EntryCursor{}, fmt.Errorf("%w: malformed cursor %q", ErrInvalidInput, s)
+	}
+	id, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return EntryCursor{}, fmt.Errorf("%w: malformed cursor %q", ErrInvalidInput, s)
+	}
+	rating, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return EntryCursor{}, fmt.Errorf("%w: malformed cursor %q", ErrInvalidInput, s)
+	}
+	return EntryCursor{WatchedDate: watched, ID: id, Rating: rating}, nil
+}
+
+// ListDiaryEntriesPage returns a page of the entries ListDiaryEntriesFiltered would
+// return. It also returns the cursor for the next page, which is nil on the last page.
+// Paging by cursor compares the whole sort key, ending with the entry ID, so pages neither
+// skip nor repeat entries that share a watched date or rating.
+func (db *DB) ListDiaryEntriesPage(
+	ctx context.Context,
+	filter models.EntryFilter,
+	page EntryPage,
+) ([]models.DiaryEntry, *EntryCursor, error) {
+	conditions, args := entryFilterConditions(filter)
+	byRating := filter.Sort == models.SortRating
+	order := orderByWatched
+	if byRating {
+		order = orderByRating
+	}
+	if after := page.After; after != nil {
+		if byRating {
+			conditions = append(conditions, "(COALESCE(e.rating, 0), e.watched_at, e.id) < (?, ?, ?)")
+			args = append(args, after.Rating)
+		} else {
+			conditions = append(conditions, "(e.watched_at, e.id) < (?, ?)")
+		}
+		args = append(args, after.WatchedDate.Format(dateLayout), after.ID)
+	}
+
+	clauses := whereClause(conditions) + " ORDER BY " + order
+	if page.Limit > 0 {
+		// Fetch one extra row to tell whether there's another page
+		clauses += " LIMIT ? OFFSET ?"
+		args = append(args, page.Limit+1, max(page.Offset, 0))
+	} else if page.Offset > 0 {
+		clauses += " LIMIT -1 OFFSET ?"
+		args = append(args, page.Offset)
+	}
+
+	entries, err := db.listDiaryEntries(ctx, clauses, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if page.Limit <= 0 || len(entries) <= page.Limit {
+		return entries, nil, nil
+	}
+
+	entries = entries[:page.Limit]
+	last := entries[page.Limit-1]
+	return entries, &EntryCursor{WatchedDate: last.WatchedDate, ID: last.ID, Rating: last.Rating}, nil
+}
+
+// CountDiaryEntriesFiltered returns the number of diary entries matching filter.
+func (db *DB) CountDiaryEntriesFiltered(ctx context.Context, filter models.EntryFilter) (int, error) {
+	conditions, args := entryFilterConditions(filter)
+	var count int
+	err := db.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM diary_entries e
+		JOIN movies m ON m.id = e.movie_id
+		`+whereClause(conditions), args...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("counting diary entries: %w", err)
+	}
+	return count, nil
+}
+
+// entryFilterConditions returns the conditions selecting the entries that match filter,
+// along with their arguments, for a query joining diary_entries e with movies m.
+func entryFilterConditions(filter models.EntryFilter) ([]string, []any) {
+	var (
+		conditions []string
+		args       []any
+	)
+	if minRating, err := strconv.Atoi(filter.MinRating); err == nil {
+		conditions = append(conditions, "e.rating >= ?")
+		args = append(args, minRating)
+	}
+	if filter.Genre != "" {
+		// genres.name is compared ignoring case by its collation
+		conditions = append(conditions, `(m.genre = ? COLLATE NOCASE OR EXISTS (
+			SELECT 1 FROM movie_genres mg
+			JOIN genres g ON g.id = mg.genre_id
+			WHERE mg.movie_id = m.id AND g.name = ?
+		))`)
+		args = append(args, filter.Genre, filter.Genre)
+	}
+	if filter.Format != "" {
+		conditions = append(conditions, "e.format = ? COLLATE NOCASE")
+		args = append(args, filter.Format)
+	}
+	switch decade, err := strconv.Atoi(filter.Decade); {
+	case filter.Decade == models.DecadeUnknown:
+		conditions = append(conditions, "COALESCE(m.year, 0) = 0")
+	case err == nil:
+		conditions = append(conditions, "m.year >= ? AND m.year < ?")
+		args = append(args, decade, decade+10)
+	}
+	if !filter.WatchedFrom.IsZero() {
+		conditions = append(conditions, "e.watched_at >= ?")
+		args = append(args, filter.WatchedFrom.Format(dateLayout))
+	}
+	if !filter.WatchedTo.IsZero() {
+		conditions = append(conditions, "e.watched_at < ?")
+		args = append(args, filter.WatchedTo.Format(dateLayout))
+	}
+	return conditions, args
+}
+
+// whereClause joins conditions into a WHERE clause, which is empty without conditions.
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(conditions, " AND ")
+}
+
+// listDiaryEntries lists the diary entries selected by clauses, the query's WHERE and
+// ORDER BY clauses.
+func (db *DB) listDiaryEntries(ctx context.Context, clauses string, args ...any) ([]models.DiaryEntry, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+entryColumns+`, COALESCE(lc.count, 0)
+		FROM diary_entries e
+		JOIN movies m ON m.id = e.movie_id
+		LEFT JOIN (
+			SELECT diary_entry_id, COUNT(*) AS count
+			FROM lookups
+			GROUP BY diary_entry_id
+		) lc ON lc.diary_entry_id = e.id
+		`+clauses, args...)
+	if err != nil {
+		return nil, fmt.Errorf("listing diary entries: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var entries []models.DiaryEntry
+	for rows.Next() {
+		var lookupCount int
+		entry, err := scanEntry(rows, &lookupCount)
+		if err != nil {
+			return nil, fmt.Errorf("scanning diary entry: %w", err)
+		}
+		entry.LookupCount = lookupCount
+		entries = append(entries, *entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// UpdateDiaryEntry replaces the details of an existing diary entry. The slug is kept so
+// shared links stay valid. A new movie in the input is saved in the same transaction.
+// Out-of-range values are reported as a ValidationError, whether
+// caught up front or by the schema's CHECK constraints.
+func (db *DB) UpdateDiaryEntry(ctx context.Context, id int64, input models.DiaryEntryInput) error {
+	if err := input.Validate(); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidInput, err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if input.NewMovie != nil {
+		if _, err := resolveEntryMovie(ctx, tx, &input); err != nil {
+			return err
+		}
+	}
+
+	format, err := canonicalFormat(ctx, tx, input.Format, id)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE diary_entries
+		SET movie_id = ?, watched_at = ?, watched_location = ?, format = ?, rating = ?, notes = ?, watched_with = ?,
+			updated_at = ?
+		WHERE id = ?`
+	args := []any{
+		input.MovieID, input.WatchedAt.Format(dateLayout), input.Location, format, nullableRating(input.Rating),
+		input.Notes, input.WatchedWith, time.Now().UTC().Format(updatedAtLayout), id,
+	}
+	if !input.UpdatedAt.IsZero() {
+		// Entries created before updated_at existed fall back to their creation time
+		query += " AND COALESCE(updated_at, created_at) = ?"
+		args = append(args, input.UpdatedAt.UTC().Format(updatedAtLayout))
+	}
+
+	result, err := tx.ExecContext(ctx, query, args...)
+	switch {
+	case isConstraintError(err, sqlite3.SQLITE_CONSTRAINT_CHECK):
+		return fmt.Errorf("%w: %w", ErrInvalidInput, checkViolation(err))
+	case isConstraintError(err, sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY):
+		return fmt.Errorf("movie %d: %w", input.MovieID, ErrNotFound)
+	case err != nil:
+		return fmt.Errorf("updating diary entry: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("checking update result: %w", err)
+	}
+	if n == 0 {
+		var exists bool
+		err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM diary_entries WHERE id = ?)", id).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("checking diary entry: %w", err)
+		}
+		if exists {
+			return fmt.Errorf("diary entry %d: %w", id, ErrConflict)
+		}
+		return fmt.Errorf("diary entry %d: %w", id, ErrNotFound)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	newMovieSaved(input)
+	return nil
+}
+
+// ListViewings returns every diary entry for the movie, most recent first.
+func (db *DB) ListViewings(ctx context.Context, movieID int64) ([]models.DiaryEntry, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+entryColumns+`
+		FROM diary_entries e
+		JOIN movies m ON m.id = e.movie_id
+		WHERE e.movie_id = ?
+		ORDER BY e.watched_at DESC, e.id DESC
+	`, movieID)
+	if err != nil {
+		return nil, fmt.Errorf("listing viewings: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var entries []models.DiaryEntry
+	for rows.Next() {
+		entry, err := scanEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning viewing: %w", err)
+		}
+		entries = append(entries, *entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// RewatchCandidates returns the latest viewing of each movie last rated at least minRating
+// and not watched for olderThan, best rated and longest unwatched first.
+func (db *DB) RewatchCandidates(ctx context.Context, minRating int, olderThan time.Duration, limit int) ([]models.DiaryEntry, error) {
+	cutoff := time.Now().Add(-olderThan).Format(dateLayout)
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+entryColumns+`
+		FROM diary_entries e
+		JOIN movies m ON m.id = e.movie_id
+		WHERE e.rating >= ? AND e.watched_at < ?
+			AND NOT EXISTS (
+				SELECT 1 FROM diary_entries newer
+				WHERE newer.movie_id = e.movie_id
+					AND (newer.watched_at > e.watched_at OR (newer.watched_at = e.watched_at AND newer.id > e.id))
+			)
+		ORDER BY e.rating DESC, e.watched_at ASC, e.id ASC
+		LIMIT ?
+	`, minRating, cutoff, limit)
+	if err != nil {
+		return nil, fmt.Errorf("listing rewatch candidates: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var entries []models.DiaryEntry
+	for rows.Next() {
+		entry, err := scanEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning rewatch candidate: %w", err)
+		}
+		entries = append(entries, *entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// IncompleteEntries returns up to limit entries logged without a rating or without notes,
+// most recently watched first. Notes that are only whitespace count as missing.
+func (db *DB) IncompleteEntries(ctx context.Context, limit int) ([]models.DiaryEntry, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+entryColumns+`
+		FROM diary_entries e
+		JOIN movies m ON m.id = e.movie_id
+		WHERE COALESCE(e.rating, 0) = 0 OR trim(COALESCE(e.notes, ''), ' ' || char(9, 10, 13)) = ''
+		ORDER BY e.watched_at DESC, e.id DESC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("listing incomplete entries: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var entries []models.DiaryEntry
+	for rows.Next() {
+		entry, err := scanEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning incomplete entry: %w", err)
+		}
+		entries = append(entries, *entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// DeleteEntries deletes the diary entries with the given IDs in a single transaction
+// and returns how many were deleted. IDs that don't exist are skipped.
+func (db *DB) DeleteEntries(ctx context.Context, ids []int64) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var deleted int64
+	for _, id := range ids {
+		result, err := tx.ExecContext(ctx, "DELETE FROM diary_entries WHERE id = ?", id)
+		if err != nil {
+			return 0, fmt.Errorf("deleting diary entry %d: %w", id, err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("counting deleted entries: %w", err)
+		}
+		deleted += n
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing transaction: %w", err)
+	}
+
+	return int(deleted), nil
+}
+
+// RateEntries gives the diary entries with the given IDs the same rating in a single
+// transaction and returns how many were updated. IDs that don't exist are skipped. The
+// rating must be between 1 and 5.
+func (db *DB) RateEntries(ctx context.Context, ids []int64, rating int) (int, error) {
+	if rating < 1 || rating > 5 {
+		return 0, fmt.Errorf("%w: rating %d must be between 1 and 5", ErrInvalidInput, rating)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now().UTC().Format(updatedAtLayout)
+	var updated int64
+	for _, id := range ids {
+		result, err := tx.ExecContext(ctx,
+			"UPDATE diary_entries SET rating = ?, updated_at = ? WHERE id = ?", rating, now, id)
+		if err != nil {
+			return 0, fmt.Errorf("rating diary entry %d: %w", id, err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("counting rated entries: %w", err)
+		}
+		updated += n
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing transaction: %w", err)
+	}
+
+	return int(updated), nil
+}
+
+// scanEntry scans a row selected with entryColumns, followed by any extra columns into extra.
+func scanEntry(s scanner, extra ...any) (*models.DiaryEntry, error) {
+	entry := &models.DiaryEntry{Movie: &models.Movie{}}
+	var updatedAt sql.NullTime
+	dest := []any{
+		&entry.ID, &entry.MovieID, &entry.WatchedDate, &entry.WatchedLocation, &entry.Format,
+		&entry.Rating, &entry.Notes, &entry.WatchedWith, &entry.Slug, &entry.CreatedAt, &updatedAt,
+		&entry.Movie.ID, &entry.Movie.TMDBID, &entry.Movie.Title, &entry.Movie.Year, &entry.Movie.PosterURL,
+		&entry.Movie.Director, &entry.Movie.Genre, &entry.Movie.Overview,
+	}
+	err := s.Scan(append(dest, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+	entry.UpdatedAt = entry.CreatedAt
+	if updatedAt.Valid {
+		entry.UpdatedAt = updatedAt.Time
+	}
+	return entry, nil
+}
+
+// nullableRating maps the "no rating" zero value to NULL so it passes the rating CHECK constraint.
+func nullableRating(rating int) any {
+	if rating == 0 {
+		return nil
+	}
+	return rating
+}
+
+// newSlug builds a slug from the movie title and watched date, with a short random suffix.
+func newSlug(title string, watchedAt time.Time) (string, error) {
+	suffix := make([]byte, 3)
+	if _, err := io.ReadFull(slugRandom, suffix); err != nil {
+		return "", fmt.Errorf("generating slug suffix: %w", err)
+	}
+	return fmt.Sprintf("%s-%s-%s", slugify(title), watchedAt.Format(dateLayout), hex.EncodeToString(suffix)), nil
+}
+
+// slugify lowercases the title and replaces everything except ASCII letters and digits with dashes.
+func slugify(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+
+	slug := strings.TrimSuffix(b.String(), "-")
+	if slug == "" {
+		return "entry"
+	}
+	return slug
+}
+
+// ListFormats returns the distinct formats entries were watched in, most used first, for
+// suggesting them when logging an entry.
+func (db *DB) ListFormats(ctx context.Context) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT format
+		FROM diary_entries
+		WHERE COALESCE(format, '') != ''
+		GROUP BY format COLLATE NOCASE
+		ORDER BY COUNT(*) DESC, format COLLATE NOCASE
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("listing formats: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var formats []string
+	for rows.Next() {
+		var format string
+		if err := rows.Scan(&format); err != nil {
+			return nil, fmt.Errorf("scanning format: %w", err)
+		}
+		formats = append(formats, format)
+	}
+	return formats, rows.Err()
+}
+
+// canonicalFormat returns the spelling of format already used by another entry than
+// excludeID, ignoring case, so "4k blu-ray" is stored as the "4K Blu-ray" logged before.
+// A format not used yet is returned as is.
+func canonicalFormat(ctx context.Context, q runner, format string, excludeID int64) (string, error) {
+	if format == "" {
+		return "", nil
+	}
+	var existing string
+	err := q.QueryRowContext(ctx, `
+		SELECT format FROM diary_entries
+		WHERE format = ? COLLATE NOCASE AND id != ?
+		ORDER BY id
+		LIMIT 1
+	`, format, excludeID).Scan(&existing)
+	if errors.Is(err, sql.ErrNoRows) {
+		return format, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("finding format: %w", err)
+	}
+	return existing, nil
+}
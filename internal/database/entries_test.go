@@ -0,0 +1,834 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pavelanni/movie-journal/internal/models"
+)
+
+func TestDeleteEntries(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	first := addTestEntry(t, db, 1, "Alien")
+	second := addTestEntry(t, db, 2, "Aliens")
+	kept := addTestEntry(t, db, 3, "Alien 3")
+
+	deleted, err := db.DeleteEntries(ctx, []int64{first, 9999, second, -1})
+	if err != nil {
+		t.Fatalf("DeleteEntries: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("deleted = %d, want 2", deleted)
+	}
+
+	for _, id := range []int64{first, second} {
+		if _, err := db.GetDiaryEntry(ctx, id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("entry %d: err = %v, want ErrNotFound", id, err)
+		}
+	}
+	if _, err := db.GetDiaryEntry(ctx, kept); err != nil {
+		t.Errorf("entry %d not in the list was removed: %v", kept, err)
+	}
+}
+
+func TestRateEntries(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	first := addTestEntry(t, db, 1, "Alien")
+	second := addTestEntry(t, db, 2, "Aliens")
+	kept := addTestEntry(t, db, 3, "Alien 3")
+
+	updated, err := db.RateEntries(ctx, []int64{first, 9999, second}, 2)
+	if err != nil {
+		t.Fatalf("RateEntries: %v", err)
+	}
+	if updated != 2 {
+		t.Errorf("updated = %d, want 2", updated)
+	}
+
+	for id, want := range map[int64]int{first: 2, second: 2, kept: 4} {
+		entry, err := db.GetDiaryEntry(ctx, id)
+		if err != nil {
+			t.Fatalf("getting entry %d: %v", id, err)
+		}
+		if entry.Rating != want {
+			t.Errorf("entry %d rating = %d, want %d", id, entry.Rating, want)
+		}
+	}
+}
+
+func TestRateEntriesOutOfRange(t *testing.T) {
+	db := openTestDB(t)
+	id := addTestEntry(t, db, 1, "Alien")
+
+	for _, rating := range []int{0, 6, -1} {
+		updated, err := db.RateEntries(context.Background(), []int64{id}, rating)
+		if !errors.Is(err, ErrInvalidInput) || updated != 0 {
+			t.Errorf("RateEntries(%d) = %d, %v, want ErrInvalidInput", rating, updated, err)
+		}
+	}
+	entry, err := db.GetDiaryEntry(context.Background(), id)
+	if err != nil {
+		t.Fatalf("getting entry: %v", err)
+	}
+	if entry.Rating != 4 {
+		t.Errorf("rating = %d after rejected ratings, want 4", entry.Rating)
+	}
+}
+
+func TestListDiaryEntriesFiltered(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	alien, err := db.SaveMovie(ctx, models.Movie{TMDBID: 348, Title: "Alien", Year: 1979})
+	if err != nil {
+		t.Fatalf("saving movie: %v", err)
+	}
+	if err := db.SetMovieGenres(ctx, alien.ID, []string{"Horror", "Science Fiction"}); err != nil {
+		t.Fatalf("setting genres: %v", err)
+	}
+	heat, err := db.SaveMovie(ctx, models.Movie{TMDBID: 949, Title: "Heat", Year: 1995, Genre: "Crime"})
+	if err != nil {
+		t.Fatalf("saving movie: %v", err)
+	}
+
+	ids := map[string]int64{}
+	for _, e := range []struct {
+		watched time.Time
+		name    string
+		movieID int64
+		rating  int
+	}{
+		{name: "nov30", movieID: alien.ID, watched: time.Date(2024, time.November, 30, 0, 0, 0, 0, time.UTC), rating: 5},
+		{name: "dec1", movieID: alien.ID, watched: time.Date(2024, time.December, 1, 0, 0, 0, 0, time.UTC), rating: 2},
+		{name: "dec31", movieID: heat.ID, watched: time.Date(2024, time.December, 31, 0, 0, 0, 0, time.UTC), rating: 4},
+		{name: "jan1", movieID: heat.ID, watched: time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)},
+	} {
+		id, err := db.CreateDiaryEntry(ctx, models.DiaryEntryInput{MovieID: e.movieID, WatchedAt: e.watched, Rating: e.rating})
+		if err != nil {
+			t.Fatalf("creating entry: %v", err)
+		}
+		ids[e.name] = id
+	}
+
+	december := time.Date(2024, time.December, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		filter models.EntryFilter
+		name   string
+		want   []string
+	}{
+		{name: "no filter", want: []string{"jan1", "dec31", "dec1", "nov30"}},
+		{
+			name:   "watched in December",
+			filter: models.EntryFilter{WatchedFrom: december, WatchedTo: december.AddDate(0, 1, 0)},
+			want:   []string{"dec31", "dec1"},
+		},
+		{name: "minimum rating", filter: models.EntryFilter{MinRating: "4"}, want: []string{"dec31", "nov30"}},
+		{name: "unparsable minimum rating", filter: models.EntryFilter{MinRating: "x"}, want: []string{"jan1", "dec31", "dec1", "nov30"}},
+		{name: "linked genre ignoring case", filter: models.EntryFilter{Genre: "science fiction"}, want: []string{"dec1", "nov30"}},
+		{name: "legacy genre column", filter: models.EntryFilter{Genre: "crime"}, want: []string{"jan1", "dec31"}},
+		{name: "genre and rating", filter: models.EntryFilter{Genre: "Horror", MinRating: "3"}, want: []string{"nov30"}},
+		{name: "sorted by rating", filter: models.EntryFilter{Sort: models.SortRating}, want: []string{"nov30", "dec31", "dec1", "jan1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := db.ListDiaryEntriesFiltered(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("ListDiaryEntriesFiltered: %v", err)
+			}
+			got := entryIDs(entries)
+			want := make([]int64, len(tt.want))
+			for i, name := range tt.want {
+				want[i] = ids[name]
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("got entries %v, want %v (%v)", got, want, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiaryEntryNewMovie(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	watched := time.Date(2024, time.March, 2, 0, 0, 0, 0, time.UTC)
+
+	movie := &models.Movie{TMDBID: 693134, Title: "Dune: Part Two", Year: 2024}
+	id, err := db.CreateDiaryEntry(ctx, models.DiaryEntryInput{NewMovie: movie, WatchedAt: watched})
+	if err != nil {
+		t.Fatalf("CreateDiaryEntry: %v", err)
+	}
+	entry, err := db.GetDiaryEntry(ctx, id)
+	if err != nil {
+		t.Fatalf("GetDiaryEntry: %v", err)
+	}
+	if movie.ID == 0 || entry.MovieID != movie.ID || entry.Movie.Title != "Dune: Part Two" {
+		t.Errorf("entry is for movie %d %q, want the new movie %d", entry.MovieID, entry.Movie.Title, movie.ID)
+	}
+
+	// A failed update leaves no movie behind
+	orphan := &models.Movie{TMDBID: 1, Title: "Orphan", Year: 2000}
+	err = db.UpdateDiaryEntry(ctx, id+1, models.DiaryEntryInput{NewMovie: orphan, WatchedAt: watched})
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("updating a missing entry: err = %v, want ErrNotFound", err)
+	}
+	if _, err := db.FindMovieByTitle(ctx, "Orphan", 0); !errors.Is(err, ErrNotFound) {
+		t.Errorf("movie saved for a failed update: err = %v, want ErrNotFound", err)
+	}
+	if orphan.ID != 0 {
+		t.Errorf("movie ID = %d after a failed update, want 0", orphan.ID)
+	}
+}
+
+func TestEntryFormats(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	movie, err := db.SaveMovie(ctx, models.Movie{TMDBID: 603, Title: "The Matrix", Year: 1999})
+	if err != nil {
+		t.Fatalf("saving movie: %v", err)
+	}
+	watched := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+	create := func(format string) int64 {
+		t.Helper()
+		id, err := db.CreateDiaryEntry(ctx, models.DiaryEntryInput{MovieID: movie.ID, WatchedAt: watched, Format: format})
+		if err != nil {
+			t.Fatalf("creating entry: %v", err)
+		}
+		return id
+	}
+	bluRay := create("4K Blu-ray")
+	// Typed differently, but stored with the casing logged first
+	bluRayAgain := create("4k blu-ray")
+	cinema := create("Cinema")
+	create("")
+
+	entry, err := db.GetDiaryEntry(ctx, bluRayAgain)
+	if err != nil {
+		t.Fatalf("GetDiaryEntry: %v", err)
+	}
+	if entry.Format != "4K Blu-ray" {
+		t.Errorf("format = %q, want %q", entry.Format, "4K Blu-ray")
+	}
+
+	formats, err := db.ListFormats(ctx)
+	if err != nil {
+		t.Fatalf("ListFormats: %v", err)
+	}
+	if want := []string{"4K Blu-ray", "Cinema"}; !slices.Equal(formats, want) {
+		t.Errorf("formats = %q, want %q, most used first", formats, want)
+	}
+
+	tests := []struct {
+		format string
+		want   []int64
+	}{
+		{format: "4K BLU-RAY", want: []int64{bluRayAgain, bluRay}},
+		{format: "cinema", want: []int64{cinema}},
+		{format: "VHS", want: nil},
+	}
+	for _, tt := range tests {
+		entries, err := db.ListDiaryEntriesFiltered(ctx, models.EntryFilter{Format: tt.format})
+		if err != nil {
+			t.Fatalf("ListDiaryEntriesFiltered: %v", err)
+		}
+		got := entryIDs(entries)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("format %q: got entries %v, want %v", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestListDiaryEntriesPage(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	movie, err := db.SaveMovie(ctx, models.Movie{TMDBID: 105, Title: "Back to the Future", Year: 1985})
+	if err != nil {
+		t.Fatalf("saving movie: %v", err)
+	}
+	// Most entries share a watched date and a rating, so only the ID breaks ties
+	sameDay := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+	for i, e := range []struct {
+		watched time.Time
+		rating  int
+	}{
+		{watched: sameDay.AddDate(0, 0, -1), rating: 3},
+		{watched: sameDay, rating: 3},
+		{watched: sameDay, rating: 5},
+		{watched: sameDay, rating: 3},
+		{watched: sameDay, rating: 3},
+		{watched: sameDay, rating: 3},
+		{watched: sameDay.AddDate(0, 0, 1), rating: 3},
+	} {
+		_, err := db.CreateDiaryEntry(ctx, models.DiaryEntryInput{MovieID: movie.ID, WatchedAt: e.watched, Rating: e.rating})
+		if err != nil {
+			t.Fatalf("creating entry %d: %v", i, err)
+		}
+	}
+
+	for _, sort := range []string{models.SortWatchedDate, models.SortRating} {
+		t.Run(sort, func(t *testing.T) {
+			filter := models.EntryFilter{Sort: sort}
+			all, err := db.ListDiaryEntriesFiltered(ctx, filter)
+			if err != nil {
+				t.Fatalf("ListDiaryEntriesFiltered: %v", err)
+			}
+			want := entryIDs(all)
+
+			// Walking the pages by cursor and by offset yields every entry once, in order
+			var byCursor, byOffset []int64
+			var after *EntryCursor
+			for page := 0; ; page++ {
+				entries, next, err := db.ListDiaryEntriesPage(ctx, filter, EntryPage{After: after, Limit: 3})
+				if err != nil {
+					t.Fatalf("ListDiaryEntriesPage after %v: %v", after, err)
+				}
+				byCursor = append(byCursor, entryIDs(entries)...)
+
+				entries, _, err = db.ListDiaryEntriesPage(ctx, filter, EntryPage{Limit: 3, Offset: page * 3})
+				if err != nil {
+					t.Fatalf("ListDiaryEntriesPage at offset %d: %v", page*3, err)
+				}
+				byOffset = append(byOffset, entryIDs(entries)...)
+
+				if next == nil {
+					break
+				}
+				// The cursor survives a round trip through a URL
+				cursor, err := ParseEntryCursor(next.String())
+				if err != nil {
+					t.Fatalf("ParseEntryCursor(%q): %v", next.String(), err)
+				}
+				after = &cursor
+			}
+			if !slices.Equal(byCursor, want) {
+				t.Errorf("pages by cursor = %v, want %v", byCursor, want)
+			}
+			if !slices.Equal(byOffset, want) {
+				t.Errorf("pages by offset = %v, want %v", byOffset, want)
+			}
+
+			// The same query gives the same order every time
+			again, err := db.ListDiaryEntriesFiltered(ctx, filter)
+			if err != nil {
+				t.Fatalf("ListDiaryEntriesFiltered: %v", err)
+			}
+			if got := entryIDs(again); !slices.Equal(got, want) {
+				t.Errorf("second listing = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseEntryCursorMalformed(t *testing.T) {
+	for _, s := range []string{"", "2024-06-01.3", "June.3.4", "2024-06-01.x.4", "2024-06-01.3.x"} {
+		if _, err := ParseEntryCursor(s); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("ParseEntryCursor(%q): err = %v, want ErrInvalidInput", s, err)
+		}
+	}
+}
+
+// entryIDs returns the IDs of the entries, in order.
+func entryIDs(entries []models.DiaryEntry) []int64 {
+	ids := make([]int64, len(entries))
+	for i := range entries {
+		ids[i] = entries[i].ID
+	}
+	return ids
+}
+
+// addDetailedEntry adds an entry with every field set, for a movie with genres, and
+// lookups lookups, returning the entry as it was inserted.
+func addDetailedEntry(tb testing.TB, db *DB, lookups int) models.DiaryEntry {
+	tb.Helper()
+	ctx := context.Background()
+	movie, err := db.SaveMovie(ctx, models.Movie{
+		TMDBID: 438631, Title: "Dune", Year: 2021, Director: "Denis Villeneuve", Overview: "Spice.",
+	})
+	if err != nil {
+		tb.Fatalf("saving movie: %v", err)
+	}
+	if err := db.SetMovieGenres(ctx, movie.ID, []string{"Science Fiction", "Adventure"}); err != nil {
+		tb.Fatalf("setting genres: %v", err)
+	}
+	want := models.DiaryEntry{
+		MovieID:         movie.ID,
+		WatchedDate:     time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC),
+		WatchedLocation: "Cinema",
+		Format:          "IMAX",
+		Rating:          5,
+		Notes:           "Loud.",
+		WatchedWith:     "Sam",
+	}
+	want.ID, err = db.CreateDiaryEntry(ctx, models.DiaryEntryInput{
+		MovieID:     want.MovieID,
+		WatchedAt:   want.WatchedDate,
+		Location:    want.WatchedLocation,
+		Format:      want.Format,
+		Rating:      want.Rating,
+		Notes:       want.Notes,
+		WatchedWith: want.WatchedWith,
+	})
+	if err != nil {
+		tb.Fatalf("creating entry: %v", err)
+	}
+	inputs := make([]models.LookupInput, lookups)
+	for i := range inputs {
+		inputs[i] = models.LookupInput{
+			Question: fmt.Sprintf("Question %d?", i), Answer: "Answer", Category: models.LookupCategoryTrivia,
+		}
+	}
+	if lookups > 0 {
+		if want.Lookups, err = db.CreateLookups(ctx, want.ID, inputs); err != nil {
+			tb.Fatalf("creating lookups: %v", err)
+		}
+	}
+	return want
+}
+
+func TestGetDiaryEntryAssemblesEntry(t *testing.T) {
+	db := openTestDB(t)
+	want := addDetailedEntry(t, db, 3)
+
+	queries := countQueries()
+	got, err := db.GetDiaryEntry(context.Background(), want.ID)
+	if err != nil {
+		t.Fatalf("GetDiaryEntry: %v", err)
+	}
+	if n := queries(); n != 2 {
+		t.Errorf("GetDiaryEntry ran %d queries, want 2", n)
+	}
+
+	if got.ID != want.ID || got.MovieID != want.MovieID || !got.WatchedDate.Equal(want.WatchedDate) ||
+		got.WatchedLocation != want.WatchedLocation || got.Format != want.Format || got.Rating != want.Rating ||
+		got.Notes != want.Notes || got.WatchedWith != want.WatchedWith || got.Slug == "" {
+		t.Errorf("entry = %+v, want %+v", got, want)
+	}
+	if got.Movie == nil || got.Movie.ID != want.MovieID || got.Movie.Title != "Dune" || got.Movie.Year != 2021 ||
+		got.Movie.Director != "Denis Villeneuve" || !slices.Equal(got.Movie.Genres, []string{"Science Fiction", "Adventure"}) {
+		t.Errorf("movie = %+v, want Dune with its genres in order", got.Movie)
+	}
+	if got.LookupCount != len(want.Lookups) || len(got.Lookups) != len(want.Lookups) {
+		t.Fatalf("entry has %d lookups (count %d), want %d", len(got.Lookups), got.LookupCount, len(want.Lookups))
+	}
+	for i, lookup := range got.Lookups {
+		w := want.Lookups[i]
+		if lookup.ID != w.ID || lookup.DiaryEntryID != want.ID || lookup.Question != w.Question ||
+			lookup.Answer != w.Answer || lookup.Category != w.Category {
+			t.Errorf("lookup %d = %+v, want %+v", i, lookup, w)
+		}
+	}
+}
+
+// getDiaryEntryNaive loads an entry the way GetDiaryEntry avoids: the entry, its movie and
+// the movie's genres one query each, then every lookup on its own.
+func (db *DB) getDiaryEntryNaive(ctx context.Context, id int64) (*models.DiaryEntry, error) {
+	entry := models.DiaryEntry{ID: id}
+	err := db.QueryRowContext(ctx, `
+		SELECT movie_id, watched_at, COALESCE(watched_location, ''), COALESCE(format, ''), COALESCE(rating, 0),
+			COALESCE(notes, ''), COALESCE(watched_with, '')
+		FROM diary_entries WHERE id = ?`, id).Scan(&entry.MovieID, &entry.WatchedDate,
+		&entry.WatchedLocation, &entry.Format, &entry.Rating, &entry.Notes, &entry.WatchedWith)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Movie, err = db.GetMovie(ctx, entry.MovieID); err != nil {
+		return nil, err
+	}
+	if entry.Movie.Genres, err = db.movieGenres(ctx, entry.MovieID); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT id FROM lookups WHERE diary_entry_id = ? ORDER BY position, id", id)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var lookupID int64
+		if err := rows.Scan(&lookupID); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		ids = append(ids, lookupID)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	for _, lookupID := range ids {
+		lookups, err := db.queryLookups(ctx, `
+			SELECT id, diary_entry_id, question, COALESCE(answer, ''), category, COALESCE(url, ''), created_at
+			FROM lookups WHERE id = ?`, lookupID)
+		if err != nil {
+			return nil, err
+		}
+		entry.Lookups = append(entry.Lookups, lookups...)
+	}
+	return &entry, nil
+}
+
+// BenchmarkGetDiaryEntry loads an entry with 20 lookups in two queries, and the naive way
+// with a query per lookup, reporting the queries each takes.
+func BenchmarkGetDiaryEntry(b *testing.B) {
+	benchmarks := []struct {
+		get  func(*DB, context.Context, int64) (*models.DiaryEntry, error)
+		name string
+	}{
+		{name: "joined", get: (*DB).GetDiaryEntry},
+		{name: "naive", get: (*DB).getDiaryEntryNaive},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			ctx := context.Background()
+			db, err := Open(filepath.Join(b.TempDir(), "bench.db"))
+			if err != nil {
+				b.Fatalf("opening database: %v", err)
+			}
+			defer func() { _ = db.Close() }()
+			id := addDetailedEntry(b, db, 20).ID
+
+			queries := countQueries()
+			for b.Loop() {
+				if _, err := bm.get(db, ctx, id); err != nil {
+					b.Fatalf("getting entry: %v", err)
+				}
+			}
+			b.ReportMetric(float64(queries())/float64(b.N), "queries/op")
+		})
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{title: "Dune", want: "dune"},
+		{title: "Dune: Part Two", want: "dune-part-two"},
+		{title: "  Crouching Tiger, Hidden Dragon! ", want: "crouching-tiger-hidden-dragon"},
+		{title: "Amélie", want: "am-lie"},
+		{title: "2001: A Space Odyssey", want: "2001-a-space-odyssey"},
+		{title: "千と千尋の神隠し", want: "entry"},
+		{title: "", want: "entry"},
+	}
+
+	for _, tt := range tests {
+		if got := slugify(tt.title); got != tt.want {
+			t.Errorf("slugify(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+// addSameDayEntry logs another viewing of the movie on the same day as the others.
+func addSameDayEntry(t *testing.T, db *DB, movieID int64) (int64, error) {
+	t.Helper()
+	return db.CreateDiaryEntry(context.Background(), models.DiaryEntryInput{
+		MovieID:   movieID,
+		WatchedAt: time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC),
+	})
+}
+
+func TestSlugsUniqueForSameTitleAndDate(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	movie, err := db.SaveMovie(ctx, models.Movie{TMDBID: 438631, Title: "Dune", Year: 2021})
+	if err != nil {
+		t.Fatalf("saving movie: %v", err)
+	}
+
+	pattern := regexp.MustCompile(`^dune-2024-06-01-[0-9a-f]{6}$`)
+	seen := make(map[string]int64)
+	for range 20 {
+		id, err := addSameDayEntry(t, db, movie.ID)
+		if err != nil {
+			t.Fatalf("creating entry: %v", err)
+		}
+		entry, err := db.GetDiaryEntry(ctx, id)
+		if err != nil {
+			t.Fatalf("getting entry: %v", err)
+		}
+		if !pattern.MatchString(entry.Slug) {
+			t.Errorf("slug %q doesn't match %s", entry.Slug, pattern)
+		}
+		if other, ok := seen[entry.Slug]; ok {
+			t.Errorf("entries %d and %d share the slug %q", other, id, entry.Slug)
+		}
+		seen[entry.Slug] = id
+
+		bySlug, err := db.GetDiaryEntryBySlug(ctx, entry.Slug)
+		if err != nil || bySlug.ID != id {
+			t.Errorf("GetDiaryEntryBySlug(%q) = %v, %v, want entry %d", entry.Slug, bySlug, err, id)
+		}
+	}
+}
+
+func TestSlugCollisionRegeneratesSuffix(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	movie, err := db.SaveMovie(ctx, models.Movie{TMDBID: 438631, Title: "Dune", Year: 2021})
+	if err != nil {
+		t.Fatalf("saving movie: %v", err)
+	}
+	previous := slugRandom
+	t.Cleanup(func() { slugRandom = previous })
+
+	// The second entry draws the first one's suffix, then a fresh one
+	slugRandom = bytes.NewReader([]byte{0xaa, 0xaa, 0xaa, 0xaa, 0xaa, 0xaa, 0xbb, 0xbb, 0xbb})
+	for range 2 {
+		if _, err := addSameDayEntry(t, db, movie.ID); err != nil {
+			t.Fatalf("creating entry: %v", err)
+		}
+	}
+	for _, slug := range []string{"dune-2024-06-01-aaaaaa", "dune-2024-06-01-bbbbbb"} {
+		if _, err := db.GetDiaryEntryBySlug(ctx, slug); err != nil {
+			t.Errorf("GetDiaryEntryBySlug(%q): %v", slug, err)
+		}
+	}
+
+	// Giving up after every attempt collides
+	slugRandom = bytes.NewReader(bytes.Repeat([]byte{0xaa}, 3*maxSlugAttempts))
+	_, err = addSameDayEntry(t, db, movie.ID)
+	if err == nil || !strings.Contains(err.Error(), "attempts collided") {
+		t.Errorf("err = %v, want slug generation to give up", err)
+	}
+}
+
+func TestRewatchCandidates(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	now := time.Now()
+	longAgo := now.AddDate(-3, 0, 0)
+	recently := now.AddDate(0, -1, 0)
+
+	// Each movie's viewings, oldest first, with their ratings
+	viewings := []struct {
+		title   string
+		watched []time.Time
+		ratings []int
+	}{
+		{title: "Heat", watched: []time.Time{longAgo}, ratings: []int{5}},
+		{title: "Alien", watched: []time.Time{longAgo.AddDate(-1, 0, 0)}, ratings: []int{5}},
+		{title: "Arrival", watched: []time.Time{longAgo}, ratings: []int{4}},
+		// A favorite watched again recently isn't stale
+		{title: "Dune", watched: []time.Time{longAgo, recently}, ratings: []int{5, 5}},
+		// The latest rating counts, not the best one
+		{title: "Tenet", watched: []time.Time{longAgo.AddDate(-1, 0, 0), longAgo}, ratings: []int{5, 2}},
+		{title: "Cats", watched: []time.Time{longAgo}, ratings: []int{1}},
+		{title: "Unrated", watched: []time.Time{longAgo}, ratings: []int{0}},
+		{title: "Barbie", watched: []time.Time{recently}, ratings: []int{5}},
+	}
+	latest := make(map[string]int64)
+	for i, v := range viewings {
+		movie, err := db.SaveMovie(ctx, models.Movie{TMDBID: i + 1, Title: v.title, Year: 2000})
+		if err != nil {
+			t.Fatalf("saving movie: %v", err)
+		}
+		for j, watched := range v.watched {
+			id, err := db.CreateDiaryEntry(ctx, models.DiaryEntryInput{
+				MovieID: movie.ID, WatchedAt: watched, Rating: v.ratings[j],
+			})
+			if err != nil {
+				t.Fatalf("creating entry: %v", err)
+			}
+			latest[v.title] = id
+		}
+	}
+	year := 365 * 24 * time.Hour
+
+	tests := []struct {
+		name      string
+		want      []string
+		minRating int
+		limit     int
+	}{
+		{name: "stale favorites", minRating: 5, limit: 10, want: []string{"Alien", "Heat"}},
+		{name: "lower bar", minRating: 4, limit: 10, want: []string{"Alien", "Heat", "Arrival"}},
+		{name: "limited", minRating: 4, limit: 1, want: []string{"Alien"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := db.RewatchCandidates(ctx, tt.minRating, year, tt.limit)
+			if err != nil {
+				t.Fatalf("RewatchCandidates: %v", err)
+			}
+			want := make([]int64, len(tt.want))
+			for i, title := range tt.want {
+				want[i] = latest[title]
+			}
+			if ids := entryIDs(got); !slices.Equal(ids, want) {
+				t.Errorf("candidates = %v, want %v (%v)", ids, want, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewatchCandidatesEmpty(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	got, err := db.RewatchCandidates(ctx, 5, time.Hour, 3)
+	if err != nil {
+		t.Fatalf("RewatchCandidates on an empty diary: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("got %d candidates from an empty diary, want none", len(got))
+	}
+}
+
+func TestIncompleteEntries(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	// Entries are watched a day apart, in order, so the latest comes first
+	entries := []struct {
+		title      string
+		notes      string
+		rating     int
+		incomplete bool
+	}{
+		{title: "Heat", notes: "The diner scene.", rating: 5},
+		{title: "Alien", notes: "Tense.", incomplete: true},
+		{title: "Arrival", rating: 4, incomplete: true},
+		{title: "Dune", notes: "  \n\t", rating: 3, incomplete: true},
+		{title: "Cats", incomplete: true},
+		{title: "Tenet", notes: "Backwards.", rating: 2},
+	}
+	var want []int64
+	for i, e := range entries {
+		movie, err := db.SaveMovie(ctx, models.Movie{TMDBID: i + 1, Title: e.title, Year: 2000})
+		if err != nil {
+			t.Fatalf("saving movie: %v", err)
+		}
+		id, err := db.CreateDiaryEntry(ctx, models.DiaryEntryInput{
+			MovieID:   movie.ID,
+			WatchedAt: time.Date(2024, time.June, 1+i, 0, 0, 0, 0, time.UTC),
+			Rating:    e.rating,
+			Notes:     e.notes,
+		})
+		if err != nil {
+			t.Fatalf("creating entry: %v", err)
+		}
+		if e.incomplete {
+			want = append([]int64{id}, want...)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		want  []int64
+		limit int
+	}{
+		{name: "all", limit: 10, want: want},
+		{name: "limited", limit: 2, want: want[:2]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := db.IncompleteEntries(ctx, tt.limit)
+			if err != nil {
+				t.Fatalf("IncompleteEntries: %v", err)
+			}
+			if ids := entryIDs(got); !slices.Equal(ids, tt.want) {
+				t.Errorf("incomplete entries = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestIncompleteEntriesAllComplete(t *testing.T) {
+	db := openTestDB(t)
+	id := addTestEntry(t, db, 438631, "Dune")
+	entry, err := db.GetDiaryEntry(context.Background(), id)
+	if err != nil {
+		t.Fatalf("getting entry: %v", err)
+	}
+	if err := db.UpdateDiaryEntry(context.Background(), id, models.DiaryEntryInput{
+		MovieID: entry.MovieID, WatchedAt: time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC), Rating: 4, Notes: "Spice.",
+	}); err != nil {
+		t.Fatalf("updating entry: %v", err)
+	}
+
+	got, err := db.IncompleteEntries(context.Background(), 5)
+	if err != nil {
+		t.Fatalf("IncompleteEntries: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("incomplete entries = %v, want none", entryIDs(got))
+	}
+}
+
+func TestUpdateDiaryEntryRatingOutOfRange(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	id := addTestEntry(t, db, 438631, "Dune")
+	entry, err := db.GetDiaryEntry(ctx, id)
+	if err != nil {
+		t.Fatalf("getting entry: %v", err)
+	}
+
+	err = db.UpdateDiaryEntry(ctx, id, models.DiaryEntryInput{
+		MovieID: entry.MovieID, WatchedAt: entry.WatchedDate, Rating: 7,
+	})
+
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("err = %v, want ErrInvalidInput", err)
+	}
+	var verr *models.ValidationError
+	if !errors.As(err, &verr) || verr.Fields["rating"] == "" {
+		t.Errorf("err = %v, want a ValidationError for rating", err)
+	}
+}
+
+func TestUpdateDiaryEntryOptimisticLocking(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	id := addTestEntry(t, db, 438631, "Dune")
+	// Both tabs load the entry at the same version
+	loaded, err := db.GetDiaryEntry(ctx, id)
+	if err != nil {
+		t.Fatalf("getting entry: %v", err)
+	}
+	edit := func(rating int, version time.Time) error {
+		return db.UpdateDiaryEntry(ctx, id, models.DiaryEntryInput{
+			MovieID: loaded.MovieID, WatchedAt: loaded.WatchedDate, Rating: rating, UpdatedAt: version,
+		})
+	}
+
+	if err := edit(5, loaded.UpdatedAt); err != nil {
+		t.Fatalf("fresh update: %v", err)
+	}
+	if err := edit(1, loaded.UpdatedAt); !errors.Is(err, ErrConflict) {
+		t.Errorf("stale update = %v, want ErrConflict", err)
+	}
+
+	current, err := db.GetDiaryEntry(ctx, id)
+	if err != nil {
+		t.Fatalf("getting entry: %v", err)
+	}
+	if current.Rating != 5 {
+		t.Errorf("rating = %d, want the first edit's 5 kept", current.Rating)
+	}
+	if !current.UpdatedAt.After(loaded.UpdatedAt) {
+		t.Errorf("updated_at = %v, want it moved past %v", current.UpdatedAt, loaded.UpdatedAt)
+	}
+
+	// Saving again against the current version goes through
+	if err := edit(2, current.UpdatedAt); err != nil {
+		t.Errorf("update at the current version: %v", err)
+	}
+	// A missing entry isn't a conflict
+	err = db.UpdateDiaryEntry(ctx, 999, models.DiaryEntryInput{
+		MovieID: loaded.MovieID, WatchedAt: loaded.WatchedDate, UpdatedAt: loaded.UpdatedAt,
+	})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("update of a missing entry = %v, want ErrNotFound", err)
+	}
+}
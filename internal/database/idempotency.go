@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pavelanni/movie-journal/internal/models"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// CreateDiaryEntryOnce creates a diary entry like CreateDiaryEntry, unless the request
+// with the given idempotency key already created one since notBefore. It returns the ID of
+// the entry and whether this call created it. The key is claimed in the same transaction
+// as the insert, with its primary key as the gate, so concurrent retries create a single
+// entry between them. A key recorded before notBefore has expired and is claimed afresh.
+func (db *DB) CreateDiaryEntryOnce(
+	ctx context.Context, key string, notBefore time.Time, input models.DiaryEntryInput,
+) (int64, bool, error) {
+	if err := input.Validate(); err != nil {
+		return 0, false, fmt.Errorf("%w: %w", ErrInvalidInput, err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, false, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Writing first takes the write lock, so a concurrent retry waits here and then sees
+	// the key this one claims
+	_, err = tx.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE key = ? AND created_at < ?",
+		key, notBefore.UTC().Format(timestampLayout))
+	if err != nil {
+		return 0, false, fmt.Errorf("deleting expired idempotency key: %w", err)
+	}
+
+	id, err := idempotentEntry(ctx, tx, key, notBefore)
+	if err == nil {
+		return id, false, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return 0, false, err
+	}
+
+	id, err = createDiaryEntry(ctx, tx, &input)
+	if err != nil {
+		return 0, false, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO idempotency_keys (key, entry_id, created_at) VALUES (?, ?, ?)
+	`, key, id, time.Now().UTC().Format(timestampLayout))
+	if isConstraintError(err, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY) {
+		// Another request claimed the key first; drop this entry and return that one's
+		_ = tx.Rollback()
+		id, err = db.IdempotentEntry(ctx, key, notBefore)
+		return id, false, err
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("saving idempotency key: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, false, fmt.Errorf("committing transaction: %w", err)
+	}
+	newMovieSaved(input)
+	return id, true, nil
+}
+
+// IdempotentEntry returns the ID of the entry created for the key. Keys recorded before
+// notBefore count as expired. It returns ErrNotFound when the key is unknown or expired.
+func (db *DB) IdempotentEntry(ctx context.Context, key string, notBefore time.Time) (int64, error) {
+	return idempotentEntry(ctx, db, key, notBefore)
+}
+
+// idempotentEntry looks up the entry created for the key using q, which may be a
+// transaction.
+func idempotentEntry(ctx context.Context, q runner, key string, notBefore time.Time) (int64, error) {
+	var entryID int64
+	err := q.QueryRowContext(ctx, `
+		SELECT entry_id FROM idempotency_keys WHERE key = ? AND created_at >= ?
+	`, key, notBefore.UTC().Format(timestampLayout)).Scan(&entryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("getting idempotency key: %w", err)
+	}
+	return entryID, nil
+}
+
+// DeleteIdempotencyKeysBefore removes keys recorded before the cutoff and returns how many were removed.
+func (db *DB) DeleteIdempotencyKeysBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE created_at < ?", cutoff.UTC().Format(timestampLayout))
+	if err != nil {
+		return 0, fmt.Errorf("deleting expired idempotency keys: %w", err)
+	}
+	return result.RowsAffected()
+}
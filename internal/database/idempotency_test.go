@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pavelanni/movie-journal/internal/models"
+)
+
+func TestCreateDiaryEntryOnce(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	movie, err := db.SaveMovie(ctx, models.Movie{TMDBID: 1, Title: "Alien", Year: 1979})
+	if err != nil {
+		t.Fatalf("saving movie: %v", err)
+	}
+	input := models.DiaryEntryInput{MovieID: movie.ID, WatchedAt: time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)}
+	notBefore := time.Now().Add(-time.Hour)
+
+	first, created, err := db.CreateDiaryEntryOnce(ctx, "key-1", notBefore, input)
+	if err != nil || !created {
+		t.Fatalf("first call = %d, %v, %v, want a new entry", first, created, err)
+	}
+
+	replayed, created, err := db.CreateDiaryEntryOnce(ctx, "key-1", notBefore, input)
+	if err != nil {
+		t.Fatalf("repeated key: %v", err)
+	}
+	if created || replayed != first {
+		t.Errorf("repeated key = %d, created %v, want entry %d replayed", replayed, created, first)
+	}
+
+	other, created, err := db.CreateDiaryEntryOnce(ctx, "key-2", notBefore, input)
+	if err != nil {
+		t.Fatalf("new key: %v", err)
+	}
+	if !created || other == first {
+		t.Errorf("new key = %d, created %v, want a new entry", other, created)
+	}
+
+	// Once the key has expired, it no longer stands for the first entry
+	renewed, created, err := db.CreateDiaryEntryOnce(ctx, "key-1", time.Now().Add(time.Hour), input)
+	if err != nil {
+		t.Fatalf("expired key: %v", err)
+	}
+	if !created || renewed == first || renewed == other {
+		t.Errorf("expired key = %d, created %v, want a new entry", renewed, created)
+	}
+	if id, err := db.IdempotentEntry(ctx, "key-1", notBefore); err != nil || id != renewed {
+		t.Errorf("expired key now stands for %d (%v), want %d", id, err, renewed)
+	}
+}
+
+func TestCreateDiaryEntryOnceConcurrent(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	movie, err := db.SaveMovie(ctx, models.Movie{TMDBID: 1, Title: "Alien", Year: 1979})
+	if err != nil {
+		t.Fatalf("saving movie: %v", err)
+	}
+	input := models.DiaryEntryInput{MovieID: movie.ID, WatchedAt: time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)}
+
+	const retries = 8
+	ids := make([]int64, retries)
+	errs := make([]error, retries)
+	var wg sync.WaitGroup
+	for i := range retries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ids[i], _, errs[i] = db.CreateDiaryEntryOnce(ctx, "retried", time.Now().Add(-time.Hour), input)
+		}()
+	}
+	wg.Wait()
+
+	for i := range retries {
+		if errs[i] != nil {
+			t.Fatalf("retry %d: %v", i, errs[i])
+		}
+		if ids[i] != ids[0] {
+			t.Errorf("retry %d got entry %d, want %d like the others", i, ids[i], ids[0])
+		}
+	}
+	if count, err := db.CountDiaryEntries(ctx); err != nil || count != 1 {
+		t.Errorf("diary has %d entries (%v), want 1", count, err)
+	}
+}
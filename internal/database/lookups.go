@@ -0,0 +1,523 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pavelanni/movie-journal/internal/models"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// CreateLookup inserts a new lookup for a diary entry, after its existing lookups, and
+// returns it.
+func (db *DB) CreateLookup(ctx context.Context, input models.LookupInput) (*models.Lookup, error) {
+	input, err := normalizeLookupInput(input)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := db.ExecContext(ctx, `
+		INSERT INTO lookups (diary_entry_id, question, answer, category, url, position)
+		VALUES (?1, ?2, ?3, ?4, ?5, (SELECT COALESCE(MAX(position), -1) + 1 FROM lookups WHERE diary_entry_id = ?1))
+	`, input.DiaryEntryID, input.Question, input.Answer, input.Category, input.URL)
+	if isConstraintError(err, sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY) {
+		return nil, fmt.Errorf("diary entry %d: %w", input.DiaryEntryID, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("inserting lookup: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("getting lookup ID: %w", err)
+	}
+
+	return db.GetLookup(ctx, id)
+}
+
+// BatchError reports which item of a batch was rejected. It wraps the item's error.
+type BatchError struct {
+	Err   error
+	Index int
+}
+
+// Error names the failing item by its zero-based index.
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("item %d: %v", e.Index, e.Err)
+}
+
+// Unwrap returns the item's error.
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// CreateLookups inserts several lookups for a diary entry in one transaction and returns
+// them in order, after the entry's existing lookups. Every input is validated first; if
+// any is invalid, nothing is inserted and the error is a *BatchError naming it.
+func (db *DB) CreateLookups(ctx context.Context, entryID int64, inputs []models.LookupInput) ([]models.Lookup, error) {
+	for i := range inputs {
+		inputs[i].DiaryEntryID = entryID
+		normalized, err := normalizeLookupInput(inputs[i])
+		if err != nil {
+			return nil, &BatchError{Index: i, Err: err}
+		}
+		inputs[i] = normalized
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	ids := make([]int64, len(inputs))
+	for i, input := range inputs {
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO lookups (diary_entry_id, question, answer, category, url, position)
+			VALUES (?1, ?2, ?3, ?4, ?5, (SELECT COALESCE(MAX(position), -1) + 1 FROM lookups WHERE diary_entry_id = ?1))
+		`, input.DiaryEntryID, input.Question, input.Answer, input.Category, input.URL)
+		if isConstraintError(err, sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY) {
+			return nil, fmt.Errorf("diary entry %d: %w", entryID, ErrNotFound)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("inserting lookup %d: %w", i, err)
+		}
+		if ids[i], err = result.LastInsertId(); err != nil {
+			return nil, fmt.Errorf("getting lookup ID: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing transaction: %w", err)
+	}
+
+	lookups := make([]models.Lookup, 0, len(ids))
+	for _, id := range ids {
+		lookup, err := db.GetLookup(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		lookups = append(lookups, *lookup)
+	}
+	return lookups, nil
+}
+
+// UpdateLookup replaces the question, answer, category and URL of a lookup.
+func (db *DB) UpdateLookup(ctx context.Context, id int64, input models.LookupInput) error {
+	input, err := normalizeLookupInput(input)
+	if err != nil {
+		return err
+	}
+
+	result, err := db.ExecContext(ctx, `
+		UPDATE lookups SET question = ?1, answer = ?2, category = ?3, url = ?4,
+			link_ok = CASE WHEN url IS ?4 THEN link_ok END
+		WHERE id = ?5
+	`, input.Question, input.Answer, input.Category, input.URL, id)
+	if err != nil {
+		return fmt.Errorf("updating lookup: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("counting updated lookups: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("lookup %d: %w", id, ErrNotFound)
+	}
+
+	return nil
+}
+
+// ReorderLookups puts a diary entry's lookups in the given order. orderedIDs must list
+// each of the entry's lookups exactly once; otherwise nothing changes and the error wraps
+// ErrInvalidInput.
+func (db *DB) ReorderLookups(ctx context.Context, entryID int64, orderedIDs []int64) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM lookups WHERE diary_entry_id = ?", entryID)
+	if err != nil {
+		return fmt.Errorf("listing lookup IDs: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	unplaced := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return fmt.Errorf("scanning lookup ID: %w", err)
+		}
+		unplaced[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterating lookup IDs: %w", err)
+	}
+
+	if len(unplaced) == 0 {
+		var exists bool
+		err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM diary_entries WHERE id = ?)`, entryID).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("checking diary entry: %w", err)
+		}
+		if !exists {
+			return fmt.Errorf("diary entry %d: %w", entryID, ErrNotFound)
+		}
+	}
+	if len(orderedIDs) != len(unplaced) {
+		return fmt.Errorf("%w: got %d lookups, entry %d has %d", ErrInvalidInput, len(orderedIDs), entryID, len(unplaced))
+	}
+
+	for i, id := range orderedIDs {
+		if !unplaced[id] {
+			return fmt.Errorf("%w: lookup %d is not on entry %d or is listed twice", ErrInvalidInput, id, entryID)
+		}
+		delete(unplaced, id)
+		if _, err := tx.ExecContext(ctx, "UPDATE lookups SET position = ? WHERE id = ?", i, id); err != nil {
+			return fmt.Errorf("moving lookup %d: %w", id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	return nil
+}
+
+// GetLookup returns the lookup with the given ID.
+func (db *DB) GetLookup(ctx context.Context, id int64) (*models.Lookup, error) {
+	var l models.Lookup
+	err := db.QueryRowContext(ctx, `
+		SELECT id, diary_entry_id, question, COALESCE(answer, ''), category, COALESCE(url, ''), created_at
+		FROM lookups
+		WHERE id = ?
+	`, id).Scan(&l.ID, &l.DiaryEntryID, &l.Question, &l.Answer, &l.Category, &l.URL, &l.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("lookup %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting lookup: %w", err)
+	}
+	return &l, nil
+}
+
+// listLookups returns the lookups recorded for a diary entry, in their chosen order.
+func (db *DB) listLookups(ctx context.Context, entryID int64) ([]models.Lookup, error) {
+	lookups, err := db.queryLookups(ctx, `
+		SELECT id, diary_entry_id, question, COALESCE(answer, ''), category, COALESCE(url, ''), created_at
+		FROM lookups
+		WHERE diary_entry_id = ?
+		ORDER BY position, id
+	`, entryID)
+	if err != nil {
+		return nil, fmt.Errorf("listing lookups: %w", err)
+	}
+	return lookups, nil
+}
+
+// SearchLookups returns the lookups recorded for a diary entry whose question or answer
+// contains query, ignoring case. An empty query matches every lookup.
+func (db *DB) SearchLookups(ctx context.Context, entryID int64, query string) ([]models.Lookup, error) {
+	var lookups []models.Lookup
+	var err error
+	if query = strings.TrimSpace(query); query == "" {
+		lookups, err = db.listLookups(ctx, entryID)
+	} else {
+		lookups, err = db.queryLookups(ctx, `
+			SELECT id, diary_entry_id, question, COALESCE(answer, ''), category, COALESCE(url, ''), created_at
+			FROM lookups
+			WHERE diary_entry_id = ?1
+				AND (instr(lower(question), lower(?2)) > 0 OR instr(lower(COALESCE(answer, '')), lower(?2)) > 0)
+			ORDER BY position, id
+		`, entryID, query)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("searching lookups: %w", err)
+	}
+	if len(lookups) > 0 {
+		return lookups, nil
+	}
+
+	// No matches; tell a missing entry apart from an entry without matching lookups
+	var exists bool
+	err = db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM diary_entries WHERE id = ?)`, entryID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("checking diary entry: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("diary entry %d: %w", entryID, ErrNotFound)
+	}
+	return lookups, nil
+}
+
+// UnansweredLookups returns the lookups recorded for a diary entry that have no answer yet,
+// in their chosen order. An answer of only whitespace counts as none.
+func (db *DB) UnansweredLookups(ctx context.Context, entryID int64) ([]models.Lookup, error) {
+	lookups, err := db.queryLookups(ctx, `
+		SELECT id, diary_entry_id, question, COALESCE(answer, ''), category, COALESCE(url, ''), created_at
+		FROM lookups
+		WHERE diary_entry_id = ? AND trim(COALESCE(answer, ''), ' ' || char(9, 10, 13)) = ''
+		ORDER BY position, id
+	`, entryID)
+	if err != nil {
+		return nil, fmt.Errorf("listing unanswered lookups: %w", err)
+	}
+	return lookups, nil
+}
+
+// ListLookupsByMovie returns the lookups from every viewing of a movie, oldest first.
+// A question asked on several viewings appears once, with its most recent answer;
+// questions are compared ignoring case and surrounding whitespace.
+func (db *DB) ListLookupsByMovie(ctx context.Context, movieID int64) ([]models.Lookup, error) {
+	lookups, err := db.queryLookups(ctx, `
+		SELECT id, diary_entry_id, question, answer, category, url, created_at
+		FROM (
+			SELECT l.id, l.diary_entry_id, l.question, COALESCE(l.answer, '') AS answer, l.category,
+				COALESCE(l.url, '') AS url, l.created_at,
+				ROW_NUMBER() OVER (
+					PARTITION BY lower(trim(l.question))
+					ORDER BY l.created_at DESC, l.id DESC
+				) AS rank
+			FROM lookups l
+			JOIN diary_entries d ON d.id = l.diary_entry_id
+			WHERE d.movie_id = ?
+		)
+		WHERE rank = 1
+		ORDER BY created_at, id
+	`, movieID)
+	if err != nil {
+		return nil, fmt.Errorf("listing movie lookups: %w", err)
+	}
+	return lookups, nil
+}
+
+// ExportLookups returns every lookup with the title of its movie, ordered by movie and then
+// by when the lookup was recorded. An empty category or a zero movieID matches any.
+func (db *DB) ExportLookups(
+	ctx context.Context, category models.LookupCategory, movieID int64,
+) ([]models.ExportedLookup, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT l.question, COALESCE(l.answer, ''), COALESCE(l.url, ''), l.category, m.title
+		FROM lookups l
+		JOIN diary_entries d ON d.id = l.diary_entry_id
+		JOIN movies m ON m.id = d.movie_id
+		WHERE (?1 = '' OR l.category = ?1) AND (?2 = 0 OR m.id = ?2)
+		ORDER BY m.title, l.created_at, l.id
+	`, category, movieID)
+	if err != nil {
+		return nil, fmt.Errorf("exporting lookups: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var lookups []models.ExportedLookup
+	for rows.Next() {
+		var l models.ExportedLookup
+		if err := rows.Scan(&l.Question, &l.Answer, &l.URL, &l.Category, &l.Movie); err != nil {
+			return nil, fmt.Errorf("scanning exported lookup: %w", err)
+		}
+		lookups = append(lookups, l)
+	}
+
+	return lookups, rows.Err()
+}
+
+// LookupURLs returns the distinct URLs of all lookups, in alphabetical order.
+func (db *DB) LookupURLs(ctx context.Context) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT DISTINCT url FROM lookups
+		WHERE COALESCE(url, '') != ''
+		ORDER BY url
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("listing lookup URLs: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var urls []string
+	for rows.Next() {
+		var url string
+		if err := rows.Scan(&url); err != nil {
+			return nil, fmt.Errorf("scanning lookup URL: %w", err)
+		}
+		urls = append(urls, url)
+	}
+	return urls, rows.Err()
+}
+
+// MarkLookupLinks records whether each URL worked when checked on every lookup linking
+// to it, and returns the number of lookups marked. Editing a lookup's URL clears the mark.
+func (db *DB) MarkLookupLinks(ctx context.Context, linkOK map[string]bool) (int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var marked int64
+	for url, ok := range linkOK {
+		result, err := tx.ExecContext(ctx, "UPDATE lookups SET link_ok = ? WHERE url = ?", ok, url)
+		if err != nil {
+			return 0, fmt.Errorf("marking lookup link: %w", err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("counting marked lookups: %w", err)
+		}
+		marked += n
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing transaction: %w", err)
+	}
+	return marked, nil
+}
+
+// MostLookedUpFilms returns up to limit movies ranked by their total lookups across all
+// viewings, most first, with ties broken by title. Movies without lookups are left out.
+func (db *DB) MostLookedUpFilms(ctx context.Context, limit int) ([]models.MovieLookups, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+movieColumns+`, COUNT(*) AS lookup_count
+		FROM movies m
+		JOIN diary_entries d ON d.movie_id = m.id
+		JOIN lookups l ON l.diary_entry_id = d.id
+		GROUP BY m.id
+		ORDER BY lookup_count DESC, m.title, m.id
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("ranking movies by lookups: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var ranking []models.MovieLookups
+	for rows.Next() {
+		var r models.MovieLookups
+		m := &r.Movie
+		err := rows.Scan(&m.ID, &m.TMDBID, &m.Title, &m.Year, &m.PosterURL, &m.Director, &m.Genre, &m.Overview, &m.IMDbID, &r.LookupCount)
+		if err != nil {
+			return nil, fmt.Errorf("scanning movie lookups: %w", err)
+		}
+		ranking = append(ranking, r)
+	}
+
+	return ranking, rows.Err()
+}
+
+// timestampLayout is how SQLite's CURRENT_TIMESTAMP stores times.
+const timestampLayout = "2006-01-02 15:04:05"
+
+// LookupCursor marks a position in a list of lookups ordered newest first.
+type LookupCursor struct {
+	CreatedAt time.Time
+	ID        int64
+}
+
+// String encodes the cursor for use in a URL.
+func (c LookupCursor) String() string {
+	return fmt.Sprintf("%d.%d", c.CreatedAt.Unix(), c.ID)
+}
+
+// ParseLookupCursor decodes a cursor produced by LookupCursor.String.
+func ParseLookupCursor(s string) (LookupCursor, error) {
+	secs, id, ok := strings.Cut(s, ".")
+	if !ok {
+		return LookupCursor{}, fmt.Errorf("%w: malformed cursor %q", ErrInvalidInput, s)
+	}
+	unix, err := strconv.ParseInt(secs, 10, 64)
+	if err != nil {
+		return LookupCursor{}, fmt.Errorf("%w: malformed cursor %q", ErrInvalidInput, s)
+	}
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return LookupCursor{}, fmt.Errorf("%w: malformed cursor %q", ErrInvalidInput, s)
+	}
+	return LookupCursor{CreatedAt: time.Unix(unix, 0).UTC(), ID: n}, nil
+}
+
+// ListLookupsByCategory returns up to limit lookups in the category, newest first, starting
+// after the given cursor (nil for the first page). It also returns the cursor for the next
+// page, which is nil on the last page. Paging by (created_at, id) rather than by offset keeps
+// pages from skipping or repeating rows when lookups are added between requests. The limit
+// must be positive.
+func (db *DB) ListLookupsByCategory(
+	ctx context.Context,
+	category models.LookupCategory,
+	after *LookupCursor,
+	limit int,
+) ([]models.Lookup, *LookupCursor, error) {
+	if limit <= 0 {
+		return nil, nil, fmt.Errorf("%w: limit must be positive, got %d", ErrInvalidInput, limit)
+	}
+
+	query := `
+		SELECT id, diary_entry_id, question, COALESCE(answer, ''), category, COALESCE(url, ''), created_at
+		FROM lookups
+		WHERE category = ?`
+	args := []any{category}
+	if after != nil {
+		query += ` AND (created_at, id) < (?, ?)`
+		args = append(args, after.CreatedAt.UTC().Format(timestampLayout), after.ID)
+	}
+	// Fetch one extra row to tell whether there's another page
+	query += `
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?`
+	args = append(args, limit+1)
+
+	lookups, err := db.queryLookups(ctx, query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("listing lookups by category: %w", err)
+	}
+	if len(lookups) <= limit {
+		return lookups, nil, nil
+	}
+
+	lookups = lookups[:limit]
+	last := lookups[limit-1]
+	return lookups, &LookupCursor{CreatedAt: last.CreatedAt, ID: last.ID}, nil
+}
+
+// queryLookups runs a query selecting lookup columns and scans the results.
+func (db *DB) queryLookups(ctx context.Context, query string, args ...any) ([]models.Lookup, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var lookups []models.Lookup
+	for rows.Next() {
+		var l models.Lookup
+		if err := rows.Scan(&l.ID, &l.DiaryEntryID, &l.Question, &l.Answer, &l.Category, &l.URL, &l.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning lookup: %w", err)
+		}
+		lookups = append(lookups, l)
+	}
+
+	return lookups, rows.Err()
+}
+
+// normalizeLookupInput trims the input, guesses a missing category from the question
+// and validates it.
+func normalizeLookupInput(input models.LookupInput) (models.LookupInput, error) {
+	input.Question = strings.TrimSpace(input.Question)
+	input.Answer = strings.TrimSpace(input.Answer)
+	input.URL = strings.TrimSpace(input.URL)
+	if input.Category == "" {
+		input.Category = classifyLookup(input.Question)
+	}
+
+	if err := input.Validate(); err != nil {
+		return input, fmt.Errorf("%w: %w", ErrInvalidInput, err)
+	}
+
+	return input, nil
+}
@@ -0,0 +1,385 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/pavelanni/movie-journal/internal/models"
+)
+
+func TestCreateLookupsRollsBack(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	entryID := addTestEntry(t, db, 438631, "Dune")
+
+	// Fail the second insert inside the transaction, after the first has gone through
+	_, err := db.ExecContext(ctx, `
+		CREATE TRIGGER fail_lookup BEFORE INSERT ON lookups WHEN NEW.question = 'Boom?'
+		BEGIN SELECT RAISE(ABORT, 'lookup rejected'); END`)
+	if err != nil {
+		t.Fatalf("creating trigger: %v", err)
+	}
+
+	_, err = db.CreateLookups(ctx, entryID, []models.LookupInput{
+		{Question: "Where was it filmed?", Answer: "Jordan", Category: models.LookupCategoryLocation},
+		{Question: "Boom?", Category: models.LookupCategoryTrivia},
+		{Question: "Who plays Chani?", Answer: "Zendaya", Category: models.LookupCategoryActor},
+	})
+	if err == nil || !strings.Contains(err.Error(), "inserting lookup 1") {
+		t.Fatalf("err = %v, want the insert of lookup 1 to fail", err)
+	}
+
+	lookups, err := db.listLookups(ctx, entryID)
+	if err != nil {
+		t.Fatalf("listing lookups: %v", err)
+	}
+	if len(lookups) != 0 {
+		t.Errorf("entry has %d lookups after a failed batch, want none", len(lookups))
+	}
+}
+
+func TestListLookupsByCategoryPages(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	entryID := addTestEntry(t, db, 438631, "Dune")
+
+	addLookups := func(inputs ...models.LookupInput) []int64 {
+		t.Helper()
+		lookups, err := db.CreateLookups(ctx, entryID, inputs)
+		if err != nil {
+			t.Fatalf("creating lookups: %v", err)
+		}
+		ids := make([]int64, len(lookups))
+		for i := range lookups {
+			ids[i] = lookups[i].ID
+		}
+		return ids
+	}
+	actor := func(question string) models.LookupInput {
+		return models.LookupInput{Question: question, Category: models.LookupCategoryActor}
+	}
+	want := addLookups(actor("Who plays Paul?"), actor("Who plays Chani?"), actor("Who plays Stilgar?"),
+		models.LookupInput{Question: "Where was it filmed?", Category: models.LookupCategoryLocation},
+		actor("Who plays Jessica?"), actor("Who plays Gurney?"))
+	want = slices.Delete(want, 3, 4)
+	slices.Reverse(want)
+
+	seen := make(map[int64]bool)
+	var got []int64
+	var after *LookupCursor
+	for page := 0; ; page++ {
+		lookups, next, err := db.ListLookupsByCategory(ctx, models.LookupCategoryActor, after, 2)
+		if err != nil {
+			t.Fatalf("page %d: %v", page, err)
+		}
+		if len(lookups) > 2 {
+			t.Fatalf("page %d has %d lookups, want at most 2", page, len(lookups))
+		}
+		for _, lookup := range lookups {
+			if seen[lookup.ID] {
+				t.Errorf("lookup %d is on more than one page", lookup.ID)
+			}
+			seen[lookup.ID] = true
+			got = append(got, lookup.ID)
+		}
+		if page == 0 {
+			// Lookups added while paging don't shift the later pages
+			addLookups(actor("Who plays Duncan?"))
+		}
+		if next == nil {
+			break
+		}
+		after = next
+	}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("paged lookups = %v, want %v", got, want)
+	}
+}
+
+func TestListLookupsByCategoryInvalidLimit(t *testing.T) {
+	db := openTestDB(t)
+
+	for _, limit := range []int{0, -1} {
+		_, _, err := db.ListLookupsByCategory(context.Background(), models.LookupCategoryActor, nil, limit)
+		if !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("limit %d: err = %v, want ErrInvalidInput", limit, err)
+		}
+	}
+}
+
+func TestListLookupsByMovie(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	firstID := addTestEntry(t, db, 438631, "Dune")
+	first, err := db.GetDiaryEntry(ctx, firstID)
+	if err != nil {
+		t.Fatalf("getting entry: %v", err)
+	}
+	secondID, err := db.CreateDiaryEntry(ctx, models.DiaryEntryInput{
+		MovieID: first.MovieID, WatchedAt: first.WatchedDate.AddDate(1, 0, 0), Rating: 5,
+	})
+	if err != nil {
+		t.Fatalf("creating rewatch: %v", err)
+	}
+	otherID := addTestEntry(t, db, 693134, "Dune: Part Two")
+
+	for _, lookup := range []struct {
+		question string
+		answer   string
+		entryID  int64
+	}{
+		{entryID: firstID, question: "Where was it filmed?", answer: "Jordan"},
+		{entryID: firstID, question: "Who plays Chani?", answer: "Zendaya"},
+		{entryID: secondID, question: "  where was it FILMED? ", answer: "Jordan and Abu Dhabi"},
+		{entryID: secondID, question: "Who composed the score?", answer: "Hans Zimmer"},
+		{entryID: otherID, question: "Who plays Feyd-Rautha?", answer: "Austin Butler"},
+	} {
+		_, err := db.CreateLookup(ctx, models.LookupInput{
+			DiaryEntryID: lookup.entryID, Question: lookup.question, Answer: lookup.answer,
+			Category: models.LookupCategoryTrivia,
+		})
+		if err != nil {
+			t.Fatalf("creating lookup: %v", err)
+		}
+	}
+
+	lookups, err := db.ListLookupsByMovie(ctx, first.MovieID)
+	if err != nil {
+		t.Fatalf("ListLookupsByMovie: %v", err)
+	}
+
+	var got []string
+	for _, lookup := range lookups {
+		got = append(got, lookup.Answer)
+	}
+	want := []string{"Zendaya", "Jordan and Abu Dhabi", "Hans Zimmer"}
+	if !slices.Equal(got, want) {
+		t.Errorf("answers = %q, want %q", got, want)
+	}
+}
+
+func TestListLookupsByMovieNone(t *testing.T) {
+	db := openTestDB(t)
+	entryID := addTestEntry(t, db, 438631, "Dune")
+	entry, err := db.GetDiaryEntry(context.Background(), entryID)
+	if err != nil {
+		t.Fatalf("getting entry: %v", err)
+	}
+
+	lookups, err := db.ListLookupsByMovie(context.Background(), entry.MovieID)
+	if err != nil {
+		t.Fatalf("ListLookupsByMovie: %v", err)
+	}
+	if len(lookups) != 0 {
+		t.Errorf("got %d lookups for a movie without any, want none", len(lookups))
+	}
+}
+
+func TestUnansweredLookups(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	entryID := addTestEntry(t, db, 438631, "Dune")
+	lookups, err := db.CreateLookups(ctx, entryID, []models.LookupInput{
+		{Question: "Where was Arrakis filmed?", Answer: "Wadi Rum"},
+		{Question: "Who plays Chani?"},
+		{Question: "Who composed the score?", Answer: "   "},
+		{Question: "How big is a sandworm?", Answer: "Very"},
+		{Question: "What is the spice?"},
+	})
+	if err != nil {
+		t.Fatalf("creating lookups: %v", err)
+	}
+	// Rows written before answers were trimmed may hold other whitespace
+	if _, err := db.ExecContext(ctx, "UPDATE lookups SET answer = ? WHERE id = ?", "\t\n", lookups[4].ID); err != nil {
+		t.Fatalf("setting a whitespace answer: %v", err)
+	}
+	// Another entry's questions never count
+	if _, err := db.CreateLookups(ctx, addTestEntry(t, db, 693134, "Dune: Part Two"), []models.LookupInput{
+		{Question: "Who plays Feyd-Rautha?"},
+	}); err != nil {
+		t.Fatalf("creating lookups: %v", err)
+	}
+
+	got, err := db.UnansweredLookups(ctx, entryID)
+	if err != nil {
+		t.Fatalf("UnansweredLookups: %v", err)
+	}
+
+	var questions []string
+	for _, l := range got {
+		questions = append(questions, l.Question)
+	}
+	want := []string{"Who plays Chani?", "Who composed the score?", "What is the spice?"}
+	if !slices.Equal(questions, want) {
+		t.Errorf("unanswered = %q, want %q", questions, want)
+	}
+}
+
+func TestUnansweredLookupsAllAnswered(t *testing.T) {
+	db := openTestDB(t)
+	entryID := addTestEntry(t, db, 438631, "Dune")
+	if _, err := db.CreateLookups(context.Background(), entryID, []models.LookupInput{
+		{Question: "Where was Arrakis filmed?", Answer: "Wadi Rum"},
+	}); err != nil {
+		t.Fatalf("creating lookups: %v", err)
+	}
+
+	got, err := db.UnansweredLookups(context.Background(), entryID)
+	if err != nil {
+		t.Fatalf("UnansweredLookups: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("got %d unanswered lookups, want none", len(got))
+	}
+}
+
+func TestMostLookedUpFilms(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	addLookups := func(entryID int64, n int) {
+		t.Helper()
+		inputs := make([]models.LookupInput, n)
+		for i := range inputs {
+			inputs[i] = models.LookupInput{Question: "Question?", Category: models.LookupCategoryTrivia}
+		}
+		if _, err := db.CreateLookups(ctx, entryID, inputs); err != nil {
+			t.Fatalf("creating lookups: %v", err)
+		}
+	}
+
+	dune := addTestEntry(t, db, 438631, "Dune")
+	addLookups(dune, 2)
+	// Lookups from a rewatch add up with the first viewing's
+	entry, err := db.GetDiaryEntry(ctx, dune)
+	if err != nil {
+		t.Fatalf("getting entry: %v", err)
+	}
+	rewatch, err := db.CreateDiaryEntry(ctx, models.DiaryEntryInput{MovieID: entry.MovieID, WatchedAt: entry.WatchedDate.AddDate(0, 1, 0)})
+	if err != nil {
+		t.Fatalf("creating rewatch: %v", err)
+	}
+	addLookups(rewatch, 1)
+	addLookups(addTestEntry(t, db, 949, "Heat"), 2)
+	addLookups(addTestEntry(t, db, 348, "Alien"), 2)
+	addTestEntry(t, db, 1, "Cats")
+
+	tests := []struct {
+		want  []string
+		limit int
+	}{
+		{limit: 10, want: []string{"Dune 3", "Alien 2", "Heat 2"}},
+		{limit: 2, want: []string{"Dune 3", "Alien 2"}},
+	}
+	for _, tt := range tests {
+		ranking, err := db.MostLookedUpFilms(ctx, tt.limit)
+		if err != nil {
+			t.Fatalf("MostLookedUpFilms: %v", err)
+		}
+		var got []string
+		for _, r := range ranking {
+			got = append(got, fmt.Sprintf("%s %d", r.Movie.Title, r.LookupCount))
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("MostLookedUpFilms(%d) = %q, want %q", tt.limit, got, tt.want)
+		}
+	}
+}
+
+// lookupQuestions returns the questions of an entry's lookups, in their chosen order.
+func lookupQuestions(t *testing.T, db *DB, entryID int64) []string {
+	t.Helper()
+	lookups, err := db.SearchLookups(context.Background(), entryID, "")
+	if err != nil {
+		t.Fatalf("listing lookups: %v", err)
+	}
+	questions := make([]string, len(lookups))
+	for i, l := range lookups {
+		questions[i] = l.Question
+	}
+	return questions
+}
+
+func TestReorderLookups(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	entryID := addTestEntry(t, db, 438631, "Dune")
+	lookups, err := db.CreateLookups(ctx, entryID, []models.LookupInput{
+		{Question: "Who plays Paul?"},
+		{Question: "Where was Arrakis filmed?"},
+		{Question: "What is the spice?"},
+	})
+	if err != nil {
+		t.Fatalf("creating lookups: %v", err)
+	}
+
+	if err := db.ReorderLookups(ctx, entryID, []int64{lookups[2].ID, lookups[0].ID, lookups[1].ID}); err != nil {
+		t.Fatalf("ReorderLookups: %v", err)
+	}
+	want := []string{"What is the spice?", "Who plays Paul?", "Where was Arrakis filmed?"}
+	if got := lookupQuestions(t, db, entryID); !slices.Equal(got, want) {
+		t.Errorf("order = %q, want %q", got, want)
+	}
+
+	// A lookup added later goes after the reordered ones
+	if _, err := db.CreateLookup(ctx, models.LookupInput{DiaryEntryID: entryID, Question: "Who wrote the novel?"}); err != nil {
+		t.Fatalf("creating lookup: %v", err)
+	}
+	want = append(want, "Who wrote the novel?")
+	if got := lookupQuestions(t, db, entryID); !slices.Equal(got, want) {
+		t.Errorf("order after adding a lookup = %q, want %q", got, want)
+	}
+}
+
+func TestReorderLookupsInvalid(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	entryID := addTestEntry(t, db, 438631, "Dune")
+	lookups, err := db.CreateLookups(ctx, entryID, []models.LookupInput{
+		{Question: "Who plays Paul?"},
+		{Question: "Where was Arrakis filmed?"},
+	})
+	if err != nil {
+		t.Fatalf("creating lookups: %v", err)
+	}
+	foreign, err := db.CreateLookups(ctx, addTestEntry(t, db, 949, "Heat"), []models.LookupInput{
+		{Question: "Where is the diner?"},
+	})
+	if err != nil {
+		t.Fatalf("creating lookups: %v", err)
+	}
+	a, b := lookups[0].ID, lookups[1].ID
+
+	tests := []struct {
+		wantErr error
+		name    string
+		ids     []int64
+		entryID int64
+	}{
+		{name: "foreign lookup", entryID: entryID, ids: []int64{b, foreign[0].ID}, wantErr: ErrInvalidInput},
+		{name: "foreign lookup added", entryID: entryID, ids: []int64{b, a, foreign[0].ID}, wantErr: ErrInvalidInput},
+		{name: "lookup left out", entryID: entryID, ids: []int64{b}, wantErr: ErrInvalidInput},
+		{name: "lookup listed twice", entryID: entryID, ids: []int64{b, b}, wantErr: ErrInvalidInput},
+		{name: "unknown lookup", entryID: entryID, ids: []int64{b, 999}, wantErr: ErrInvalidInput},
+		{name: "unknown entry", entryID: 999, ids: []int64{b, a}, wantErr: ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := db.ReorderLookups(ctx, tt.entryID, tt.ids)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ReorderLookups = %v, want %v", err, tt.wantErr)
+			}
+			// Nothing moves, not even the lookups placed before the bad ID
+			want := []string{"Who plays Paul?", "Where was Arrakis filmed?"}
+			if got := lookupQuestions(t, db, entryID); !slices.Equal(got, want) {
+				t.Errorf("order = %q after a rejected reorder, want %q", got, want)
+			}
+		})
+	}
+}
@@ -4,10 +4,11 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"strings"
 )
 
 // schemaVersion is the current database schema version.
-const schemaVersion = 1
+const schemaVersion = 12
 
 // Migrate runs database migrations to bring the schema up to date.
 func (db *DB) Migrate(ctx context.Context) error {
@@ -23,10 +24,9 @@ func (db *DB) Migrate(ctx context.Context) error {
 	}
 
 	// Get current version
-	var currentVersion int
-	err = db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&currentVersion)
+	currentVersion, err := db.SchemaVersion(ctx)
 	if err != nil {
-		return fmt.Errorf("getting current version: %w", err)
+		return err
 	}
 
 	slog.Info("Database migration check",
@@ -37,6 +37,10 @@ func (db *DB) Migrate(ctx context.Context) error {
 	// Run migrations
 	for v := currentVersion + 1; v <= schemaVersion; v++ {
 		if err := db.runMigration(ctx, v); err != nil {
+			slog.Error("Migration failed",
+				slog.Int("version", v),
+				slog.String("error", err.Error()),
+			)
 			return fmt.Errorf("running migration %d: %w", v, err)
 		}
 		slog.Info("Applied migration", slog.Int("version", v))
@@ -45,23 +49,66 @@ func (db *DB) Migrate(ctx context.Context) error {
 	return nil
 }
 
-func (db *DB) runMigration(ctx context.Context, version int) error {
-	tx, err := db.BeginTx(ctx, nil)
+// SchemaVersion returns the version of the latest migration applied to the database.
+func (db *DB) SchemaVersion(ctx context.Context) (int, error) {
+	var version int
+	err := db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
 	if err != nil {
-		return fmt.Errorf("beginning transaction: %w", err)
+		return 0, fmt.Errorf("getting current version: %w", err)
 	}
-	defer func() { _ = tx.Rollback() }()
+	return version, nil
+}
 
+// runMigration applies the migration for the given version.
+func (db *DB) runMigration(ctx context.Context, version int) error {
 	var migration string
 	switch version {
 	case 1:
 		migration = migrationV1
+	case 2:
+		migration = migrationV2
+	case 3:
+		migration = migrationV3
+	case 4:
+		migration = migrationV4
+	case 5:
+		migration = migrationV5
+	case 6:
+		migration = migrationV6
+	case 7:
+		migration = migrationV7
+	case 8:
+		migration = migrationV8
+	case 9:
+		migration = migrationV9
+	case 10:
+		migration = migrationV10
+	case 11:
+		migration = migrationV11
+	case 12:
+		migration = migrationV12
 	default:
 		return fmt.Errorf("unknown migration version: %d", version)
 	}
 
-	if _, err := tx.ExecContext(ctx, migration); err != nil {
-		return fmt.Errorf("executing migration: %w", err)
+	return db.applyMigration(ctx, version, migration)
+}
+
+// applyMigration runs a migration script in a transaction and records it as applied
+// under version.
+func (db *DB) applyMigration(ctx context.Context, version int, script string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Run statements one by one so a failure can point at the offending one.
+	// The transaction is rolled back on error, so schema_migrations stays untouched.
+	for i, stmt := range splitStatements(script) {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("executing statement %d (%s): %w", i+1, statementSnippet(stmt), err)
+		}
 	}
 
 	if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version) VALUES (?)", version); err != nil {
@@ -71,6 +118,74 @@ func (db *DB) runMigration(ctx context.Context, version int) error {
 	return tx.Commit()
 }
 
+// splitStatements splits a migration script into individual statements.
+// Semicolons inside quoted strings and trigger BEGIN ... END blocks don't end a statement.
+func splitStatements(script string) []string {
+	var (
+		statements []string
+		current    strings.Builder
+		quote      rune
+		depth      int
+	)
+
+	flush := func() {
+		if stmt := strings.TrimSpace(current.String()); stripComments(stmt) != "" {
+			statements = append(statements, stmt)
+		}
+		current.Reset()
+	}
+
+	for _, line := range strings.SplitAfter(script, "\n") {
+		if quote == 0 {
+			upper := strings.ToUpper(stripComments(line))
+			switch {
+			case upper == "BEGIN" || strings.HasSuffix(upper, " BEGIN"):
+				depth++
+			case upper == "END" || upper == "END;":
+				depth--
+			}
+		}
+		for _, r := range line {
+			current.WriteRune(r)
+			switch {
+			case quote != 0:
+				if r == quote {
+					quote = 0
+				}
+			case r == '\'' || r == '"':
+				quote = r
+			case r == ';' && depth == 0:
+				flush()
+			}
+		}
+	}
+	flush()
+
+	return statements
+}
+
+// stripComments removes "--" line comments from a statement.
+func stripComments(stmt string) string {
+	lines := strings.Split(stmt, "\n")
+	for i, line := range lines {
+		if idx := strings.Index(line, "--"); idx >= 0 {
+			lines[i] = line[:idx]
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// statementSnippet returns a short single-line excerpt of a statement for error messages.
+func statementSnippet(stmt string) string {
+	const maxLen = 60
+
+	snippet := strings.Join(strings.Fields(stripComments(stmt)), " ")
+	if len(snippet) > maxLen {
+		snippet = snippet[:maxLen] + "..."
+	}
+	return snippet
+}
+
 // migrationV1 creates the initial schema.
 const migrationV1 = `
 -- Movies table: cached movie metadata from TMDB
@@ -116,3 +231,114 @@ CREATE TABLE IF NOT EXISTS lookups (
 CREATE INDEX IF NOT EXISTS idx_lookups_diary_entry_id ON lookups(diary_entry_id);
 CREATE INDEX IF NOT EXISTS idx_lookups_category ON lookups(category);
 `
+
+// migrationV2 adds shareable slugs and the viewing location to diary entries.
+const migrationV2 = `
+ALTER TABLE diary_entries ADD COLUMN slug TEXT;
+ALTER TABLE diary_entries ADD COLUMN watched_location TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_diary_entries_slug ON diary_entries(slug);
+`
+
+// migrationV3 normalizes movie genres into their own tables.
+// The legacy movies.genre column is kept in sync with the first genre.
+const migrationV3 = `
+CREATE TABLE IF NOT EXISTS genres (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT UNIQUE NOT NULL COLLATE NOCASE
+);
+
+CREATE TABLE IF NOT EXISTS movie_genres (
+	movie_id INTEGER NOT NULL REFERENCES movies(id) ON DELETE CASCADE,
+	genre_id INTEGER NOT NULL REFERENCES genres(id) ON DELETE CASCADE,
+	position INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (movie_id, genre_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_movie_genres_genre_id ON movie_genres(genre_id);
+
+-- Backfill from the legacy single-genre column
+INSERT OR IGNORE INTO genres (name)
+SELECT DISTINCT TRIM(genre) FROM movies WHERE TRIM(COALESCE(genre, '')) != '';
+
+INSERT OR IGNORE INTO movie_genres (movie_id, genre_id, position)
+SELECT m.id, g.id, 0 FROM movies m JOIN genres g ON g.name = TRIM(m.genre);
+`
+
+// migrationV4 indexes lookups for paging through a category newest first.
+// It replaces the category-only index, which is a prefix of the new one.
+const migrationV4 = `
+CREATE INDEX IF NOT EXISTS idx_lookups_category_created ON lookups(category, created_at, id);
+DROP INDEX IF EXISTS idx_lookups_category;
+`
+
+// migrationV5 stores autosaved drafts of the new entry form, keyed by a cookie ID.
+// The form is kept as URL-encoded values so it can be refilled as submitted.
+const migrationV5 = `
+CREATE TABLE IF NOT EXISTS drafts (
+	id TEXT PRIMARY KEY,
+	form TEXT NOT NULL,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_drafts_updated_at ON drafts(updated_at);
+`
+
+// migrationV6 adds the IMDb ID used to link movies to IMDb and Letterboxd.
+const migrationV6 = `
+ALTER TABLE movies ADD COLUMN imdb_id TEXT;
+`
+
+// migrationV7 tracks when diary entries were last changed, so edits made from a stale
+// copy of an entry can be detected.
+const migrationV7 = `
+ALTER TABLE diary_entries ADD COLUMN updated_at DATETIME;
+UPDATE diary_entries SET updated_at = created_at;
+`
+
+// migrationV8 remembers the entry created for each Idempotency-Key, so a retried
+// request returns that entry instead of creating a duplicate.
+const migrationV8 = `
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+	key TEXT PRIMARY KEY,
+	entry_id INTEGER NOT NULL REFERENCES diary_entries(id) ON DELETE CASCADE,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created_at ON idempotency_keys(created_at);
+`
+
+// migrationV9 lets an entry's lookups be put in the order they came up in the film.
+// Existing lookups keep the order they were recorded in.
+const migrationV9 = `
+ALTER TABLE lookups ADD COLUMN position INTEGER NOT NULL DEFAULT 0;
+
+UPDATE lookups SET position = (
+	SELECT COUNT(*) FROM lookups l
+	WHERE l.diary_entry_id = lookups.diary_entry_id
+		AND (l.created_at < lookups.created_at OR (l.created_at = lookups.created_at AND l.id < lookups.id))
+);
+
+CREATE INDEX IF NOT EXISTS idx_lookups_entry_position ON lookups(diary_entry_id, position);
+`
+
+// migrationV10 caches each movie's TMDB tagline and keywords, stored as a JSON array.
+// facts_fetched_at is NULL until they've been fetched, so movies TMDB knows nothing
+// about aren't fetched again on every visit.
+const migrationV10 = `
+ALTER TABLE movies ADD COLUMN tagline TEXT;
+ALTER TABLE movies ADD COLUMN keywords TEXT;
+ALTER TABLE movies ADD COLUMN facts_fetched_at DATETIME;
+`
+
+// migrationV11 records the format an entry was watched in, such as "4K Blu-ray" or
+// "Cinema", separately from where it was watched.
+const migrationV11 = `
+ALTER TABLE diary_entries ADD COLUMN format TEXT;
+`
+
+// migrationV12 records whether a lookup's URL still worked when the links were last
+// checked: 1 if it did, 0 if it was dead, and NULL if it hasn't been checked.
+const migrationV12 = `
+ALTER TABLE lookups ADD COLUMN link_ok INTEGER;
+`
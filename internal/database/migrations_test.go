@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestApplyMigrationRollsBackOnFailure(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	before, err := db.SchemaVersion(ctx)
+	if err != nil {
+		t.Fatalf("SchemaVersion: %v", err)
+	}
+
+	const broken = `
+-- The first statement is fine and must be rolled back with the rest
+CREATE TABLE watchlist_draft (id INTEGER PRIMARY KEY);
+
+CREATE INDEX idx_missing ON no_such_table(id);
+`
+	err = db.applyMigration(ctx, before+1, broken)
+
+	if err == nil {
+		t.Fatal("applyMigration succeeded with a broken script")
+	}
+	if !strings.Contains(err.Error(), "statement 2 (CREATE INDEX idx_missing ON no_such_table(id);)") {
+		t.Errorf("error = %q, want it to point at the failing statement", err)
+	}
+	if after, err := db.SchemaVersion(ctx); err != nil || after != before {
+		t.Errorf("schema version = %d (%v) after a failed migration, want still %d", after, err, before)
+	}
+	var tables int
+	err = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE name = 'watchlist_draft'").Scan(&tables)
+	if err != nil {
+		t.Fatalf("checking tables: %v", err)
+	}
+	if tables != 0 {
+		t.Error("the statement before the failing one wasn't rolled back")
+	}
+}
+
+func TestRunMigrationUnknownVersion(t *testing.T) {
+	db := openTestDB(t)
+
+	err := db.runMigration(context.Background(), schemaVersion+1)
+
+	if err == nil || !strings.Contains(err.Error(), "unknown migration version") {
+		t.Errorf("err = %v, want an unknown version error", err)
+	}
+}
+
+func TestStatementSnippet(t *testing.T) {
+	tests := []struct {
+		stmt string
+		want string
+	}{
+		{stmt: "-- comment\nCREATE TABLE t (\n\tid INTEGER\n)", want: "CREATE TABLE t ( id INTEGER )"},
+		{
+			stmt: "INSERT INTO movies (tmdb_id, title, year, director) SELECT tmdb_id, title, year, director FROM old",
+			want: "INSERT INTO movies (tmdb_id, title, year, director) SELECT t...",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := statementSnippet(tt.stmt); got != tt.want {
+			t.Errorf("statementSnippet(%q) = %q, want %q", tt.stmt, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/pavelanni/movie-journal/internal/models"
+)
+
+// movieColumns lists the columns selected for a movie aliased as m.
+const movieColumns = `
+	m.id, m.tmdb_id, m.title, COALESCE(m.year, 0), COALESCE(m.poster_url, ''),
+	COALESCE(m.director, ''), COALESCE(m.genre, ''), COALESCE(m.overview, ''), COALESCE(m.imdb_id, '')`
+
+// SearchMovies returns up to limit movies whose title contains the query, case-insensitively.
+func (db *DB) SearchMovies(ctx context.Context, query string, limit int) ([]models.Movie, error) {
+	pattern := "%" + escapeLike(strings.TrimSpace(query)) + "%"
+	movies, err := db.queryMovies(ctx, `
+		SELECT `+movieColumns+`
+		FROM movies m
+		WHERE m.title LIKE ? ESCAPE '\'
+		ORDER BY m.title, m.id
+		LIMIT ?
+	`, pattern, limit)
+	if err != nil {
+		return nil, fmt.Errorf("searching movies: %w", err)
+	}
+	return movies, nil
+}
+
+// GetMovie returns the movie with the given ID.
+func (db *DB) GetMovie(ctx context.Context, id int64) (*models.Movie, error) {
+	movies, err := db.queryMovies(ctx, `
+		SELECT `+movieColumns+`
+		FROM movies m
+		WHERE m.id = ?
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("getting movie: %w", err)
+	}
+	if len(movies) == 0 {
+		return nil, fmt.Errorf("movie %d: %w", id, ErrNotFound)
+	}
+	return &movies[0], nil
+}
+
+// SaveMovie adds a movie fetched from TMDB to the library and returns the stored movie.
+// If the TMDB ID is already present, its metadata is refreshed, keeping known values
+// that the new data leaves empty.
+func (db *DB) SaveMovie(ctx context.Context, movie models.Movie) (*models.Movie, error) {
+	id, err := saveMovie(ctx, db, movie)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetMovie(ctx, id)
+}
+
+// saveMovie adds or refreshes a movie like SaveMovie using q, which may be a transaction,
+// and returns its ID.
+func saveMovie(ctx context.Context, q runner, movie models.Movie) (int64, error) {
+	var id int64
+	err := q.QueryRowContext(ctx, `
+		INSERT INTO movies (tmdb_id, title, year, poster_url, director, genre, overview, imdb_id)
+		VALUES (?, ?, NULLIF(?, 0), NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''))
+		ON CONFLICT (tmdb_id) DO UPDATE SET
+			title = excluded.title,
+			year = COALESCE(excluded.year, movies.year),
+			poster_url = COALESCE(excluded.poster_url, movies.poster_url),
+			director = COALESCE(excluded.director, movies.director),
+			genre = COALESCE(excluded.genre, movies.genre),
+			overview = COALESCE(excluded.overview, movies.overview),
+			imdb_id = COALESCE(excluded.imdb_id, movies.imdb_id)
+		RETURNING id
+	`, movie.TMDBID, movie.Title, movie.Year, movie.PosterURL, movie.Director, movie.Genre, movie.Overview, movie.IMDbID).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("saving movie: %w", err)
+	}
+	return id, nil
+}
+
+// SetMovieIMDbID records the IMDb ID of a movie already in the library.
+func (db *DB) SetMovieIMDbID(ctx context.Context, id int64, imdbID string) error {
+	result, err := db.ExecContext(ctx, "UPDATE movies SET imdb_id = NULLIF(?, '') WHERE id = ?", imdbID, id)
+	if err != nil {
+		return fmt.Errorf("setting IMDb ID: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("checking updated movie: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("movie %d: %w", id, ErrNotFound)
+	}
+	return nil
+}
+
+// MovieFacts returns the TMDB tagline and keywords cached for a movie. It returns
+// ErrNotFound if the movie doesn't exist or its facts haven't been fetched yet.
+func (db *DB) MovieFacts(ctx context.Context, id int64) (*models.MovieFacts, error) {
+	var facts models.MovieFacts
+	var keywords string
+	err := db.QueryRowContext(ctx, `
+		SELECT COALESCE(tagline, ''), COALESCE(keywords, '[]')
+		FROM movies
+		WHERE id = ? AND facts_fetched_at IS NOT NULL
+	`, id).Scan(&facts.Tagline, &keywords)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("facts for movie %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting movie facts: %w", err)
+	}
+	if err := json.Unmarshal([]byte(keywords), &facts.Keywords); err != nil {
+		return nil, fmt.Errorf("decoding movie keywords: %w", err)
+	}
+	return &facts, nil
+}
+
+// SetMovieFacts caches the TMDB tagline and keywords of a movie already in the library.
+func (db *DB) SetMovieFacts(ctx context.Context, id int64, facts models.MovieFacts) error {
+	keywords, err := json.Marshal(facts.Keywords)
+	if err != nil {
+		return fmt.Errorf("encoding movie keywords: %w", err)
+	}
+	result, err := db.ExecContext(ctx, `
+		UPDATE movies SET tagline = NULLIF(?, ''), keywords = ?, facts_fetched_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, facts.Tagline, string(keywords), id)
+	if err != nil {
+		return fmt.Errorf("setting movie facts: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("checking updated movie: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("movie %d: %w", id, ErrNotFound)
+	}
+	return nil
+}
+
+// FindMovieByTitle returns the movie with exactly the given title, ignoring case, released
+// in year unless year is zero. If several movies match, the most recent release wins.
+func (db *DB) FindMovieByTitle(ctx context.Context, title string, year int) (*models.Movie, error) {
+	movies, err := db.queryMovies(ctx, `
+		SELECT `+movieColumns+`
+		FROM movies m
+		WHERE m.title = ?1 COLLATE NOCASE AND (?2 = 0 OR m.year = ?2)
+		ORDER BY m.year DESC, m.id DESC
+		LIMIT 1
+	`, strings.TrimSpace(title), year)
+	if err != nil {
+		return nil, fmt.Errorf("finding movie: %w", err)
+	}
+	if len(movies) == 0 {
+		return nil, fmt.Errorf("movie %q: %w", title, ErrNotFound)
+	}
+	return &movies[0], nil
+}
+
+// PruneOrphanMovies deletes movies that no diary entry references
+// and returns the number of movies removed.
+func (db *DB) PruneOrphanMovies(ctx context.Context) (int, error) {
+	result, err := db.ExecContext(ctx, `
+		DELETE FROM movies
+		WHERE NOT EXISTS (
+			SELECT 1 FROM diary_entries WHERE diary_entries.movie_id = movies.id
+		)
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("deleting orphan movies: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("counting deleted movies: %w", err)
+	}
+
+	return int(n), nil
+}
+
+// SetMovieGenres replaces the genres linked to a movie, keeping their order.
+// The legacy genre column is updated to the first genre.
+func (db *DB) SetMovieGenres(ctx context.Context, movieID int64, genres []string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM movie_genres WHERE movie_id = ?", movieID); err != nil {
+		return fmt.Errorf("clearing movie genres: %w", err)
+	}
+
+	var first any
+	position := 0
+	for _, name := range genres {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO genres (name) VALUES (?)", name); err != nil {
+			return fmt.Errorf("inserting genre %q: %w", name, err)
+		}
+		_, err := tx.ExecContext(ctx, `
+			INSERT OR IGNORE INTO movie_genres (movie_id, genre_id, position)
+			SELECT ?, id, ? FROM genres WHERE name = ?
+		`, movieID, position, name)
+		if err != nil {
+			return fmt.Errorf("linking genre %q: %w", name, err)
+		}
+
+		if first == nil {
+			first = name
+		}
+		position++
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE movies SET genre = ? WHERE id = ?", first, movieID); err != nil {
+		return fmt.Errorf("updating legacy genre: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// ListMoviesByGenre returns the movies linked to the given genre, ordered by title.
+func (db *DB) ListMoviesByGenre(ctx context.Context, genre string) ([]models.Movie, error) {
+	movies, err := db.queryMovies(ctx, `
+		SELECT `+movieColumns+`
+		FROM movies m
+		JOIN movie_genres mg ON mg.movie_id = m.id
+		JOIN genres g ON g.id = mg.genre_id
+		WHERE g.name = ?
+		ORDER BY m.title, m.id
+	`, strings.TrimSpace(genre))
+	if err != nil {
+		return nil, fmt.Errorf("listing movies by genre: %w", err)
+	}
+
+	for i := range movies {
+		if movies[i].Genres, err = db.movieGenres(ctx, movies[i].ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return movies, nil
+}
+
+// movieGenres returns the genre names linked to a movie, in order.
+func (db *DB) movieGenres(ctx context.Context, movieID int64) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT g.name
+		FROM genres g
+		JOIN movie_genres mg ON mg.genre_id = g.id
+		WHERE mg.movie_id = ?
+		ORDER BY mg.position, g.name
+	`, movieID)
+	if err != nil {
+		return nil, fmt.Errorf("listing movie genres: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var genres []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("scanning genre: %w", err)
+		}
+		genres = append(genres, name)
+	}
+
+	return genres, rows.Err()
+}
+
+// queryMovies runs a query selecting movieColumns and scans the results.
+func (db *DB) queryMovies(ctx context.Context, query string, args ...any) ([]models.Movie, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var movies []models.Movie
+	for rows.Next() {
+		var m models.Movie
+		err := rows.Scan(&m.ID, &m.TMDBID, &m.Title, &m.Year, &m.PosterURL, &m.Director, &m.Genre, &m.Overview, &m.IMDbID)
+		if err != nil {
+			return nil, fmt.Errorf("scanning movie: %w", err)
+		}
+		movies = append(movies, m)
+	}
+
+	return movies, rows.Err()
+}
+
+// escapeLike escapes LIKE wildcards so the value matches literally with ESCAPE '\'.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
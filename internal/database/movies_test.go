@@ -0,0 +1,183 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/pavelanni/movie-journal/internal/models"
+)
+
+func TestPruneOrphanMovies(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	watched := addTestEntry(t, db, 438631, "Dune")
+	orphan, err := db.SaveMovie(ctx, models.Movie{TMDBID: 693134, Title: "Dune: Part Two", Year: 2024})
+	if err != nil {
+		t.Fatalf("saving movie: %v", err)
+	}
+	// A movie whose only entry was deleted is an orphan too
+	deleted := addTestEntry(t, db, 841, "Dune (1984)")
+	deletedEntry, err := db.GetDiaryEntry(ctx, deleted)
+	if err != nil {
+		t.Fatalf("getting entry: %v", err)
+	}
+	if _, err := db.DeleteEntries(ctx, []int64{deleted}); err != nil {
+		t.Fatalf("deleting entry: %v", err)
+	}
+
+	n, err := db.PruneOrphanMovies(ctx)
+	if err != nil {
+		t.Fatalf("PruneOrphanMovies: %v", err)
+	}
+
+	if n != 2 {
+		t.Errorf("pruned %d movies, want 2", n)
+	}
+	for _, id := range []int64{orphan.ID, deletedEntry.MovieID} {
+		if _, err := db.GetMovie(ctx, id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("orphan movie %d: err = %v, want ErrNotFound", id, err)
+		}
+	}
+	entry, err := db.GetDiaryEntry(ctx, watched)
+	if err != nil {
+		t.Fatalf("the watched movie's entry is gone: %v", err)
+	}
+	if _, err := db.GetMovie(ctx, entry.MovieID); err != nil {
+		t.Errorf("the watched movie was pruned: %v", err)
+	}
+
+	if n, err := db.PruneOrphanMovies(ctx); err != nil || n != 0 {
+		t.Errorf("pruning again removed %d movies (%v), want none", n, err)
+	}
+}
+
+func TestSetMovieGenres(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	movie, err := db.SaveMovie(ctx, models.Movie{TMDBID: 438631, Title: "Dune", Year: 2021, Genre: "Drama"})
+	if err != nil {
+		t.Fatalf("saving movie: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		wantLegacy string
+		genres     []string
+		want       []string
+	}{
+		{
+			name:   "several in order",
+			genres: []string{"Science Fiction", " Adventure ", "", "Science Fiction"},
+			want:   []string{"Science Fiction", "Adventure"}, wantLegacy: "Science Fiction",
+		},
+		{name: "replaced", genres: []string{"Drama"}, want: []string{"Drama"}, wantLegacy: "Drama"},
+		{name: "cleared", genres: nil, want: nil, wantLegacy: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := db.SetMovieGenres(ctx, movie.ID, tt.genres); err != nil {
+				t.Fatalf("SetMovieGenres: %v", err)
+			}
+
+			genres, err := db.movieGenres(ctx, movie.ID)
+			if err != nil {
+				t.Fatalf("listing genres: %v", err)
+			}
+			if !slices.Equal(genres, tt.want) {
+				t.Errorf("genres = %q, want %q", genres, tt.want)
+			}
+			got, err := db.GetMovie(ctx, movie.ID)
+			if err != nil {
+				t.Fatalf("GetMovie: %v", err)
+			}
+			if got.Genre != tt.wantLegacy {
+				t.Errorf("legacy genre = %q, want %q", got.Genre, tt.wantLegacy)
+			}
+		})
+	}
+}
+
+func TestListMoviesByGenre(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	link := func(tmdbID int, title string, genres ...string) {
+		t.Helper()
+		movie, err := db.SaveMovie(ctx, models.Movie{TMDBID: tmdbID, Title: title})
+		if err != nil {
+			t.Fatalf("saving movie: %v", err)
+		}
+		if err := db.SetMovieGenres(ctx, movie.ID, genres); err != nil {
+			t.Fatalf("setting genres: %v", err)
+		}
+	}
+	link(438631, "Dune", "Science Fiction", "Adventure")
+	link(348, "Alien", "Horror", "Science Fiction")
+	link(949, "Heat", "Crime")
+
+	movies, err := db.ListMoviesByGenre(ctx, "Science Fiction")
+	if err != nil {
+		t.Fatalf("ListMoviesByGenre: %v", err)
+	}
+
+	var titles []string
+	for _, movie := range movies {
+		titles = append(titles, movie.Title)
+	}
+	if !slices.Equal(titles, []string{"Alien", "Dune"}) {
+		t.Errorf("movies = %q, want Alien and Dune", titles)
+	}
+	if len(movies) == 2 && !slices.Equal(movies[1].Genres, []string{"Science Fiction", "Adventure"}) {
+		t.Errorf("Dune's genres = %q, want all of them", movies[1].Genres)
+	}
+}
+
+func TestMovieFacts(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	movie, err := db.SaveMovie(ctx, models.Movie{TMDBID: 438631, Title: "Dune", Year: 2021})
+	if err != nil {
+		t.Fatalf("saving movie: %v", err)
+	}
+
+	// Nothing is cached until the facts are fetched
+	if _, err := db.MovieFacts(ctx, movie.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("MovieFacts before caching: err = %v, want ErrNotFound", err)
+	}
+
+	tests := []struct {
+		name  string
+		facts models.MovieFacts
+	}{
+		{name: "tagline and keywords", facts: models.MovieFacts{Tagline: "Beyond fear, destiny awaits.", Keywords: []string{"desert", "prophecy"}}},
+		// Facts that TMDB doesn't know are cached too, so they aren't fetched again
+		{name: "nothing known", facts: models.MovieFacts{Keywords: []string{}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := db.SetMovieFacts(ctx, movie.ID, tt.facts); err != nil {
+				t.Fatalf("SetMovieFacts: %v", err)
+			}
+
+			got, err := db.MovieFacts(ctx, movie.ID)
+			if err != nil {
+				t.Fatalf("MovieFacts: %v", err)
+			}
+			if got.Tagline != tt.facts.Tagline || !slices.Equal(got.Keywords, tt.facts.Keywords) {
+				t.Errorf("facts = %+v, want %+v", got, tt.facts)
+			}
+		})
+	}
+}
+
+func TestSetMovieFactsUnknownMovie(t *testing.T) {
+	db := openTestDB(t)
+
+	err := db.SetMovieFacts(context.Background(), 999, models.MovieFacts{Tagline: "Nobody's movie"})
+
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetMovieFacts for an unknown movie: err = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,237 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pavelanni/movie-journal/internal/models"
+)
+
+// GetStats computes diary-wide statistics as of now.
+func (db *DB) GetStats(ctx context.Context, now time.Time) (*models.Stats, error) {
+	stats := &models.Stats{}
+
+	rows, err := db.QueryContext(ctx, "SELECT COALESCE(notes, '') FROM diary_entries")
+	if err != nil {
+		return nil, fmt.Errorf("querying notes: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var notesCount int
+	for rows.Next() {
+		var notes string
+		if err := rows.Scan(&notes); err != nil {
+			return nil, fmt.Errorf("scanning notes: %w", err)
+		}
+		stats.TotalEntries++
+		if words := len(strings.Fields(notes)); words > 0 {
+			stats.TotalWords += words
+			notesCount++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating notes: %w", err)
+	}
+	if notesCount > 0 {
+		stats.AverageNoteWords = stats.TotalWords / notesCount
+	}
+
+	yearStart := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	err = db.QueryRowContext(ctx, `
+		SELECT COUNT(DISTINCT movie_id), COALESCE(AVG(rating), 0), COUNT(CASE WHEN watched_at >= ? AND watched_at < ? THEN 1 END)
+		FROM diary_entries
+	`, yearStart.Format(dateLayout), yearStart.AddDate(1, 0, 0).Format(dateLayout)).
+		Scan(&stats.TotalMovies, &stats.AverageRating, &stats.EntriesThisYear)
+	if err != nil {
+		return nil, fmt.Errorf("querying totals: %w", err)
+	}
+
+	stats.TopGenre, err = db.topGenre(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dates, err := db.watchedDates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stats.CurrentStreak = currentStreak(dates, now)
+
+	return stats, nil
+}
+
+// CountDiaryEntries returns the number of diary entries.
+func (db *DB) CountDiaryEntries(ctx context.Context) (int, error) {
+	var count int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM diary_entries").Scan(&count); err != nil {
+		return 0, fmt.Errorf("counting diary entries: %w", err)
+	}
+	return count, nil
+}
+
+// CountInYear returns the number of entries watched during the given calendar year.
+func (db *DB) CountInYear(ctx context.Context, year int) (int, error) {
+	yearStart := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	var count int
+	err := db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM diary_entries WHERE watched_at >= ? AND watched_at < ?
+	`, yearStart.Format(dateLayout), yearStart.AddDate(1, 0, 0).Format(dateLayout)).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("counting entries in %d: %w", year, err)
+	}
+	return count, nil
+}
+
+// MovieAverageRating returns the average rating across a movie's viewings and how many
+// viewings were rated. Unrated viewings are ignored; with none rated, both are zero.
+func (db *DB) MovieAverageRating(ctx context.Context, movieID int64) (float64, int, error) {
+	var average float64
+	var count int
+	err := db.QueryRowContext(ctx, `
+		SELECT COALESCE(AVG(rating), 0), COUNT(rating)
+		FROM diary_entries
+		WHERE movie_id = ? AND rating IS NOT NULL
+	`, movieID).Scan(&average, &count)
+	if err != nil {
+		return 0, 0, fmt.Errorf("averaging movie ratings: %w", err)
+	}
+	return average, count, nil
+}
+
+// CountByDecade counts diary entries by the release decade of their movie, keyed by the
+// decade's first year (1990 for the 1990s). Movies without a year are counted under 0.
+func (db *DB) CountByDecade(ctx context.Context) (map[int]int, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT CASE WHEN COALESCE(m.year, 0) = 0 THEN 0 ELSE (m.year / 10) * 10 END AS decade, COUNT(*)
+		FROM diary_entries d
+		JOIN movies m ON m.id = d.movie_id
+		GROUP BY decade
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("counting entries by decade: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var decade, count int
+		if err := rows.Scan(&decade, &count); err != nil {
+			return nil, fmt.Errorf("scanning decade count: %w", err)
+		}
+		counts[decade] = count
+	}
+	return counts, rows.Err()
+}
+
+// GenreRatings returns the average rating of each genre over rated entries, highest first.
+// Movies without a genre are grouped under an empty genre. Genres with fewer than minCount
+// rated entries are left out, since a single viewing says little about taste.
+func (db *DB) GenreRatings(ctx context.Context, minCount int) ([]models.GenreRating, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT COALESCE(g.name, ''), AVG(d.rating), COUNT(*)
+		FROM diary_entries d
+		LEFT JOIN movie_genres mg ON mg.movie_id = d.movie_id
+		LEFT JOIN genres g ON g.id = mg.genre_id
+		WHERE d.rating IS NOT NULL
+		GROUP BY g.id
+		HAVING COUNT(*) >= ?
+		ORDER BY AVG(d.rating) DESC, COUNT(*) DESC, g.name
+	`, minCount)
+	if err != nil {
+		return nil, fmt.Errorf("querying genre ratings: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var ratings []models.GenreRating
+	for rows.Next() {
+		var gr models.GenreRating
+		if err := rows.Scan(&gr.Genre, &gr.Average, &gr.Count); err != nil {
+			return nil, fmt.Errorf("scanning genre rating: %w", err)
+		}
+		ratings = append(ratings, gr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating genre ratings: %w", err)
+	}
+	return ratings, nil
+}
+
+// topGenre returns the genre with the most diary entries, breaking ties by name.
+func (db *DB) topGenre(ctx context.Context) (string, error) {
+	var genre string
+	err := db.QueryRowContext(ctx, `
+		SELECT g.name
+		FROM diary_entries d
+		JOIN movie_genres mg ON mg.movie_id = d.movie_id
+		JOIN genres g ON g.id = mg.genre_id
+		GROUP BY g.id
+		ORDER BY COUNT(*) DESC, g.name
+		LIMIT 1
+	`).Scan(&genre)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("querying top genre: %w", err)
+	}
+	return genre, nil
+}
+
+// watchedDates returns the distinct days with at least one entry, most recent first.
+func (db *DB) watchedDates(ctx context.Context) ([]time.Time, error) {
+	rows, err := db.QueryContext(ctx, "SELECT DISTINCT watched_at FROM diary_entries ORDER BY watched_at DESC")
+	if err != nil {
+		return nil, fmt.Errorf("querying watched dates: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var dates []time.Time
+	for rows.Next() {
+		var date time.Time
+		if err := rows.Scan(&date); err != nil {
+			return nil, fmt.Errorf("scanning watched date: %w", err)
+		}
+		dates = append(dates, date)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating watched dates: %w", err)
+	}
+	return dates, nil
+}
+
+// currentStreak counts consecutive days with an entry, given distinct dates sorted most recent first.
+// A streak that ended yesterday is still current, since today's entry may not be written yet.
+// Dates after today are ignored.
+func currentStreak(dates []time.Time, now time.Time) int {
+	today := civilDay(now)
+	for len(dates) > 0 && civilDay(dates[0]).After(today) {
+		dates = dates[1:]
+	}
+	if len(dates) == 0 {
+		return 0
+	}
+
+	day := civilDay(dates[0])
+	if day.Before(today.AddDate(0, 0, -1)) {
+		return 0
+	}
+
+	streak := 0
+	for _, date := range dates {
+		if !civilDay(date).Equal(day) {
+			break
+		}
+		streak++
+		day = day.AddDate(0, 0, -1)
+	}
+	return streak
+}
+
+// civilDay truncates t to midnight UTC of its calendar date, so days compare regardless of time zone.
+func civilDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,313 @@
+package database
+
+import (
+	"context"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pavelanni/movie-journal/internal/models"
+)
+
+// addTestViewing saves a movie released in year and logs a viewing of it, returning the
+// entry ID.
+func addTestViewing(t *testing.T, db *DB, tmdbID int, title string, year int) int64 {
+	t.Helper()
+	ctx := context.Background()
+	movie, err := db.SaveMovie(ctx, models.Movie{TMDBID: tmdbID, Title: title, Year: year})
+	if err != nil {
+		t.Fatalf("saving movie: %v", err)
+	}
+	id, err := db.CreateDiaryEntry(ctx, models.DiaryEntryInput{
+		MovieID:   movie.ID,
+		WatchedAt: time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("creating entry: %v", err)
+	}
+	return id
+}
+
+func TestCountByDecade(t *testing.T) {
+	db := openTestDB(t)
+	addTestViewing(t, db, 1, "Alien", 1979)
+	addTestViewing(t, db, 2, "Aliens", 1986)
+	addTestViewing(t, db, 3, "Heat", 1995)
+	addTestViewing(t, db, 4, "Se7en", 1995)
+	addTestViewing(t, db, 5, "Lost Reel", 0)
+
+	counts, err := db.CountByDecade(context.Background())
+	if err != nil {
+		t.Fatalf("CountByDecade: %v", err)
+	}
+	want := map[int]int{1970: 1, 1980: 1, 1990: 2, 0: 1}
+	if !maps.Equal(counts, want) {
+		t.Errorf("counts = %v, want %v", counts, want)
+	}
+}
+
+func TestListDiaryEntriesFilteredByDecade(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	alien := addTestViewing(t, db, 1, "Alien", 1979)
+	heat := addTestViewing(t, db, 2, "Heat", 1995)
+	// 1990 is the decade's first year and 1999 its last
+	nineties := addTestViewing(t, db, 3, "Goodfellas", 1990)
+	lastNineties := addTestViewing(t, db, 4, "The Matrix", 1999)
+	addTestViewing(t, db, 5, "Gladiator", 2000)
+	unknown := addTestViewing(t, db, 6, "Lost Reel", 0)
+
+	tests := []struct {
+		decade string
+		want   []int64
+	}{
+		{decade: "1970", want: []int64{alien}},
+		{decade: "1990", want: []int64{lastNineties, nineties, heat}},
+		{decade: "1960", want: nil},
+		{decade: models.DecadeUnknown, want: []int64{unknown}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.decade, func(t *testing.T) {
+			entries, err := db.ListDiaryEntriesFiltered(ctx, models.EntryFilter{Decade: tt.decade})
+			if err != nil {
+				t.Fatalf("ListDiaryEntriesFiltered: %v", err)
+			}
+			got := entryIDs(entries)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got entries %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCurrentStreak(t *testing.T) {
+	now := time.Date(2024, time.June, 10, 21, 30, 0, 0, time.UTC)
+	day := func(d int) time.Time { return time.Date(2024, time.June, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name  string
+		dates []time.Time
+		want  int
+	}{
+		{name: "no entries", want: 0},
+		{name: "only today", dates: []time.Time{day(10)}, want: 1},
+		{name: "ending today", dates: []time.Time{day(10), day(9), day(8)}, want: 3},
+		{name: "ending yesterday", dates: []time.Time{day(9), day(8)}, want: 2},
+		{name: "ended two days ago", dates: []time.Time{day(8), day(7), day(6)}, want: 0},
+		{name: "broken by a gap", dates: []time.Time{day(10), day(9), day(7), day(6), day(5)}, want: 2},
+		{name: "future dates ignored", dates: []time.Time{day(12), day(10), day(9)}, want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := currentStreak(tt.dates, now); got != tt.want {
+				t.Errorf("currentStreak = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	// Month boundaries don't break a streak
+	july1 := time.Date(2024, time.July, 1, 8, 0, 0, 0, time.UTC)
+	if got := currentStreak([]time.Time{july1, day(30), day(29)}, july1); got != 3 {
+		t.Errorf("streak across June and July = %d, want 3", got)
+	}
+}
+
+func TestGetStatsWriting(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	movie, err := db.SaveMovie(ctx, models.Movie{TMDBID: 438631, Title: "Dune", Year: 2021})
+	if err != nil {
+		t.Fatalf("saving movie: %v", err)
+	}
+	now := time.Date(2024, time.June, 10, 12, 0, 0, 0, time.UTC)
+	for _, entry := range []struct {
+		notes string
+		day   int
+	}{
+		{day: 10, notes: "Loud and   beautiful."},
+		{day: 9, notes: "Slower the second time, but the\nscore holds up."},
+		{day: 9, notes: ""},
+		{day: 7, notes: "   "},
+	} {
+		_, err := db.CreateDiaryEntry(ctx, models.DiaryEntryInput{
+			MovieID:   movie.ID,
+			WatchedAt: time.Date(2024, time.June, entry.day, 0, 0, 0, 0, time.UTC),
+			Notes:     entry.notes,
+		})
+		if err != nil {
+			t.Fatalf("creating entry: %v", err)
+		}
+	}
+
+	stats, err := db.GetStats(ctx, now)
+	if err != nil {
+		t.Fatalf("GetStats: %v", err)
+	}
+
+	// 3 + 9 words over the two entries with notes
+	if stats.TotalWords != 12 || stats.AverageNoteWords != 6 {
+		t.Errorf("words = %d, average %d, want 12 and 6", stats.TotalWords, stats.AverageNoteWords)
+	}
+	if stats.CurrentStreak != 2 {
+		t.Errorf("streak = %d, want 2", stats.CurrentStreak)
+	}
+	if stats.TotalEntries != 4 {
+		t.Errorf("entries = %d, want 4", stats.TotalEntries)
+	}
+}
+
+func TestMovieAverageRating(t *testing.T) {
+	tests := []struct {
+		name      string
+		ratings   []int
+		want      float64
+		wantCount int
+	}{
+		{name: "several ratings", ratings: []int{3, 4, 0, 4}, want: 11.0 / 3, wantCount: 3},
+		{name: "one rating", ratings: []int{5}, want: 5, wantCount: 1},
+		{name: "all unrated", ratings: []int{0, 0}, want: 0, wantCount: 0},
+		{name: "never watched"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			ctx := context.Background()
+			movie, err := db.SaveMovie(ctx, models.Movie{TMDBID: 438631, Title: "Dune", Year: 2021})
+			if err != nil {
+				t.Fatalf("saving movie: %v", err)
+			}
+			for i, rating := range tt.ratings {
+				_, err := db.CreateDiaryEntry(ctx, models.DiaryEntryInput{
+					MovieID: movie.ID, WatchedAt: time.Date(2024, time.June, 1+i, 0, 0, 0, 0, time.UTC), Rating: rating,
+				})
+				if err != nil {
+					t.Fatalf("creating entry: %v", err)
+				}
+			}
+			// Another movie's ratings don't count
+			addTestEntry(t, db, 949, "Heat")
+
+			average, count, err := db.MovieAverageRating(ctx, movie.ID)
+			if err != nil {
+				t.Fatalf("MovieAverageRating: %v", err)
+			}
+			if math.Abs(average-tt.want) > 1e-9 || count != tt.wantCount {
+				t.Errorf("MovieAverageRating = %v, %d, want %v, %d", average, count, tt.want, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestCountInYear(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	movie, err := db.SaveMovie(ctx, models.Movie{TMDBID: 438631, Title: "Dune", Year: 2021})
+	if err != nil {
+		t.Fatalf("saving movie: %v", err)
+	}
+	// Viewings on either side of both ends of 2024
+	for _, watched := range []time.Time{
+		time.Date(2023, time.December, 31, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, time.December, 31, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		if _, err := db.CreateDiaryEntry(ctx, models.DiaryEntryInput{MovieID: movie.ID, WatchedAt: watched}); err != nil {
+			t.Fatalf("creating entry: %v", err)
+		}
+	}
+
+	tests := []struct {
+		year int
+		want int
+	}{
+		{year: 2023, want: 1},
+		{year: 2024, want: 3},
+		{year: 2025, want: 1},
+		{year: 2026, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.year), func(t *testing.T) {
+			got, err := db.CountInYear(ctx, tt.year)
+			if err != nil {
+				t.Fatalf("CountInYear(%d): %v", tt.year, err)
+			}
+			if got != tt.want {
+				t.Errorf("CountInYear(%d) = %d, want %d", tt.year, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenreRatings(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	movies := []struct {
+		title   string
+		genres  []string
+		ratings []int
+	}{
+		{title: "Dune", genres: []string{"Science Fiction", "Adventure"}, ratings: []int{5, 4}},
+		{title: "Arrival", genres: []string{"Science Fiction"}, ratings: []int{4}},
+		// The unrated viewing doesn't drag the average down
+		{title: "Heat", genres: []string{"Crime"}, ratings: []int{3, 3, 0}},
+		{title: "Untitled", ratings: []int{2, 2}},
+	}
+	for i, m := range movies {
+		movie, err := db.SaveMovie(ctx, models.Movie{TMDBID: i + 1, Title: m.title, Year: 2000})
+		if err != nil {
+			t.Fatalf("saving movie: %v", err)
+		}
+		if err := db.SetMovieGenres(ctx, movie.ID, m.genres); err != nil {
+			t.Fatalf("setting genres: %v", err)
+		}
+		for _, rating := range m.ratings {
+			if _, err := db.CreateDiaryEntry(ctx, models.DiaryEntryInput{
+				MovieID: movie.ID, WatchedAt: time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC), Rating: rating,
+			}); err != nil {
+				t.Fatalf("creating entry: %v", err)
+			}
+		}
+	}
+
+	tests := []struct {
+		name     string
+		want     []models.GenreRating
+		minCount int
+	}{
+		{name: "every genre", minCount: 1, want: []models.GenreRating{
+			{Genre: "Adventure", Average: 4.5, Count: 2},
+			{Genre: "Science Fiction", Average: 13.0 / 3, Count: 3},
+			{Genre: "Crime", Average: 3, Count: 2},
+			{Genre: "", Average: 2, Count: 2},
+		}},
+		{name: "min count", minCount: 3, want: []models.GenreRating{
+			{Genre: "Science Fiction", Average: 13.0 / 3, Count: 3},
+		}},
+		{name: "none with enough", minCount: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := db.GenreRatings(ctx, tt.minCount)
+			if err != nil {
+				t.Fatalf("GenreRatings: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("GenreRatings(%d) = %+v, want %+v", tt.minCount, got, tt.want)
+			}
+			for i, want := range tt.want {
+				if got[i].Genre != want.Genre || got[i].Count != want.Count || math.Abs(got[i].Average-want.Average) > 1e-9 {
+					t.Errorf("GenreRatings(%d)[%d] = %+v, want %+v", tt.minCount, i, got[i], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"path"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates spans for database calls. It's a no-op unless tracing is configured.
+var tracer = otel.Tracer("github.com/pavelanni/movie-journal/internal/database")
+
+// querier runs statements; it's implemented by both *sql.DB and *sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// runner runs traced statements; it's implemented by both *DB and *Tx, so helpers can
+// run inside or outside a transaction.
+type runner interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *Row
+}
+
+// Tx is a database transaction whose statements are traced and timed like the DB's own.
+type Tx struct {
+	*sql.Tx
+	db *DB
+}
+
+// Rows is the result of a traced query. Its span and timing run until it's closed, so
+// they cover iterating over the rows as well as running the query.
+type Rows struct {
+	*sql.Rows
+	timer *queryTimer
+}
+
+// Close closes the rows and ends the query's span.
+func (r *Rows) Close() error {
+	err := r.Rows.Close()
+	r.timer.stop(r.Rows.Err())
+	return err
+}
+
+// Row is the result of a traced single-row query. Its span and timing run until it's
+// scanned, since that's when SQLite does the work.
+type Row struct {
+	*sql.Row
+	timer *queryTimer
+}
+
+// Scan copies the row into dest and ends the query's span.
+func (r *Row) Scan(dest ...any) error {
+	err := r.Row.Scan(dest...)
+	if errors.Is(err, sql.ErrNoRows) {
+		r.timer.stop(nil)
+	} else {
+		r.timer.stop(err)
+	}
+	return err
+}
+
+// ExecContext executes a statement inside a tracing span.
+func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return db.exec(ctx, db.DB, query, args)
+}
+
+// QueryContext runs a query inside a tracing span. The span ends when the rows are closed.
+func (db *DB) QueryContext(ctx context.Context, query string, args ...any) (*Rows, error) {
+	return db.query(ctx, db.DB, query, args)
+}
+
+// QueryRowContext runs a single-row query inside a tracing span. The span ends when the
+// row is scanned.
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) *Row {
+	return db.queryRow(ctx, db.DB, query, args)
+}
+
+// BeginTx starts a transaction inside a tracing span.
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	ctx, span := startQuerySpan(ctx, "BEGIN")
+	defer span.End()
+
+	tx, err := db.DB.BeginTx(ctx, opts)
+	recordError(span, err)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, db: db}, nil
+}
+
+// ExecContext executes a statement in the transaction inside a tracing span.
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return tx.db.exec(ctx, tx.Tx, query, args)
+}
+
+// QueryContext runs a query in the transaction inside a tracing span. The span ends when
+// the rows are closed.
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...any) (*Rows, error) {
+	return tx.db.query(ctx, tx.Tx, query, args)
+}
+
+// QueryRowContext runs a single-row query in the transaction inside a tracing span. The
+// span ends when the row is scanned.
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...any) *Row {
+	return tx.db.queryRow(ctx, tx.Tx, query, args)
+}
+
+// exec executes a statement on q, tracing and timing it.
+func (db *DB) exec(ctx context.Context, q querier, query string, args []any) (sql.Result, error) {
+	ctx, timer := db.startQuery(ctx, query)
+	result, err := q.ExecContext(ctx, query, args...)
+	timer.stop(err)
+	return result, err
+}
+
+// query runs a query on q. The rows carry its timer, which stops when they are closed.
+func (db *DB) query(ctx context.Context, q querier, query string, args []any) (*Rows, error) {
+	ctx, timer := db.startQuery(ctx, query)
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		timer.stop(err)
+		return nil, err
+	}
+	return &Rows{Rows: rows, timer: timer}, nil
+}
+
+// queryRow runs a single-row query on q. The row carries its timer, which stops when it's
+// scanned.
+func (db *DB) queryRow(ctx context.Context, q querier, query string, args []any) *Row {
+	ctx, timer := db.startQuery(ctx, query)
+	return &Row{Row: q.QueryRowContext(ctx, query, args...), timer: timer}
+}
+
+// queryTimer traces and times a statement from when it starts until its results are done
+// with.
+type queryTimer struct {
+	start   time.Time
+	span    trace.Span
+	db      *DB
+	query   string
+	callers []uintptr
+	once    sync.Once
+}
+
+// startQuery starts timing a statement and its span. It's called through one of the
+// exec, query or queryRow helpers by an ExecContext, QueryContext or QueryRowContext
+// wrapper, so the DB method that ran the statement is four frames up.
+func (db *DB) startQuery(ctx context.Context, query string) (context.Context, *queryTimer) {
+	ctx, span := startQuerySpan(ctx, query)
+	timer := &queryTimer{db: db, query: query, span: span, start: time.Now()}
+	// Only program counters are kept; they're resolved to a name if the query turns out
+	// slow. A few frames are needed in case the method was inlined.
+	pcs := make([]uintptr, 4)
+	timer.callers = pcs[:runtime.Callers(4, pcs)]
+	return ctx, timer
+}
+
+// stop ends the statement's span, recording err, and logs the statement if it was slow.
+// Only the first call has any effect.
+func (t *queryTimer) stop(err error) {
+	t.once.Do(func() {
+		recordError(t.span, err)
+		t.span.End()
+		t.db.logSlowQuery(t.query, time.Since(t.start), t.callers)
+	})
+}
+
+// logSlowQuery warns about a query that ran longer than the slow query threshold, naming
+// the DB method that ran it.
+func (db *DB) logSlowQuery(query string, elapsed time.Duration, callers []uintptr) {
+	if db.slowQuery <= 0 || elapsed < db.slowQuery {
+		return
+	}
+
+	caller := "unknown"
+	if len(callers) > 0 {
+		frame, _ := runtime.CallersFrames(callers).Next()
+		if frame.Function != "" {
+			caller = path.Base(frame.Function)
+		}
+	}
+	slog.Warn("Slow query",
+		slog.String("method", caller),
+		slog.String("operation", sqlOperation(query)),
+		slog.Duration("duration", elapsed),
+		slog.String("query", statementSnippet(query)),
+	)
+}
+
+// startQuerySpan starts a span named after the query's SQL operation.
+// Only the operation is recorded; the query text and arguments are left out.
+func startQuerySpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	operation := sqlOperation(query)
+	return tracer.Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system.name", "sqlite"),
+			attribute.String("db.operation.name", operation),
+		))
+}
+
+// sqlOperation returns the leading keyword of a query, such as SELECT or INSERT.
+func sqlOperation(query string) string {
+	fields := strings.Fields(stripComments(query))
+	if len(fields) == 0 {
+		return "UNKNOWN"
+	}
+	return strings.ToUpper(fields[0])
+}
+
+// recordError marks the span as failed if err is set.
+func recordError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
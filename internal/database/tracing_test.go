@@ -0,0 +1,201 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// spanCounter is a span processor that counts the spans ended.
+type spanCounter struct {
+	ended atomic.Int64
+}
+
+func (c *spanCounter) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+func (c *spanCounter) OnEnd(sdktrace.ReadOnlySpan)                     { c.ended.Add(1) }
+func (c *spanCounter) Shutdown(context.Context) error                  { return nil }
+func (c *spanCounter) ForceFlush(context.Context) error                { return nil }
+
+var (
+	querySpans         spanCounter
+	installSpanCounter sync.Once
+)
+
+// countQueries returns a function reporting how many statements have been run since it
+// was called, counted by their spans. The tracer provider it installs is global and
+// stays in place for the rest of the tests, so only run one test counting at a time.
+func countQueries() func() int64 {
+	installSpanCounter.Do(func() {
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(&querySpans)))
+	})
+	start := querySpans.ended.Load()
+	return func() int64 { return querySpans.ended.Load() - start }
+}
+
+// slowQueryWarning is the part of a logged slow query warning the tests check.
+type slowQueryWarning struct {
+	Msg      string        `json:"msg"`
+	Method   string        `json:"method"`
+	Query    string        `json:"query"`
+	Duration time.Duration `json:"duration"`
+}
+
+// captureSlowQueries sends warnings from the default logger to the returned function,
+// which decodes the slow query warnings logged so far.
+func captureSlowQueries(t *testing.T) func() []slowQueryWarning {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn})))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	return func() []slowQueryWarning {
+		var warnings []slowQueryWarning
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			var warning slowQueryWarning
+			if line == "" || json.Unmarshal([]byte(line), &warning) != nil || warning.Msg != "Slow query" {
+				continue
+			}
+			warnings = append(warnings, warning)
+		}
+		return warnings
+	}
+}
+
+// countQuery counts to n the slow way, taking tens of milliseconds per 100,000.
+const countQuery = `
+	WITH RECURSIVE counter(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM counter WHERE i < ?)
+	SELECT COUNT(*) FROM counter`
+
+func TestSlowQueryInTransaction(t *testing.T) {
+	db := openTestDB(t)
+	db.SetSlowQueryThreshold(50 * time.Millisecond)
+	warnings := captureSlowQueries(t)
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	var count int
+	if err := tx.QueryRowContext(ctx, countQuery, 500_000).Scan(&count); err != nil {
+		t.Fatalf("counting: %v", err)
+	}
+
+	got := warnings()
+	if len(got) != 1 {
+		t.Fatalf("got %d slow query warnings, want 1", len(got))
+	}
+	if got[0].Duration < 50*time.Millisecond {
+		t.Errorf("duration = %s, want at least the threshold", got[0].Duration)
+	}
+	if !strings.Contains(got[0].Method, "TestSlowQueryInTransaction") {
+		t.Errorf("method = %q, want the test that ran the query", got[0].Method)
+	}
+	if !strings.Contains(got[0].Query, "WITH RECURSIVE counter") {
+		t.Errorf("query = %q, want the counting query", got[0].Query)
+	}
+}
+
+func TestSlowQueryIncludesIteration(t *testing.T) {
+	db := openTestDB(t)
+	db.SetSlowQueryThreshold(50 * time.Millisecond)
+	warnings := captureSlowQueries(t)
+
+	rows, err := db.QueryContext(context.Background(), "SELECT 1 UNION ALL SELECT 2")
+	if err != nil {
+		t.Fatalf("QueryContext: %v", err)
+	}
+	for rows.Next() {
+		time.Sleep(30 * time.Millisecond)
+	}
+	if len(warnings()) != 0 {
+		t.Error("warned before the rows were closed")
+	}
+	_ = rows.Close()
+	_ = rows.Close()
+
+	if got := warnings(); len(got) != 1 {
+		t.Errorf("got %d slow query warnings after closing twice, want 1", len(got))
+	}
+}
+
+func TestSlowQueryMethod(t *testing.T) {
+	tests := []struct {
+		run        func(ctx context.Context, db *DB) error
+		name       string
+		wantMethod string
+		threshold  time.Duration
+	}{
+		{
+			name:      "single row",
+			threshold: time.Nanosecond,
+			run: func(ctx context.Context, db *DB) error {
+				_, err := db.CountDiaryEntries(ctx)
+				return err
+			},
+			wantMethod: "database.(*DB).CountDiaryEntries",
+		},
+		{
+			name:      "rows",
+			threshold: time.Nanosecond,
+			run: func(ctx context.Context, db *DB) error {
+				_, err := db.ListDiaryEntries(ctx)
+				return err
+			},
+			wantMethod: "database.(*DB).listDiaryEntries",
+		},
+		{
+			name:      "statement in a transaction",
+			threshold: time.Nanosecond,
+			run: func(ctx context.Context, db *DB) error {
+				_, err := db.DeleteEntries(ctx, []int64{1})
+				return err
+			},
+			wantMethod: "database.(*DB).DeleteEntries",
+		},
+		{
+			name:      "fast query",
+			threshold: time.Hour,
+			run: func(ctx context.Context, db *DB) error {
+				_, err := db.CountDiaryEntries(ctx)
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			db.SetSlowQueryThreshold(tt.threshold)
+			warnings := captureSlowQueries(t)
+
+			if err := tt.run(context.Background(), db); err != nil {
+				t.Fatal(err)
+			}
+
+			got := warnings()
+			if tt.wantMethod == "" {
+				if len(got) != 0 {
+					t.Errorf("got %d slow query warnings, want none", len(got))
+				}
+				return
+			}
+			if len(got) != 1 {
+				t.Fatalf("got %d slow query warnings, want 1", len(got))
+			}
+			if got[0].Method != tt.wantMethod {
+				t.Errorf("method = %q, want %q", got[0].Method, tt.wantMethod)
+			}
+		})
+	}
+}
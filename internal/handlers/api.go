@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pavelanni/movie-journal/internal/database"
+	"github.com/pavelanni/movie-journal/internal/journal"
+	"github.com/pavelanni/movie-journal/internal/models"
+)
+
+// apiPageSize is the number of entries the entries API returns at a time unless
+// ?per_page= asks for another size.
+const apiPageSize = 50
+
+// entriesPage is the JSON body of a page of diary entries. Next is the cursor to request
+// the following page with, and is empty on the last page.
+type entriesPage struct {
+	Next    string              `json:"next,omitempty"`
+	Entries []models.DiaryEntry `json:"entries"`
+}
+
+// ListEntries returns diary entries as JSON, filtered and sorted by the same query
+// parameters as the recent entries list. Later pages are requested with ?after= set to
+// the previous page's next cursor, so entries added meanwhile don't shift them.
+func (h *Handlers) ListEntries(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	page := database.EntryPage{Limit: parsePerPage(query.Get("per_page"))}
+	if page.Limit == 0 {
+		page.Limit = apiPageSize
+	}
+	if raw := query.Get("after"); raw != "" {
+		cursor, err := database.ParseEntryCursor(raw)
+		if err != nil {
+			errorPage(w, r, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		page.After = &cursor
+	}
+
+	entries, next, err := h.db.ListDiaryEntriesPage(r.Context(), parseEntryFilter(query, time.Now()), page)
+	if err != nil {
+		slog.Error("Failed to list diary entries", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to load entries")
+		return
+	}
+	body := entriesPage{Entries: entries}
+	if body.Entries == nil {
+		body.Entries = []models.DiaryEntry{}
+	}
+	if next != nil {
+		body.Next = next.String()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// maxEntryBodyBytes caps the size of an entry creation request body.
+const maxEntryBodyBytes = 64 << 10
+
+// entryRequest is the JSON body of an entry creation request. The fields are named after
+// the new entry form's inputs and validated the same way.
+type entryRequest struct {
+	MovieTitle      string `json:"movie_title"`
+	WatchedDate     string `json:"watched_date"`
+	WatchedLocation string `json:"watched_location"`
+	Format          string `json:"format"`
+	Notes           string `json:"notes"`
+	WatchedWith     string `json:"watched_with"`
+	MovieYear       int    `json:"movie_year"`
+	Rating          int    `json:"rating"`
+}
+
+// form returns the request as the values of a new entry form. Zero numbers are left out,
+// as they would be by an empty input.
+func (e entryRequest) form() url.Values {
+	form := url.Values{
+		"movie_title":      {e.MovieTitle},
+		"watched_date":     {e.WatchedDate},
+		"watched_location": {e.WatchedLocation},
+		"format":           {e.Format},
+		"notes":            {e.Notes},
+		"watched_with":     {e.WatchedWith},
+	}
+	if e.MovieYear != 0 {
+		form.Set("movie_year", strconv.Itoa(e.MovieYear))
+	}
+	if e.Rating != 0 {
+		form.Set("rating", strconv.Itoa(e.Rating))
+	}
+	return form
+}
+
+// CreateEntry saves a new diary entry from a JSON body and returns it as JSON with status
+// 201. Invalid input gets a 422 naming the invalid fields. A request repeating an earlier
+// Idempotency-Key gets the entry that request created, with status 200, as long as it
+// hasn't been deleted since.
+func (h *Handlers) CreateEntry(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get(idempotencyKeyHeader)
+	if len(key) > maxIdempotencyKeyLength {
+		errorPage(w, r, http.StatusBadRequest, "Idempotency key is too long")
+		return
+	}
+	if key != "" {
+		id, err := h.db.IdempotentEntry(r.Context(), key, time.Now().Add(-idempotencyTTL))
+		if err == nil {
+			h.writeEntry(w, r, http.StatusOK, id)
+			return
+		}
+		if !errors.Is(err, database.ErrNotFound) {
+			slog.Error("Failed to look up idempotency key", slog.String("error", err.Error()))
+			errorPage(w, r, http.StatusInternalServerError, "Failed to save entry")
+			return
+		}
+	}
+
+	var body entryRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxEntryBodyBytes)).Decode(&body); err != nil {
+		errorPage(w, r, http.StatusBadRequest, "Request body must be a JSON entry")
+		return
+	}
+
+	// Entries are often logged right after watching, so the date can be left out
+	input, movie, err := h.journal.ParseEntry(r.Context(), body.form(), time.Now(), true)
+	if err == nil {
+		var id int64
+		var created bool
+		if id, created, err = h.saveEntry(r.Context(), key, input); err == nil {
+			status := http.StatusOK
+			if created {
+				h.prefetchMovie(r.Context(), *movie)
+				status = http.StatusCreated
+			}
+			h.writeEntry(w, r, status, id)
+			return
+		}
+	}
+	if verr := journal.FormErrors(err); verr != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_ = json.NewEncoder(w).Encode(verr)
+		return
+	}
+	slog.Error("Failed to create diary entry", slog.String("error", err.Error()))
+	errorPage(w, r, http.StatusInternalServerError, "Failed to save entry")
+}
+
+// writeEntry responds with the diary entry as JSON.
+func (h *Handlers) writeEntry(w http.ResponseWriter, r *http.Request, status int, id int64) {
+	entry, err := h.db.GetDiaryEntry(r.Context(), id)
+	if err != nil {
+		slog.Error("Failed to get diary entry", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to load entry")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(entry)
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/pavelanni/movie-journal/internal/models"
+)
+
+func TestListEntries(t *testing.T) {
+	h, db := newTestHandlers(t)
+	// All watched on the same day, so they're ordered by ID
+	first := addTestEntry(t, db, 1, "Alien")
+	second := addTestEntry(t, db, 2, "Aliens")
+	third := addTestEntry(t, db, 3, "Alien 3")
+
+	get := func(query url.Values) entriesPage {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodGet, "/api/entries?"+query.Encode(), nil)
+		w := httptest.NewRecorder()
+		h.ListEntries(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+		}
+		var page entriesPage
+		if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		return page
+	}
+
+	page := get(url.Values{"per_page": {"2"}})
+	if got := pageIDs(page); !slices.Equal(got, []int64{third, second}) {
+		t.Errorf("first page = %v, want %v", got, []int64{third, second})
+	}
+	if page.Next == "" {
+		t.Fatal("first page has no next cursor")
+	}
+
+	page = get(url.Values{"per_page": {"2"}, "after": {page.Next}})
+	if got := pageIDs(page); !slices.Equal(got, []int64{first}) {
+		t.Errorf("second page = %v, want %v", got, []int64{first})
+	}
+	if page.Next != "" {
+		t.Errorf("last page has next cursor %q", page.Next)
+	}
+}
+
+func TestListEntriesInvalidCursor(t *testing.T) {
+	h, _ := newTestHandlers(t)
+
+	r := httptest.NewRequest(http.MethodGet, "/api/entries?after=bogus", nil)
+	w := httptest.NewRecorder()
+
+	h.ListEntries(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+// pageIDs returns the IDs of the entries on the page, in order.
+func pageIDs(page entriesPage) []int64 {
+	var ids []int64
+	for i := range page.Entries {
+		ids = append(ids, page.Entries[i].ID)
+	}
+	return ids
+}
+
+// postEntry posts the JSON entry to the entries API with the idempotency key, if any.
+func postEntry(t *testing.T, h *Handlers, body, key string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/api/entries", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		r.Header.Set(idempotencyKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	h.CreateEntry(w, r)
+	return w
+}
+
+// decodeEntry decodes the entry in the response, failing the test unless it has the status.
+func decodeEntry(t *testing.T, w *httptest.ResponseRecorder, status int) models.DiaryEntry {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("status = %d, want %d: %s", w.Code, status, w.Body)
+	}
+	var entry models.DiaryEntry
+	if err := json.NewDecoder(w.Body).Decode(&entry); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	return entry
+}
+
+func TestCreateEntryIdempotencyKey(t *testing.T) {
+	h, db := newTestHandlers(t)
+	addTestEntry(t, db, 438631, "Dune")
+	const body = `{"movie_title": "Dune", "watched_date": "2024-07-01", "rating": 5}`
+
+	first := decodeEntry(t, postEntry(t, h, body, "first"), http.StatusCreated)
+	if first.Rating != 5 || first.Movie == nil || first.Movie.Title != "Dune" {
+		t.Errorf("created entry = %+v, want Dune rated 5", first)
+	}
+
+	repeated := decodeEntry(t, postEntry(t, h, body, "first"), http.StatusOK)
+	if repeated.ID != first.ID {
+		t.Errorf("repeating the key returned entry %d, want %d", repeated.ID, first.ID)
+	}
+
+	second := decodeEntry(t, postEntry(t, h, body, "second"), http.StatusCreated)
+	if second.ID == first.ID {
+		t.Errorf("a new key returned the earlier entry %d", first.ID)
+	}
+
+	count, err := db.CountDiaryEntries(context.Background())
+	if err != nil {
+		t.Fatalf("counting entries: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("diary has %d entries, want 3", count)
+	}
+}
+
+func TestCreateEntryReplayAfterDelete(t *testing.T) {
+	h, db := newTestHandlers(t)
+	addTestEntry(t, db, 438631, "Dune")
+	const body = `{"movie_title": "Dune", "watched_date": "2024-07-01"}`
+
+	first := decodeEntry(t, postEntry(t, h, body, "retry"), http.StatusCreated)
+	if _, err := db.DeleteEntries(context.Background(), []int64{first.ID}); err != nil {
+		t.Fatalf("deleting entry: %v", err)
+	}
+
+	// Deleting the entry forgets its key, so the retry creates the entry again rather than
+	// pointing at the deleted one
+	again := decodeEntry(t, postEntry(t, h, body, "retry"), http.StatusCreated)
+	if again.ID == first.ID {
+		t.Errorf("retry returned the deleted entry %d", first.ID)
+	}
+}
+
+func TestCreateEntryInvalid(t *testing.T) {
+	h, db := newTestHandlers(t)
+	addTestEntry(t, db, 438631, "Dune")
+
+	w := postEntry(t, h, `{"movie_title": "Dune", "rating": 7}`, "")
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusUnprocessableEntity, w.Body)
+	}
+	var verr models.ValidationError
+	if err := json.NewDecoder(w.Body).Decode(&verr); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if _, ok := verr.Fields["rating"]; !ok {
+		t.Errorf("errors = %v, want one for rating", verr.Fields)
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/pavelanni/movie-journal/internal/database"
+	"github.com/pavelanni/movie-journal/internal/models"
+	"github.com/pavelanni/movie-journal/templates"
+)
+
+// Compare renders the diary entries given by the a and b query parameters side by side.
+func (h *Handlers) Compare(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	idA, errA := strconv.ParseInt(query.Get("a"), 10, 64)
+	idB, errB := strconv.ParseInt(query.Get("b"), 10, 64)
+	if errA != nil || errB != nil {
+		errorPage(w, r, http.StatusBadRequest, "Pick two entries to compare")
+		return
+	}
+	if idA == idB {
+		errorPage(w, r, http.StatusBadRequest, "Pick two different entries to compare")
+		return
+	}
+
+	a, err := h.db.GetDiaryEntry(r.Context(), idA)
+	var b *models.DiaryEntry
+	if err == nil {
+		b, err = h.db.GetDiaryEntry(r.Context(), idB)
+	}
+	if errors.Is(err, database.ErrNotFound) {
+		errorPage(w, r, http.StatusNotFound, "Entry not found")
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to get diary entry", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to load entries")
+		return
+	}
+
+	err = templates.Compare(*a, *b).Render(r.Context(), w)
+	if err != nil {
+		errorPage(w, r, http.StatusInternalServerError, "Failed to render template")
+		return
+	}
+}
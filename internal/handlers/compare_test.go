@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pavelanni/movie-journal/internal/models"
+)
+
+// compare requests the comparison page for the entries with IDs a and b.
+func compare(h *Handlers, a, b string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.Compare(w, httptest.NewRequest(http.MethodGet, "/compare?a="+a+"&b="+b, nil))
+	return w
+}
+
+func TestCompare(t *testing.T) {
+	h, db := newTestHandlers(t)
+	dune := addRatedEntry(t, db, 438631, "Dune", 5)
+	heat := addRatedEntry(t, db, 949, "Heat", 3)
+	if _, err := db.CreateLookups(context.Background(), heat, []models.LookupInput{
+		{Question: "Where is the diner?", Answer: "Kate Mantilini"},
+	}); err != nil {
+		t.Fatalf("creating lookups: %v", err)
+	}
+
+	w := compare(h, strconv.FormatInt(dune, 10), strconv.FormatInt(heat, 10))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	body := w.Body.String()
+	for _, want := range []string{"Dune", "Heat", "Where is the diner?", "Kate Mantilini", "0 lookups", "1 lookup"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("comparison doesn't contain %s", want)
+		}
+	}
+	if dunePos, heatPos := strings.Index(body, ">Dune<"), strings.Index(body, ">Heat<"); dunePos < 0 || heatPos < dunePos {
+		t.Errorf("entry a (Dune) isn't in the first column")
+	}
+}
+
+func TestCompareInvalid(t *testing.T) {
+	h, db := newTestHandlers(t)
+	id := strconv.FormatInt(addTestEntry(t, db, 438631, "Dune"), 10)
+
+	tests := []struct {
+		name       string
+		a          string
+		b          string
+		wantStatus int
+	}{
+		{name: "missing id", a: id, b: "", wantStatus: http.StatusBadRequest},
+		{name: "not a number", a: "dune", b: id, wantStatus: http.StatusBadRequest},
+		{name: "same entry", a: id, b: id, wantStatus: http.StatusBadRequest},
+		{name: "first not found", a: "999", b: id, wantStatus: http.StatusNotFound},
+		{name: "second not found", a: id, b: "999", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := compare(h, tt.a, tt.b); w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import "time"
+
+// rangeForPreset returns the [from, to) date range for a quick filter preset
+// ("today", "week", "month" or "year") relative to now. The bounds are UTC midnights on
+// now's calendar date in its own location, matching how watched dates are parsed.
+// Weeks start on Monday. ok is false for unknown presets.
+func rangeForPreset(preset string, now time.Time) (from, to time.Time, ok bool) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch preset {
+	case "today":
+		return today, today.AddDate(0, 0, 1), true
+	case "week":
+		offset := (int(today.Weekday()) + 6) % 7 // days since Monday
+		from = today.AddDate(0, 0, -offset)
+		return from, from.AddDate(0, 0, 7), true
+	case "month":
+		from = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return from, from.AddDate(0, 1, 0), true
+	case "year":
+		from = time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+		return from, from.AddDate(1, 0, 0), true
+	}
+
+	return time.Time{}, time.Time{}, false
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRangeForPreset(t *testing.T) {
+	// West of UTC, late evening local time is already the next day in UTC
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data not available: %v", err)
+	}
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		now      time.Time
+		wantFrom time.Time
+		wantTo   time.Time
+		name     string
+		preset   string
+	}{
+		{
+			name:     "today on the last day of the month",
+			preset:   "today",
+			now:      time.Date(2024, time.January, 31, 23, 30, 0, 0, newYork),
+			wantFrom: day(2024, time.January, 31),
+			wantTo:   day(2024, time.February, 1),
+		},
+		{
+			name:     "today on new year's eve",
+			preset:   "today",
+			now:      time.Date(2024, time.December, 31, 22, 0, 0, 0, newYork),
+			wantFrom: day(2024, time.December, 31),
+			wantTo:   day(2025, time.January, 1),
+		},
+		{
+			name:     "week spanning the year end",
+			preset:   "week",
+			now:      time.Date(2025, time.January, 1, 9, 0, 0, 0, time.UTC),
+			wantFrom: day(2024, time.December, 30),
+			wantTo:   day(2025, time.January, 6),
+		},
+		{
+			name:     "week on a Sunday evening",
+			preset:   "week",
+			now:      time.Date(2024, time.June, 9, 23, 0, 0, 0, newYork),
+			wantFrom: day(2024, time.June, 3),
+			wantTo:   day(2024, time.June, 10),
+		},
+		{
+			name:     "month on its last evening",
+			preset:   "month",
+			now:      time.Date(2024, time.February, 29, 23, 59, 0, 0, newYork),
+			wantFrom: day(2024, time.February, 1),
+			wantTo:   day(2024, time.March, 1),
+		},
+		{
+			name:     "month of December",
+			preset:   "month",
+			now:      time.Date(2024, time.December, 15, 12, 0, 0, 0, time.UTC),
+			wantFrom: day(2024, time.December, 1),
+			wantTo:   day(2025, time.January, 1),
+		},
+		{
+			name:     "year on its last evening",
+			preset:   "year",
+			now:      time.Date(2024, time.December, 31, 23, 0, 0, 0, newYork),
+			wantFrom: day(2024, time.January, 1),
+			wantTo:   day(2025, time.January, 1),
+		},
+		{
+			name:     "year on its first morning",
+			preset:   "year",
+			now:      time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+			wantFrom: day(2025, time.January, 1),
+			wantTo:   day(2026, time.January, 1),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to, ok := rangeForPreset(tt.preset, tt.now)
+			if !ok {
+				t.Fatalf("rangeForPreset(%q) not ok", tt.preset)
+			}
+			if !from.Equal(tt.wantFrom) || !to.Equal(tt.wantTo) {
+				t.Errorf("range = [%s, %s), want [%s, %s)", from, to, tt.wantFrom, tt.wantTo)
+			}
+		})
+	}
+}
+
+func TestRangeForPresetUnknown(t *testing.T) {
+	for _, preset := range []string{"", "decade", "Today"} {
+		if _, _, ok := rangeForPreset(preset, time.Now()); ok {
+			t.Errorf("rangeForPreset(%q) ok, want unknown", preset)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pavelanni/movie-journal/internal/database"
+)
+
+// draftCookie is the name of the cookie holding the ID of the user's new entry draft.
+const draftCookie = "mj_draft"
+
+// draftFields lists the new entry form fields kept in a draft.
+var draftFields = []string{"watched_date", "movie_title", "watched_location", "format", "watched_with", "rating", "notes"}
+
+// SaveDraft autosaves the in-progress new entry form so it survives a lost tab or a crash.
+// The draft is keyed by a cookie, which is issued on the first save.
+func (h *Handlers) SaveDraft(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		errorPage(w, r, http.StatusBadRequest, "Failed to parse form")
+		return
+	}
+
+	id := draftID(r)
+	if id == "" {
+		var err error
+		if id, err = newDraftID(); err != nil {
+			slog.Error("Failed to generate draft ID", slog.String("error", err.Error()))
+			errorPage(w, r, http.StatusInternalServerError, "Failed to save draft")
+			return
+		}
+	}
+
+	form := url.Values{}
+	for _, field := range draftFields {
+		if value := r.PostForm.Get(field); value != "" {
+			form.Set(field, value)
+		}
+	}
+
+	if err := h.db.SaveDraft(r.Context(), id, form); err != nil {
+		slog.Error("Failed to save draft", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to save draft")
+		return
+	}
+
+	// Saving is frequent enough to double as the cleanup for abandoned drafts
+	if h.draftTTL > 0 {
+		if _, err := h.db.DeleteDraftsBefore(r.Context(), time.Now().Add(-h.draftTTL)); err != nil {
+			slog.Warn("Failed to delete expired drafts", slog.String("error", err.Error()))
+		}
+	}
+
+	h.setDraftCookie(w, id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// loadDraft returns the user's unexpired draft, or nil if there isn't one.
+func (h *Handlers) loadDraft(r *http.Request) url.Values {
+	id := draftID(r)
+	if id == "" {
+		return nil
+	}
+
+	var notBefore time.Time
+	if h.draftTTL > 0 {
+		notBefore = time.Now().Add(-h.draftTTL)
+	}
+	form, err := h.db.GetDraft(r.Context(), id, notBefore)
+	if err != nil {
+		if !errors.Is(err, database.ErrNotFound) {
+			slog.Error("Failed to load draft", slog.String("error", err.Error()))
+		}
+		return nil
+	}
+	return form
+}
+
+// discardDraft deletes the user's draft and its cookie once the entry has been saved.
+func (h *Handlers) discardDraft(w http.ResponseWriter, r *http.Request) {
+	id := draftID(r)
+	if id == "" {
+		return
+	}
+	if err := h.db.DeleteDraft(r.Context(), id); err != nil {
+		slog.Warn("Failed to delete draft", slog.String("error", err.Error()))
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     draftCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// setDraftCookie stores the draft ID in a cookie that lasts as long as the draft does.
+func (h *Handlers) setDraftCookie(w http.ResponseWriter, id string) {
+	maxAge := preferencesMaxAge
+	if h.draftTTL > 0 {
+		maxAge = int(h.draftTTL.Seconds())
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     draftCookie,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// draftID returns the draft ID from the request's cookie, or "" if there isn't a valid one.
+func draftID(r *http.Request) string {
+	cookie, err := r.Cookie(draftCookie)
+	if err != nil {
+		return ""
+	}
+	if _, err := hex.DecodeString(cookie.Value); err != nil || len(cookie.Value) != 32 {
+		return ""
+	}
+	return cookie.Value
+}
+
+// newDraftID returns a random draft ID.
+func newDraftID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pavelanni/movie-journal/internal/database"
+)
+
+// saveDraftForm posts form to SaveDraft with the cookie, if any, and returns the draft cookie.
+func saveDraftForm(t *testing.T, h *Handlers, form url.Values, cookie *http.Cookie) *http.Cookie {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/diary/draft", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	h.SaveDraft(w, r)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+	for _, c := range w.Result().Cookies() {
+		if c.Name == draftCookie {
+			return c
+		}
+	}
+	t.Fatal("response doesn't set the draft cookie")
+	return nil
+}
+
+// newEntryForm renders the new entry form with the draft cookie.
+func newEntryForm(h *Handlers, cookie *http.Cookie) string {
+	r := httptest.NewRequest(http.MethodGet, "/diary/new", nil)
+	r.Header.Set("HX-Request", "true")
+	r.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	h.NewDiaryEntryForm(w, r)
+	return w.Body.String()
+}
+
+func TestDraftRestoredUntilSubmitted(t *testing.T) {
+	h, db := newTestHandlers(t)
+	addTestEntry(t, db, 438631, "Dune")
+
+	cookie := saveDraftForm(t, h, url.Values{"movie_title": {"Dune"}, "notes": {"Half-written thoughts"}}, nil)
+	// Later saves keep the same draft
+	again := saveDraftForm(t, h, url.Values{"movie_title": {"Dune"}, "notes": {"Fully written thoughts"}}, cookie)
+	if again.Value != cookie.Value {
+		t.Errorf("draft ID changed from %q to %q", cookie.Value, again.Value)
+	}
+
+	if form := newEntryForm(h, cookie); !strings.Contains(form, "Fully written thoughts") {
+		t.Errorf("new entry form doesn't restore the draft:\n%s", form)
+	}
+
+	form := url.Values{"movie_title": {"Dune"}, "watched_date": {"2024-09-01"}, "notes": {"Fully written thoughts"}}
+	r := httptest.NewRequest(http.MethodPost, "/diary", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("HX-Request", "true")
+	r.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	h.CreateDiaryEntry(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d:\n%s", w.Code, http.StatusOK, w.Body)
+	}
+
+	var cleared bool
+	for _, c := range w.Result().Cookies() {
+		cleared = cleared || (c.Name == draftCookie && c.MaxAge < 0)
+	}
+	if !cleared {
+		t.Error("saving the entry doesn't clear the draft cookie")
+	}
+	if _, err := db.GetDraft(context.Background(), cookie.Value, time.Time{}); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("draft is still stored after the entry was saved: %v", err)
+	}
+	if form := newEntryForm(h, cookie); strings.Contains(form, "Fully written thoughts") {
+		t.Error("new entry form restores a submitted draft")
+	}
+}
+
+func TestDraftExpires(t *testing.T) {
+	h, db := newTestHandlers(t)
+	h.draftTTL = time.Hour
+	ctx := context.Background()
+
+	cookie := saveDraftForm(t, h, url.Values{"notes": {"Forgotten thoughts"}}, nil)
+	if cookie.MaxAge != int(time.Hour.Seconds()) {
+		t.Errorf("cookie MaxAge = %d, want the draft TTL", cookie.MaxAge)
+	}
+	stale := time.Now().Add(-2 * time.Hour).UTC().Format("2006-01-02 15:04:05")
+	if _, err := db.ExecContext(ctx, "UPDATE drafts SET updated_at = ?", stale); err != nil {
+		t.Fatalf("aging draft: %v", err)
+	}
+
+	if form := newEntryForm(h, cookie); strings.Contains(form, "Forgotten thoughts") {
+		t.Error("new entry form restores an expired draft")
+	}
+
+	// Saving another draft cleans up the expired one
+	saveDraftForm(t, h, url.Values{"notes": {"New thoughts"}}, nil)
+	if _, err := db.GetDraft(ctx, cookie.Value, time.Time{}); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expired draft is still stored: %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pavelanni/movie-journal/internal/models"
+)
+
+// entryFromForm rebuilds an entry from submitted form values so a rejected edit can be
+// shown again without losing what the user typed. Unparseable values are left empty.
+func entryFromForm(id int64, r *http.Request) *models.DiaryEntry {
+	entry := &models.DiaryEntry{
+		ID:              id,
+		Movie:           &models.Movie{Title: r.FormValue("movie_title")},
+		WatchedLocation: r.FormValue("watched_location"),
+		Format:          r.FormValue("format"),
+		WatchedWith:     r.FormValue("watched_with"),
+		Notes:           r.FormValue("notes"),
+	}
+	entry.WatchedDate, _ = time.Parse("2006-01-02", r.FormValue("watched_date"))
+	entry.Rating, _ = strconv.Atoi(r.FormValue("rating"))
+	entry.UpdatedAt = parseEntryVersion(r)
+	return entry
+}
+
+// parseEntryVersion reads when the entry being edited was last changed, as sent back by
+// the edit form. It returns the zero time if the form didn't include it.
+func parseEntryVersion(r *http.Request) time.Time {
+	nanos, err := strconv.ParseInt(r.FormValue("updated_at"), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos).UTC()
+}
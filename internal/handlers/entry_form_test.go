@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pavelanni/movie-journal/internal/database"
+	"github.com/pavelanni/movie-journal/internal/journal"
+	"github.com/pavelanni/movie-journal/internal/models"
+	"github.com/pavelanni/movie-journal/internal/tmdb"
+)
+
+// fakeTMDB serves canned search results and external IDs, counting the searches made.
+func fakeTMDB(t *testing.T, searchResults string, searches *atomic.Int32) *tmdb.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/search/movie":
+			searches.Add(1)
+			_, _ = w.Write([]byte(searchResults))
+		case strings.HasSuffix(r.URL.Path, "/external_ids"):
+			_, _ = w.Write([]byte(`{"imdb_id":"tt15239678"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return tmdb.NewClient("test-key", tmdb.WithBaseURL(server.URL), tmdb.WithRetry(1, 0))
+}
+
+// useTMDB makes h look up movies, including those of new entries, on client.
+func useTMDB(h *Handlers, client *tmdb.Client) {
+	h.tmdb = client
+	h.journal = journal.New(journal.Config{DB: h.db, TMDB: client})
+}
+
+// postEntryForm submits the new entry form with HTMX.
+func postEntryForm(h *Handlers, form url.Values) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/diary", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("HX-Request", "true")
+	w := httptest.NewRecorder()
+	h.CreateDiaryEntry(w, r)
+	return w
+}
+
+// countMovies returns how many movies are in the library.
+func countMovies(t *testing.T, db *database.DB) int {
+	t.Helper()
+	var count int
+	if err := db.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM movies").Scan(&count); err != nil {
+		t.Fatalf("counting movies: %v", err)
+	}
+	return count
+}
+
+func TestCreateDiaryEntryResolvesMovieOnTMDB(t *testing.T) {
+	h, db := newTestHandlers(t)
+	var searches atomic.Int32
+	useTMDB(h, fakeTMDB(t, `{"results":[
+		{"id":438631,"title":"Dune","release_date":"2021-09-15"},
+		{"id":693134,"title":"Dune: Part Two","release_date":"2024-02-27"}
+	]}`, &searches))
+
+	w := postEntryForm(h, url.Values{"movie_title": {"Dune: Part Two"}, "watched_date": {"2024-03-02"}})
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d:\n%s", w.Code, http.StatusOK, w.Body)
+	}
+	movie, err := db.FindMovieByTitle(context.Background(), "Dune: Part Two", 0)
+	if err != nil {
+		t.Fatalf("the TMDB match wasn't added to the library: %v", err)
+	}
+	if movie.TMDBID != 693134 || movie.Year != 2024 || movie.IMDbID != "tt15239678" {
+		t.Errorf("saved movie = %+v, want the exact title match with its IMDb ID", movie)
+	}
+	if !strings.Contains(w.Body.String(), "Dune: Part Two") {
+		t.Errorf("response doesn't show the new entry:\n%s", w.Body)
+	}
+	if count, err := db.CountDiaryEntries(context.Background()); err != nil || count != 1 {
+		t.Errorf("diary has %d entries (%v), want 1", count, err)
+	}
+}
+
+func TestCreateDiaryEntryShowsPreviousViewing(t *testing.T) {
+	h, db := newTestHandlers(t)
+	addRatedEntry(t, db, 438631, "Dune", 3)
+
+	w := postEntryForm(h, url.Values{"movie_title": {"Dune"}, "watched_date": {"2024-09-01"}, "rating": {"5"}})
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d:\n%s", w.Code, http.StatusOK, w.Body)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "Last time you watched this on June 1, 2024") {
+		t.Errorf("response doesn't mention the previous viewing:\n%s", body)
+	}
+	if !strings.Contains(body, "and rated it 3/5") {
+		t.Errorf("response doesn't show the previous rating:\n%s", body)
+	}
+	if count, err := db.CountDiaryEntries(context.Background()); err != nil || count != 2 {
+		t.Errorf("diary has %d entries (%v), want the rewatch saved as well", count, err)
+	}
+}
+
+func TestCreateDiaryEntryFirstViewingHasNoComparison(t *testing.T) {
+	h, _ := newTestHandlers(t)
+	var searches atomic.Int32
+	useTMDB(h, fakeTMDB(t, `{"results":[{"id":438631,"title":"Dune","release_date":"2021-09-15"}]}`, &searches))
+
+	w := postEntryForm(h, url.Values{"movie_title": {"Dune"}, "watched_date": {"2024-09-01"}})
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d:\n%s", w.Code, http.StatusOK, w.Body)
+	}
+	if strings.Contains(w.Body.String(), "Last time you watched this") {
+		t.Errorf("first viewing mentions a previous one:\n%s", w.Body)
+	}
+}
+
+func TestCreateDiaryEntryWithoutTMDBMatch(t *testing.T) {
+	h, db := newTestHandlers(t)
+	var searches atomic.Int32
+	useTMDB(h, fakeTMDB(t, `{"results":[]}`, &searches))
+
+	w := postEntryForm(h, url.Values{"movie_title": {"No Such Movie"}, "watched_date": {"2024-03-02"}})
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+	if !strings.Contains(w.Body.String(), "isn&#39;t in your library") {
+		t.Errorf("form doesn't explain the movie wasn't found:\n%s", w.Body)
+	}
+	if searches.Load() != 1 {
+		t.Errorf("searched TMDB %d times, want 1", searches.Load())
+	}
+	if n := countMovies(t, db); n != 0 {
+		t.Errorf("library has %d movies, want none", n)
+	}
+}
+
+func TestCreateDiaryEntryInvalidSkipsTMDB(t *testing.T) {
+	h, db := newTestHandlers(t)
+	var searches atomic.Int32
+	useTMDB(h, fakeTMDB(t, `{"results":[{"id":693134,"title":"Dune: Part Two","release_date":"2024-02-27"}]}`, &searches))
+
+	w := postEntryForm(h, url.Values{"movie_title": {"Dune: Part Two"}, "watched_date": {"2024-03-02"}, "rating": {"9"}})
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+	if !strings.Contains(w.Body.String(), "Rating must be between 1 and 5") {
+		t.Errorf("form doesn't show the rating error inline:\n%s", w.Body)
+	}
+	if searches.Load() != 0 {
+		t.Errorf("searched TMDB %d times for an invalid form, want 0", searches.Load())
+	}
+	if n := countMovies(t, db); n != 0 {
+		t.Errorf("library has %d movies after a rejected form, want none", n)
+	}
+}
+
+// putEntryForm submits the edit form for the entry with id as an HTMX request.
+func putEntryForm(h *Handlers, id string, form url.Values) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPut, "/diary/"+id, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("HX-Request", "true")
+	r.SetPathValue("id", id)
+	w := httptest.NewRecorder()
+	h.EditDiaryEntry(w, r)
+	return w
+}
+
+func TestEditDiaryEntryKeepsTitleOffTMDB(t *testing.T) {
+	h, db := newTestHandlers(t)
+	id := strconv.FormatInt(addTestEntry(t, db, 438631, "Dune"), 10)
+	var searches atomic.Int32
+	useTMDB(h, fakeTMDB(t, `{"results":[{"id":1,"title":"Dune","release_date":"1984-12-14"}]}`, &searches))
+
+	w := putEntryForm(h, id, url.Values{"movie_title": {"dune"}, "watched_date": {"2024-06-02"}, "rating": {"5"}})
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d:\n%s", w.Code, http.StatusOK, w.Body)
+	}
+	if searches.Load() != 0 {
+		t.Errorf("searched TMDB %d times for an unchanged title, want 0", searches.Load())
+	}
+	if n := countMovies(t, db); n != 1 {
+		t.Errorf("library has %d movies, want just the entry's own", n)
+	}
+}
+
+func TestEditDiaryEntryRatingOutOfRange(t *testing.T) {
+	h, db := newTestHandlers(t)
+	entryID := addTestEntry(t, db, 438631, "Dune")
+	id := strconv.FormatInt(entryID, 10)
+
+	w := putEntryForm(h, id, url.Values{"movie_title": {"Dune"}, "watched_date": {"2024-06-02"}, "rating": {"7"}})
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d:\n%s", w.Code, http.StatusUnprocessableEntity, w.Body)
+	}
+	if !strings.Contains(w.Body.String(), "Rating must be between 1 and 5") {
+		t.Errorf("edit form doesn't show the rating error:\n%s", w.Body)
+	}
+	entry, err := db.GetDiaryEntry(context.Background(), entryID)
+	if err != nil {
+		t.Fatalf("getting entry: %v", err)
+	}
+	if entry.Rating != 4 {
+		t.Errorf("rating = %d after a rejected edit, want 4", entry.Rating)
+	}
+}
+
+func TestEditDiaryEntryConflict(t *testing.T) {
+	h, db := newTestHandlers(t)
+	entryID := addTestEntry(t, db, 438631, "Dune")
+	id := strconv.FormatInt(entryID, 10)
+	loaded, err := db.GetDiaryEntry(context.Background(), entryID)
+	if err != nil {
+		t.Fatalf("getting entry: %v", err)
+	}
+	version := strconv.FormatInt(loaded.UpdatedAt.UnixNano(), 10)
+	form := func(notes string) url.Values {
+		return url.Values{"movie_title": {"Dune"}, "watched_date": {"2024-06-01"}, "notes": {notes}, "updated_at": {version}}
+	}
+
+	// The first tab saves; the second, still at the loaded version, conflicts
+	if w := putEntryForm(h, id, form("From the first tab")); w.Code != http.StatusOK {
+		t.Fatalf("first save: status = %d, want %d:\n%s", w.Code, http.StatusOK, w.Body)
+	}
+	w := putEntryForm(h, id, form("From the second tab"))
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("stale save: status = %d, want %d", w.Code, http.StatusConflict)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "changed elsewhere") {
+		t.Errorf("response doesn't explain the conflict:\n%s", body)
+	}
+	if !strings.Contains(body, "From the second tab") {
+		t.Errorf("the conflicting changes aren't kept in the form:\n%s", body)
+	}
+	entry, err := db.GetDiaryEntry(context.Background(), entryID)
+	if err != nil {
+		t.Fatalf("getting entry: %v", err)
+	}
+	if entry.Notes != "From the first tab" {
+		t.Errorf("notes = %q, want the first save kept", entry.Notes)
+	}
+	// The form now carries the current version, so saving it again overwrites
+	current := strconv.FormatInt(entry.UpdatedAt.UnixNano(), 10)
+	if !strings.Contains(body, `name="updated_at" value="`+current+`"`) {
+		t.Errorf("form doesn't carry the current version %s:\n%s", current, body)
+	}
+}
+
+func TestCreateDiaryEntryDefaultsToToday(t *testing.T) {
+	h, db := newTestHandlers(t)
+	if _, err := db.SaveMovie(context.Background(), models.Movie{TMDBID: 438631, Title: "Dune", Year: 2021}); err != nil {
+		t.Fatalf("saving movie: %v", err)
+	}
+
+	before := time.Now().Format("2006-01-02")
+	w := postEntryForm(h, url.Values{"movie_title": {"Dune"}, "watched_date": {""}, "rating": {"4"}})
+	after := time.Now().Format("2006-01-02")
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d:\n%s", w.Code, http.StatusOK, w.Body)
+	}
+	entries, err := db.ListDiaryEntries(context.Background())
+	if err != nil {
+		t.Fatalf("listing entries: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("diary has %d entries, want 1", len(entries))
+	}
+	// The test may straddle midnight
+	if got := entries[0].WatchedDate.Format("2006-01-02"); got != before && got != after {
+		t.Errorf("watched date = %s, want today (%s)", got, after)
+	}
+}
+
+func TestCreateDiaryEntryInvalidDate(t *testing.T) {
+	h, db := newTestHandlers(t)
+	if _, err := db.SaveMovie(context.Background(), models.Movie{TMDBID: 438631, Title: "Dune", Year: 2021}); err != nil {
+		t.Fatalf("saving movie: %v", err)
+	}
+
+	w := postEntryForm(h, url.Values{"movie_title": {"Dune"}, "watched_date": {"01/06/2024"}})
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+	if !strings.Contains(w.Body.String(), "Enter the date you watched the movie") {
+		t.Errorf("form doesn't show the date error inline:\n%s", w.Body)
+	}
+	if count, err := db.CountDiaryEntries(context.Background()); err != nil || count != 0 {
+		t.Errorf("diary has %d entries (%v) after a rejected form, want none", count, err)
+	}
+}
+
+func TestEditDiaryEntryRequiresDate(t *testing.T) {
+	h, db := newTestHandlers(t)
+	entryID := addTestEntry(t, db, 438631, "Dune")
+
+	w := putEntryForm(h, strconv.FormatInt(entryID, 10), url.Values{"movie_title": {"Dune"}, "watched_date": {""}, "rating": {"4"}})
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d:\n%s", w.Code, http.StatusUnprocessableEntity, w.Body)
+	}
+	entry, err := db.GetDiaryEntry(context.Background(), entryID)
+	if err != nil {
+		t.Fatalf("getting entry: %v", err)
+	}
+	if got := entry.WatchedDate.Format("2006-01-02"); got != "2024-06-01" {
+		t.Errorf("watched date = %s after a rejected edit, want 2024-06-01", got)
+	}
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/pavelanni/movie-journal/templates"
+)
+
+// errorPage reports an error to the client. Browsers loading a page get the styled
+// error page; HTMX and JSON clients get just the message as plain text.
+func errorPage(w http.ResponseWriter, r *http.Request, status int, message string) {
+	if isHTMX(r) || prefersJSON(r) {
+		http.Error(w, message, status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	if err := templates.ErrorPage(status, message).Render(r.Context(), w); err != nil {
+		slog.Error("Failed to render error page", slog.String("error", err.Error()))
+	}
+}
+
+// NotFound renders the 404 page for paths that don't match any route.
+func (h *Handlers) NotFound(w http.ResponseWriter, r *http.Request) {
+	errorPage(w, r, http.StatusNotFound, "The page you're looking for doesn't exist.")
+}
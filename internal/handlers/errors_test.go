@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestErrorPage(t *testing.T) {
+	tests := []struct {
+		headers  map[string]string
+		name     string
+		wantType string
+		wantPage bool
+	}{
+		{
+			name:     "browser navigation",
+			headers:  map[string]string{"Accept": "text/html,application/xhtml+xml"},
+			wantType: "text/html; charset=utf-8",
+			wantPage: true,
+		},
+		{
+			name:     "htmx request",
+			headers:  map[string]string{"HX-Request": "true", "Accept": "text/html"},
+			wantType: "text/plain; charset=utf-8",
+		},
+		{
+			name:     "api client",
+			headers:  map[string]string{"Accept": "application/json"},
+			wantType: "text/plain; charset=utf-8",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestHandlers(t)
+			r := httptest.NewRequest(http.MethodGet, "/no/such/page", nil)
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+
+			h.NotFound(w, r)
+
+			if w.Code != http.StatusNotFound {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusNotFound)
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantType)
+			}
+			body := w.Body.String()
+			if isPage := strings.HasPrefix(body, "<!doctype html>"); isPage != tt.wantPage {
+				t.Errorf("full page = %v, want %v", isPage, tt.wantPage)
+			}
+			if !strings.Contains(body, "doesn't exist") && !strings.Contains(body, "doesn&#39;t exist") {
+				t.Errorf("body does not contain the message: %q", body)
+			}
+			if !tt.wantPage && strings.Contains(body, "<") {
+				t.Errorf("terse response contains markup: %q", body)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/pavelanni/movie-journal/internal/models"
+)
+
+// feedEntries is the number of most recent entries in the Atom feed.
+const feedEntries = 20
+
+// atomFeed is an Atom feed document (RFC 4287).
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// atomAuthor names the author of a feed.
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+// atomLink is a link from a feed or one of its entries.
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+// atomEntry is a single diary entry in the feed.
+type atomEntry struct {
+	Title     string   `xml:"title"`
+	ID        string   `xml:"id"`
+	Published string   `xml:"published"`
+	Updated   string   `xml:"updated"`
+	Summary   string   `xml:"summary,omitempty"`
+	Link      atomLink `xml:"link"`
+}
+
+// Feed returns the most recent diary entries as an Atom feed, for following the diary in
+// a feed reader.
+func (h *Handlers) Feed(w http.ResponseWriter, r *http.Request) {
+	entries, err := h.db.ListDiaryEntries(r.Context())
+	if err != nil {
+		slog.Error("Failed to list diary entries", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to load entries")
+		return
+	}
+	if len(entries) > feedEntries {
+		entries = entries[:feedEntries]
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	_, _ = fmt.Fprint(w, xml.Header)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(buildFeed(entries, requestBaseURL(r))); err != nil {
+		slog.Error("Failed to encode feed", slog.String("error", err.Error()))
+	}
+}
+
+// buildFeed builds the Atom feed for entries, most recent first, linking them under
+// baseURL. Each entry is published on its watched date. The feed is as up to date as its
+// most recently changed entry.
+func buildFeed(entries []models.DiaryEntry, baseURL string) atomFeed {
+	feed := atomFeed{
+		Title:  "Movie Journal",
+		ID:     baseURL + "/",
+		Author: atomAuthor{Name: "Movie Journal"},
+		Links: []atomLink{
+			{Href: baseURL + "/feed.xml", Rel: "self", Type: "application/atom+xml"},
+			{Href: baseURL + "/", Rel: "alternate", Type: "text/html"},
+		},
+	}
+
+	var updated time.Time
+	for i := range entries {
+		entry := &entries[i]
+		changed := entry.UpdatedAt
+		if changed.IsZero() {
+			changed = entry.CreatedAt
+		}
+		if changed.After(updated) {
+			updated = changed
+		}
+
+		url := fmt.Sprintf("%s/entry/%d", baseURL, entry.ID)
+		feed.Entries = append(feed.Entries, atomEntry{
+			Title:     feedEntryTitle(entry.Movie),
+			ID:        url,
+			Published: entry.WatchedDate.UTC().Format(time.RFC3339),
+			Updated:   changed.UTC().Format(time.RFC3339),
+			Summary:   entry.Notes,
+			Link:      atomLink{Href: url, Rel: "alternate", Type: "text/html"},
+		})
+	}
+	// An empty feed still needs an updated time
+	if updated.IsZero() {
+		updated = time.Unix(0, 0)
+	}
+	feed.Updated = updated.UTC().Format(time.RFC3339)
+	return feed
+}
+
+// feedEntryTitle titles a feed entry after its movie, with the release year when known.
+func feedEntryTitle(movie *models.Movie) string {
+	switch {
+	case movie == nil:
+		return "Untitled"
+	case movie.Year != 0:
+		return fmt.Sprintf("%s (%d)", movie.Title, movie.Year)
+	default:
+		return movie.Title
+	}
+}
+
+// requestBaseURL returns the scheme and host the request was made to, such as
+// "https://movies.example.com", for building absolute links.
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pavelanni/movie-journal/internal/models"
+)
+
+// getFeed requests the Atom feed and parses it, failing the test unless it is
+// well-formed Atom.
+func getFeed(t *testing.T, h *Handlers) atomFeed {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.Feed(w, httptest.NewRequest(http.MethodGet, "http://movies.example.com/feed.xml", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/atom+xml; charset=utf-8" {
+		t.Errorf("Content-Type = %q, want application/atom+xml", ct)
+	}
+	var feed atomFeed
+	if err := xml.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("feed isn't well-formed Atom: %v\n%s", err, w.Body)
+	}
+	return feed
+}
+
+func TestFeed(t *testing.T) {
+	h, db := newTestHandlers(t)
+	ctx := context.Background()
+	movie, err := db.SaveMovie(ctx, models.Movie{TMDBID: 438631, Title: "Dune", Year: 2021})
+	if err != nil {
+		t.Fatalf("saving movie: %v", err)
+	}
+	id, err := db.CreateDiaryEntry(ctx, models.DiaryEntryInput{
+		MovieID:   movie.ID,
+		WatchedAt: time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC),
+		Rating:    5,
+		Notes:     "Sand & <spice>",
+	})
+	if err != nil {
+		t.Fatalf("creating entry: %v", err)
+	}
+
+	feed := getFeed(t, h)
+
+	if feed.Title != "Movie Journal" || feed.ID != "http://movies.example.com/" {
+		t.Errorf("feed = %q (%s), want Movie Journal at the site root", feed.Title, feed.ID)
+	}
+	if _, err := time.Parse(time.RFC3339, feed.Updated); err != nil {
+		t.Errorf("updated = %q, want an RFC 3339 time", feed.Updated)
+	}
+	if len(feed.Entries) != 1 {
+		t.Fatalf("feed has %d entries, want 1", len(feed.Entries))
+	}
+	want := atomEntry{
+		Title:     "Dune (2021)",
+		ID:        "http://movies.example.com/entry/" + strconv.FormatInt(id, 10),
+		Published: "2024-06-01T00:00:00Z",
+		Summary:   "Sand & <spice>",
+		Link:      atomLink{Href: "http://movies.example.com/entry/" + strconv.FormatInt(id, 10), Rel: "alternate", Type: "text/html"},
+	}
+	got := feed.Entries[0]
+	got.Updated = ""
+	if got != want {
+		t.Errorf("entry = %+v, want %+v", got, want)
+	}
+}
+
+func TestFeedLimit(t *testing.T) {
+	h, db := newTestHandlers(t)
+	for i := range feedEntries + 5 {
+		addTestEntry(t, db, i+1, "Movie "+strconv.Itoa(i+1))
+	}
+
+	if feed := getFeed(t, h); len(feed.Entries) != feedEntries {
+		t.Errorf("feed has %d entries, want the latest %d", len(feed.Entries), feedEntries)
+	}
+}
+
+func TestFeedEmpty(t *testing.T) {
+	h, _ := newTestHandlers(t)
+
+	feed := getFeed(t, h)
+
+	if len(feed.Entries) != 0 {
+		t.Errorf("feed has %d entries, want none", len(feed.Entries))
+	}
+	if feed.Updated != "1970-01-01T00:00:00Z" {
+		t.Errorf("updated = %q, want the epoch for an empty feed", feed.Updated)
+	}
+}
+
+func TestFeedEntryTitle(t *testing.T) {
+	tests := []struct {
+		movie *models.Movie
+		want  string
+	}{
+		{movie: &models.Movie{Title: "Dune", Year: 2021}, want: "Dune (2021)"},
+		{movie: &models.Movie{Title: "Untitled Project"}, want: "Untitled Project"},
+		{movie: nil, want: "Untitled"},
+	}
+	for _, tt := range tests {
+		if got := feedEntryTitle(tt.movie); got != tt.want {
+			t.Errorf("feedEntryTitle(%+v) = %q, want %q", tt.movie, got, tt.want)
+		}
+	}
+}
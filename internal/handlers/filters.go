@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pavelanni/movie-journal/internal/journal"
+	"github.com/pavelanni/movie-journal/internal/models"
+)
+
+// parseEntryFilter reads the list filters explicitly requested in the query.
+// Invalid values are dropped, so they never show up as active filters.
+func parseEntryFilter(query url.Values, now time.Time) models.EntryFilter {
+	prefs := parsePreferences(query)
+	filter := models.EntryFilter{
+		MinRating: prefs.MinRating,
+		Sort:      prefs.Sort,
+		Genre:     strings.TrimSpace(query.Get("genre")),
+		Format:    journal.NormalizeFormat(query.Get("format")),
+	}
+	if from, to, ok := rangeForPreset(query.Get("range"), now); ok {
+		filter.DateRange = query.Get("range")
+		filter.WatchedFrom, filter.WatchedTo = from, to
+	}
+	if decade, ok := parseDecade(query.Get("decade")); ok {
+		filter.Decade = decade
+	}
+	return filter
+}
+
+// hasFilters reports whether filter narrows down the entries listed. Sorting doesn't count.
+func hasFilters(filter models.EntryFilter) bool {
+	return filter.MinRating != "" || filter.Genre != "" || filter.DateRange != "" ||
+		filter.Decade != "" || filter.Format != ""
+}
+
+// parseDecade normalizes a decade filter value: "unknown", or a year that starts a decade.
+func parseDecade(value string) (string, bool) {
+	if value == models.DecadeUnknown {
+		return value, true
+	}
+	year, err := strconv.Atoi(value)
+	if err != nil || year <= 0 || year%10 != 0 {
+		return "", false
+	}
+	return strconv.Itoa(year), true
+}
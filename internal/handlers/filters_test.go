@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pavelanni/movie-journal/internal/models"
+)
+
+func TestGetRecentEntriesFilters(t *testing.T) {
+	h, db := newTestHandlers(t)
+	ctx := context.Background()
+	for _, e := range []struct {
+		title  string
+		genre  string
+		rating int
+	}{
+		{title: "Heat", genre: "Crime", rating: 5},
+		{title: "Ronin", genre: "Crime", rating: 2},
+		{title: "Alien", genre: "Horror", rating: 5},
+	} {
+		movie, err := db.SaveMovie(ctx, models.Movie{TMDBID: len(e.title), Title: e.title, Genre: e.genre})
+		if err != nil {
+			t.Fatalf("saving movie: %v", err)
+		}
+		_, err = db.CreateDiaryEntry(ctx, models.DiaryEntryInput{
+			MovieID:   movie.ID,
+			WatchedAt: time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC),
+			Rating:    e.rating,
+		})
+		if err != nil {
+			t.Fatalf("creating entry: %v", err)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/recent-entries?min_rating=4&genre=Crime", nil)
+	r.Header.Set("HX-Request", "true")
+	w := httptest.NewRecorder()
+
+	h.GetRecentEntries(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "Heat") {
+		t.Errorf("matching entry is missing:\n%s", body)
+	}
+	for _, title := range []string{"Ronin", "Alien"} {
+		if strings.Contains(body, title) {
+			t.Errorf("filtered out entry %s is listed", title)
+		}
+	}
+
+	// Each chip's clear link keeps the other filter
+	chips := []struct {
+		label    string
+		clearURL string
+	}{
+		{label: "Rating 4+", clearURL: `hx-get="/recent-entries?genre=Crime"`},
+		{label: "Genre: Crime", clearURL: `hx-get="/recent-entries?min_rating=4"`},
+	}
+	for _, chip := range chips {
+		if !strings.Contains(body, `aria-label="Clear `+chip.label+`"`) {
+			t.Errorf("no chip for %q", chip.label)
+		}
+		if !strings.Contains(body, chip.clearURL) {
+			t.Errorf("no clear link %s for %q", chip.clearURL, chip.label)
+		}
+	}
+}
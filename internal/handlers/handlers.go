@@ -2,34 +2,169 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/a-h/templ"
+	"github.com/pavelanni/movie-journal/internal/answers"
 	"github.com/pavelanni/movie-journal/internal/database"
+	"github.com/pavelanni/movie-journal/internal/journal"
 	"github.com/pavelanni/movie-journal/internal/models"
+	"github.com/pavelanni/movie-journal/internal/tmdb"
 	"github.com/pavelanni/movie-journal/templates"
 )
 
 // Handlers contains all HTTP handlers.
 type Handlers struct {
-	db *database.DB
+	// answerer is nil when answer suggestions are disabled.
+	answerer answers.Answerer
+	db       *database.DB
+	// journal validates and saves new and edited entries.
+	journal *journal.Service
+	// tmdb is nil when no TMDB API key is configured.
+	tmdb *tmdb.Client
+	// runJob runs background work such as prefetching TMDB details; nil runs it inline.
+	runJob func(name string, job func(ctx context.Context))
+	// recentLimit is how many of the most recent entries the home page shows.
+	recentLimit int
+	// perPage is the diary list's page size unless the user picks one.
+	perPage int
+	// draftTTL is how long an untouched new entry draft is kept; zero keeps drafts forever.
+	draftTTL time.Duration
+}
+
+// Config holds the settings for Handlers. Only DB is required.
+type Config struct {
+	// Answerer suggests answers to lookups; nil disables answer suggestions.
+	Answerer answers.Answerer
+	DB       *database.DB
+	// TMDB looks up movies not yet in the library; nil disables TMDB lookups.
+	TMDB *tmdb.Client
+	// RunJob runs background work, such as fetching TMDB details after an entry is saved.
+	// When nil, that work runs inline before the response.
+	RunJob func(name string, job func(ctx context.Context))
+	// MaxNotesLength caps entry notes, in characters; zero means no limit.
+	MaxNotesLength int
+	// RecentLimit is how many of the most recent entries the home page shows before
+	// linking to the full diary.
+	RecentLimit int
+	// PerPage is the number of entries on each page of the diary unless the user picks
+	// a page size.
+	PerPage int
+	// DraftTTL is how long an untouched new entry draft is kept; zero keeps drafts forever.
+	DraftTTL time.Duration
 }
 
 // New creates a new Handlers instance.
-func New(db *database.DB) *Handlers {
-	return &Handlers{db: db}
+func New(cfg Config) *Handlers {
+	return &Handlers{
+		db:          cfg.DB,
+		tmdb:        cfg.TMDB,
+		answerer:    cfg.Answerer,
+		runJob:      cfg.RunJob,
+		recentLimit: cfg.RecentLimit,
+		perPage:     cfg.PerPage,
+		draftTTL:    cfg.DraftTTL,
+		journal: journal.New(journal.Config{
+			DB:             cfg.DB,
+			TMDB:           cfg.TMDB,
+			MaxNotesLength: cfg.MaxNotesLength,
+		}),
+	}
+}
+
+// goJob runs job with the job runner, or inline, bounded by the request's context, if
+// there's none.
+func (h *Handlers) goJob(ctx context.Context, name string, job func(ctx context.Context)) {
+	if h.runJob == nil {
+		job(ctx)
+		return
+	}
+	h.runJob(name, job)
 }
 
-// Home renders the home page with recent diary entries.
+// Home renders the home page with the most recent diary entries, up to the recent limit,
+// linking to the full diary when there are more.
 func (h *Handlers) Home(w http.ResponseWriter, r *http.Request) {
-	// For now, use sample data until we implement database queries
-	entries := getSampleEntries()
+	filter, view, _ := h.listSettings(w, r, true)
+	found, err := h.listEntries(r.Context(), filter, h.recentLimit, 1)
+	if err != nil {
+		slog.Error("Failed to list diary entries", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to load entries")
+		return
+	}
+	var more templ.Component = templ.NopComponent
+	if found.pages > 1 {
+		more = templates.MoreEntries(diaryURL(filter))
+	}
+
+	err = templates.Index(entriesList(found.total, found.entries, filter, view, more)).Render(r.Context(), w)
+	if err != nil {
+		errorPage(w, r, http.StatusInternalServerError, "Failed to render template")
+		return
+	}
+}
+
+// Diary renders the full diary, a page at a time.
+func (h *Handlers) Diary(w http.ResponseWriter, r *http.Request) {
+	list, ok := h.recentEntries(w, r, true)
+	if !ok {
+		return
+	}
+
+	err := templates.Index(list).Render(r.Context(), w)
+	if err != nil {
+		errorPage(w, r, http.StatusInternalServerError, "Failed to render template")
+		return
+	}
+}
+
+// Decades renders the number of entries per release decade.
+func (h *Handlers) Decades(w http.ResponseWriter, r *http.Request) {
+	counts, err := h.db.CountByDecade(r.Context())
+	if err != nil {
+		slog.Error("Failed to count entries by decade", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to load decades")
+		return
+	}
+
+	err = templates.Decades(counts).Render(r.Context(), w)
+	if err != nil {
+		errorPage(w, r, http.StatusInternalServerError, "Failed to render template")
+		return
+	}
+}
+
+// defaultTasteMinCount is how many rated entries a genre needs to appear on the taste
+// page unless ?min= says otherwise.
+const defaultTasteMinCount = 3
+
+// Taste renders the average rating per genre. Genres with fewer rated entries than
+// ?min= (default defaultTasteMinCount) are left out.
+func (h *Handlers) Taste(w http.ResponseWriter, r *http.Request) {
+	minCount := defaultTasteMinCount
+	if n, err := strconv.Atoi(r.URL.Query().Get("min")); err == nil && n > 0 {
+		minCount = n
+	}
+
+	ratings, err := h.db.GenreRatings(r.Context(), minCount)
+	if err != nil {
+		slog.Error("Failed to average ratings by genre", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to load genres")
+		return
+	}
 
-	err := templates.Index(entries, "").Render(r.Context(), w)
+	err = templates.Taste(ratings, minCount).Render(r.Context(), w)
 	if err != nil {
-		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+		errorPage(w, r, http.StatusInternalServerError, "Failed to render template")
 		return
 	}
 }
@@ -38,7 +173,58 @@ func (h *Handlers) Home(w http.ResponseWriter, r *http.Request) {
 func (h *Handlers) About(w http.ResponseWriter, r *http.Request) {
 	err := templates.About().Render(r.Context(), w)
 	if err != nil {
-		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+		errorPage(w, r, http.StatusInternalServerError, "Failed to render template")
+		return
+	}
+}
+
+// Stats renders diary-wide statistics.
+func (h *Handlers) Stats(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.db.GetStats(r.Context(), time.Now())
+	if err != nil {
+		slog.Error("Failed to get stats", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to load stats")
+		return
+	}
+
+	err = templates.Stats(*stats).Render(r.Context(), w)
+	if err != nil {
+		errorPage(w, r, http.StatusInternalServerError, "Failed to render template")
+		return
+	}
+}
+
+// YearCount returns the number of films watched so far this year for the page header
+// (HTML fragment for HTMX).
+func (h *Handlers) YearCount(w http.ResponseWriter, r *http.Request) {
+	year := time.Now().Year()
+	count, err := h.db.CountInYear(r.Context(), year)
+	if err != nil {
+		slog.Error("Failed to count entries this year", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to count entries")
+		return
+	}
+
+	err = templates.YearCount(count, year).Render(r.Context(), w)
+	if err != nil {
+		errorPage(w, r, http.StatusInternalServerError, "Failed to render template")
+		return
+	}
+}
+
+// FormatSuggestions returns the formats entries were watched in before, as datalist
+// options for the format field (HTML fragment for HTMX).
+func (h *Handlers) FormatSuggestions(w http.ResponseWriter, r *http.Request) {
+	formats, err := h.db.ListFormats(r.Context())
+	if err != nil {
+		slog.Error("Failed to list formats", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to load formats")
+		return
+	}
+
+	err = templates.FormatOptions(formats).Render(r.Context(), w)
+	if err != nil {
+		errorPage(w, r, http.StatusInternalServerError, "Failed to render template")
 		return
 	}
 }
@@ -46,17 +232,56 @@ func (h *Handlers) About(w http.ResponseWriter, r *http.Request) {
 // GetDiaryEntry returns a single diary entry's details (HTML fragment for HTMX).
 func (h *Handlers) GetDiaryEntry(w http.ResponseWriter, r *http.Request) {
 	h.renderDiaryEntry(w, r, func(entry models.DiaryEntry, w http.ResponseWriter, r *http.Request) error {
-		return templates.MovieDetails(entry).Render(r.Context(), w)
+		average, rated := h.movieAverageRating(r.Context(), entry.MovieID)
+		return renderFragment(w, r, "Diary Entry", templates.MovieDetails(entry, average, rated))
 	})
 }
 
 // GetDiaryEntryShort returns a single diary entry's as MovieCard (HTML fragment for HTMX).
 func (h *Handlers) GetDiaryEntryShort(w http.ResponseWriter, r *http.Request) {
 	h.renderDiaryEntry(w, r, func(entry models.DiaryEntry, w http.ResponseWriter, r *http.Request) error {
-		return templates.MovieCard(entry).Render(r.Context(), w)
+		return renderFragment(w, r, "Diary Entry", templates.MovieCard(entry))
+	})
+}
+
+// GetEntry returns a single diary entry as HTML or JSON, depending on the Accept header.
+func (h *Handlers) GetEntry(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Accept")
+	h.renderDiaryEntry(w, r, func(entry models.DiaryEntry, w http.ResponseWriter, r *http.Request) error {
+		if prefersJSON(r) {
+			w.Header().Set("Content-Type", "application/json")
+			return json.NewEncoder(w).Encode(entry)
+		}
+		average, rated := h.movieAverageRating(r.Context(), entry.MovieID)
+		return renderFragment(w, r, "Diary Entry", templates.MovieDetails(entry, average, rated))
 	})
 }
 
+// isHTMX reports whether the request was issued by HTMX.
+func isHTMX(r *http.Request) bool {
+	return r.Header.Get("HX-Request") == "true"
+}
+
+// entriesList returns the recent entries list followed by pager, or the empty-diary welcome
+// when the diary has no entries at all (total counts entries before any filtering).
+func entriesList(
+	total int, entries []models.DiaryEntry, filter models.EntryFilter, view string, pager templ.Component,
+) templ.Component {
+	if total == 0 {
+		return templates.EmptyDiary()
+	}
+	return templates.RecentEntries(entries, filter, view, pager)
+}
+
+// renderFragment renders the fragment as is for HTMX requests and wraps it
+// in the full page layout for direct browser hits.
+func renderFragment(w http.ResponseWriter, r *http.Request, title string, fragment templ.Component) error {
+	if isHTMX(r) {
+		return fragment.Render(r.Context(), w)
+	}
+	return templates.Page(title, fragment).Render(r.Context(), w)
+}
+
 // renderDiaryEntry is a helper that extracts ID, finds entry, and renders using provided function.
 func (h *Handlers) renderDiaryEntry(
 	w http.ResponseWriter,
@@ -66,278 +291,501 @@ func (h *Handlers) renderDiaryEntry(
 	idStr := r.PathValue("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		errorPage(w, r, http.StatusBadRequest, "Invalid ID")
 		return
 	}
 
-	entries := getSampleEntries()
-	found := getEntryByID(id, entries)
-	if found == nil {
-		http.Error(w, "Entry not found", http.StatusNotFound)
+	found, err := h.db.GetDiaryEntry(r.Context(), id)
+	if errors.Is(err, database.ErrNotFound) {
+		errorPage(w, r, http.StatusNotFound, "Entry not found")
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to get diary entry", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to load entry")
 		return
 	}
 
 	if err := renderFunc(*found, w, r); err != nil {
-		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+		errorPage(w, r, http.StatusInternalServerError, "Failed to render template")
+	}
+}
+
+// movieAverageRating returns the average rating across a movie's viewings and how many
+// were rated. Errors are logged and treated as no ratings, so the detail view still renders.
+func (h *Handlers) movieAverageRating(ctx context.Context, movieID int64) (float64, int) {
+	average, rated, err := h.db.MovieAverageRating(ctx, movieID)
+	if err != nil {
+		slog.Error("Failed to average movie ratings", slog.String("error", err.Error()))
+		return 0, 0
+	}
+	return average, rated
+}
+
+// SharedDiaryEntry renders the standalone page for a diary entry looked up by its slug.
+func (h *Handlers) SharedDiaryEntry(w http.ResponseWriter, r *http.Request) {
+	entry, err := h.db.GetDiaryEntryBySlug(r.Context(), r.PathValue("slug"))
+	if errors.Is(err, database.ErrNotFound) {
+		errorPage(w, r, http.StatusNotFound, "Entry not found")
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to get diary entry", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to load entry")
+		return
+	}
+
+	err = templates.SharedEntry(*entry).Render(r.Context(), w)
+	if err != nil {
+		errorPage(w, r, http.StatusInternalServerError, "Failed to render template")
+		return
 	}
 }
 
 // GetRecentEntries returns filtered diary entries (HTML fragment for HTMX).
 func (h *Handlers) GetRecentEntries(w http.ResponseWriter, r *http.Request) {
-	entries := getSampleEntries()
-
-	rating := r.URL.Query().Get("min_rating")
-	if rating != "" {
-		minRating, err := strconv.Atoi(rating)
-		if err == nil {
-			filtered := make([]models.DiaryEntry, 0)
-			for i := range entries {
-				if entries[i].Rating >= minRating {
-					filtered = append(filtered, entries[i])
-				}
-			}
-			entries = filtered
-		}
+	list, ok := h.recentEntries(w, r, false)
+	if !ok {
+		return
 	}
 
-	err := templates.RecentEntries(entries, rating).Render(r.Context(), w)
+	var err error
+	if isHTMX(r) {
+		err = list.Render(r.Context(), w)
+	} else {
+		err = templates.Index(list).Render(r.Context(), w)
+	}
 	if err != nil {
-		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+		errorPage(w, r, http.StatusInternalServerError, "Failed to render template")
 		return
 	}
 }
 
-// NewDiaryEntryForm renders the form to create a new diary entry.
+// recentEntries returns the recent entries list for the request, filtered, sorted and
+// paged by its query and the saved preferences as listSettings describes. The pager
+// links carry the filters in effect, so every page comes from the same list. If the
+// entries can't be loaded, it writes an error page and returns false.
+func (h *Handlers) recentEntries(w http.ResponseWriter, r *http.Request, savedFilters bool) (templ.Component, bool) {
+	filter, view, perPage := h.listSettings(w, r, savedFilters)
+	page, _ := parsePagination(r)
+
+	found, err := h.listEntries(r.Context(), filter, perPage, page)
+	if err != nil {
+		slog.Error("Failed to list diary entries", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to load entries")
+		return nil, false
+	}
+	pager := templates.Pagination(found.page, found.pages, h.pageBaseURL(filter, perPage))
+	return entriesList(found.total, found.entries, filter, view, pager), true
+}
+
+// listSettings returns the filter, layout and page size a diary list request asks for.
+// The saved sort, layout and page size fill in for any the query doesn't set. With
+// savedFilters, as on the home page and the diary, the saved minimum rating applies too
+// unless the query sets filters of its own.
+func (h *Handlers) listSettings(
+	w http.ResponseWriter, r *http.Request, savedFilters bool,
+) (filter models.EntryFilter, view string, perPage int) {
+	filter = parseEntryFilter(r.URL.Query(), time.Now())
+	saved := loadPreferences(r)
+	if savedFilters && !hasFilters(filter) {
+		filter.MinRating = saved.MinRating
+	}
+	view = viewPreference(w, r, &saved)
+	filter.Sort = sortFilter(sortPreference(w, r, &saved))
+	_, perPage = parsePagination(r)
+	if perPage == 0 {
+		perPage = saved.PerPage
+	}
+	if perPage == 0 {
+		perPage = h.perPage
+	}
+	return filter, view, perPage
+}
+
+// entryListPage is one page of a filtered list of diary entries.
+type entryListPage struct {
+	entries []models.DiaryEntry
+	page    int
+	pages   int
+	// total is nonzero when the diary has entries, even if none match the filter.
+	total int
+}
+
+// listEntries returns page of the diary entries matching filter, counting from 1, with
+// perPage entries to a page; a page past the end gets the last one. Without a page size,
+// everything is on a single page.
+func (h *Handlers) listEntries(
+	ctx context.Context, filter models.EntryFilter, perPage, page int,
+) (entryListPage, error) {
+	count, err := h.db.CountDiaryEntriesFiltered(ctx, filter)
+	if err != nil {
+		return entryListPage{}, err
+	}
+	list := entryListPage{page: 1, pages: 1, total: count}
+	if count == 0 {
+		// Nothing matching doesn't mean the diary is empty
+		list.total, err = h.db.CountDiaryEntries(ctx)
+		return list, err
+	}
+
+	var p database.EntryPage
+	if perPage > 0 {
+		list.pages = (count + perPage - 1) / perPage
+		list.page = min(max(page, 1), list.pages)
+		p = database.EntryPage{Limit: perPage, Offset: (list.page - 1) * perPage}
+	}
+	list.entries, _, err = h.db.ListDiaryEntriesPage(ctx, filter, p)
+	return list, err
+}
+
+// NewDiaryEntryForm renders the form to create a new diary entry, restoring the user's
+// autosaved draft if there is one. A movie_title query parameter picks the movie.
 func (h *Handlers) NewDiaryEntryForm(w http.ResponseWriter, r *http.Request) {
-	err := templates.DiaryNew().Render(r.Context(), w)
+	draft := h.loadDraft(r)
+	// A movie picked elsewhere, such as the discover page, fills in the title
+	if title := r.URL.Query().Get("movie_title"); title != "" {
+		if draft == nil {
+			draft = url.Values{}
+		}
+		draft.Set("movie_title", title)
+	}
+
+	var err error
+	if isHTMX(r) {
+		err = templates.DiaryNewForm(draft, nil).Render(r.Context(), w)
+	} else {
+		err = templates.DiaryNew(draft, nil).Render(r.Context(), w)
+	}
 	if err != nil {
-		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+		errorPage(w, r, http.StatusInternalServerError, "Failed to render template")
 		return
 	}
 }
 
-// CreateDiaryEntry handles the submission of a new diary entry.
+// CreateDiaryEntry saves a new diary entry for a movie in the library. HTMX requests get
+// a confirmation fragment that mentions the previous viewing when this is a rewatch.
+// Invalid input re-renders the form with the errors next to their fields. A request
+// repeating an earlier Idempotency-Key gets the entry that request created.
 func (h *Handlers) CreateDiaryEntry(w http.ResponseWriter, r *http.Request) {
-	// Parse form data
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, "Failed to parse form", http.StatusBadRequest)
-		return
-	}
-
-	// Extract form values (in a real app, validate and save to DB)
-	watchedDate := r.FormValue("watched_date")
-	movieTitle := r.FormValue("movie_title")
-	watchedLocation := r.FormValue("watched_location")
-	ratingStr := r.FormValue("rating")
-	notes := r.FormValue("notes")
-	watchedWith := r.FormValue("watched_with")
-
-	// For now, just log the received data (replace with DB save logic)
-	slog.Info("Received new diary entry",
-		slog.String("watched_date", watchedDate),
-		slog.String("watched_location", watchedLocation),
-		slog.String("movie_title", movieTitle),
-		slog.String("rating", ratingStr),
-		slog.String("notes", notes),
-		slog.String("watched_with", watchedWith),
-	)
+		errorPage(w, r, http.StatusBadRequest, "Failed to parse form")
+		return
+	}
 
-	// After logging, redirect back to home page (in a real app, redirect to the new entry's page)
-	http.Redirect(w, r, "/", http.StatusSeeOther)
-}
+	key := r.Header.Get(idempotencyKeyHeader)
+	if len(key) > maxIdempotencyKeyLength {
+		errorPage(w, r, http.StatusBadRequest, "Idempotency key is too long")
+		return
+	}
+	if h.replayIdempotentEntry(w, r, key) {
+		return
+	}
 
-// EditDiaryEntryForm renders the form to edit an existing diary entry.
-func (h *Handlers) EditDiaryEntryForm(w http.ResponseWriter, r *http.Request) {
-	// Extract ID from URL path
-	idStr := r.PathValue("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
+	// Entries are often logged right after watching, so the date can be left out
+	input, movie, err := h.journal.ParseEntry(r.Context(), r.Form, time.Now(), true)
+	if verr := journal.FormErrors(err); verr != nil {
+		h.renderEntryFormErrors(w, r, verr)
+		return
+	}
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		slog.Error("Failed to read entry form", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to save entry")
 		return
 	}
 
-	// Find entry in sample data (will be replaced with DB query later)
-	entries := getSampleEntries()
-	found := getEntryByID(id, entries)
+	// Look up earlier viewings before inserting, so the new entry isn't among them. A movie
+	// new to the library has none.
+	var viewings []models.DiaryEntry
+	if movie.ID != 0 {
+		viewings, err = h.db.ListViewings(r.Context(), movie.ID)
+		if err != nil {
+			slog.Error("Failed to list viewings", slog.String("error", err.Error()))
+			errorPage(w, r, http.StatusInternalServerError, "Failed to save entry")
+			return
+		}
+	}
 
-	if found == nil {
-		http.Error(w, "Entry not found", http.StatusNotFound)
+	id, created, err := h.saveEntry(r.Context(), key, input)
+	if verr := journal.FormErrors(err); verr != nil {
+		h.renderEntryFormErrors(w, r, verr)
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to create diary entry", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to save entry")
 		return
 	}
+	h.discardDraft(w, r)
+	if !created {
+		h.renderReplayedEntry(w, r, id)
+		return
+	}
+	h.prefetchMovie(r.Context(), *movie)
 
-	// Render the edit form
-	err = templates.DiaryEditForm(found).Render(r.Context(), w)
+	if !isHTMX(r) {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	entry := models.DiaryEntry{
+		ID:              id,
+		MovieID:         movie.ID,
+		Movie:           movie,
+		WatchedDate:     input.WatchedAt,
+		WatchedLocation: input.Location,
+		Format:          input.Format,
+		Rating:          input.Rating,
+		Notes:           input.Notes,
+		WatchedWith:     input.WatchedWith,
+	}
+	var previous *models.DiaryEntry
+	if len(viewings) > 0 {
+		previous = &viewings[0]
+	}
+
+	// Let any entry list on the page pick up the new entry
+	w.Header().Set("HX-Trigger", fmt.Sprintf(`{"entryCreated":{"id":%d}}`, id))
+	err = templates.DiaryEntryCreated(entry, previous).Render(r.Context(), w)
 	if err != nil {
-		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+		errorPage(w, r, http.StatusInternalServerError, "Failed to render template")
 		return
 	}
 }
 
-// EditDiaryEntry handles the editing of an existing diary entry.
+// renderEntryFormErrors re-renders the new entry form with the submitted values and field errors.
+func (h *Handlers) renderEntryFormErrors(w http.ResponseWriter, r *http.Request, verr *models.ValidationError) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+
+	var err error
+	if isHTMX(r) {
+		err = templates.DiaryNewForm(r.PostForm, verr.Fields).Render(r.Context(), w)
+	} else {
+		err = templates.DiaryNew(r.PostForm, verr.Fields).Render(r.Context(), w)
+	}
+	if err != nil {
+		slog.Error("Failed to render form", slog.String("error", err.Error()))
+	}
+}
+
+// DuplicateDiaryEntry renders the new entry form prefilled with an existing entry's
+// location, format and company, for logging a series watched together. The date defaults to today;
+// the movie, rating, and notes are left blank.
+func (h *Handlers) DuplicateDiaryEntry(w http.ResponseWriter, r *http.Request) {
+	h.renderDiaryEntry(w, r, func(entry models.DiaryEntry, w http.ResponseWriter, r *http.Request) error {
+		form := url.Values{
+			"watched_date":     {time.Now().Format("2006-01-02")},
+			"watched_location": {entry.WatchedLocation},
+			"format":           {entry.Format},
+			"watched_with":     {entry.WatchedWith},
+		}
+		if isHTMX(r) {
+			return templates.DiaryNewForm(form, nil).Render(r.Context(), w)
+		}
+		return templates.DiaryNew(form, nil).Render(r.Context(), w)
+	})
+}
+
+// EditDiaryEntryForm renders the form to edit an existing diary entry.
+func (h *Handlers) EditDiaryEntryForm(w http.ResponseWriter, r *http.Request) {
+	h.renderDiaryEntry(w, r, func(entry models.DiaryEntry, w http.ResponseWriter, r *http.Request) error {
+		return renderFragment(w, r, "Edit Entry", templates.DiaryEditForm(&entry, nil))
+	})
+}
+
+// EditDiaryEntry saves changes to an existing diary entry and returns its details.
+// Invalid input re-renders the edit form with the errors next to their fields.
 func (h *Handlers) EditDiaryEntry(w http.ResponseWriter, r *http.Request) {
-	// Extract ID from URL path
-	idStr := r.PathValue("id")
-	// TODO: id will be used when we start working with DB
-	id, err := strconv.ParseInt(idStr, 10, 64)
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		errorPage(w, r, http.StatusBadRequest, "Invalid ID")
 		return
 	}
 
-	// Parse form data
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, "Failed to parse form", http.StatusBadRequest)
-		return
-	}
-
-	// Extract form values (in a real app, validate and update in DB)
-	watchedDate := r.FormValue("watched_date")
-	movieTitle := r.FormValue("movie_title")
-	watchedLocation := r.FormValue("watched_location")
-	ratingStr := r.FormValue("rating")
-	notes := r.FormValue("notes")
-	watchedWith := r.FormValue("watched_with")
-
-	// For now, just log the received data (replace with DB update logic)
-	slog.Info("Received edit for diary entry",
-		slog.String("watched_date", watchedDate),
-		slog.String("watched_location", watchedLocation),
-		slog.String("movie_title", movieTitle),
-		slog.String("rating", ratingStr),
-		slog.String("notes", notes),
-		slog.String("watched_with", watchedWith),
-	)
+		errorPage(w, r, http.StatusBadRequest, "Failed to parse form")
+		return
+	}
 
-	// After logging, return to the Movie Details view (in a real app, fetch updated entry from DB)
-	entries := getSampleEntries()
-	entry := getEntryByID(id, entries)
-	if entry == nil {
-		http.Error(w, "Entry not found after edit", http.StatusNotFound)
+	current, err := h.db.GetDiaryEntry(r.Context(), id)
+	if errors.Is(err, database.ErrNotFound) {
+		errorPage(w, r, http.StatusNotFound, "Entry not found")
 		return
 	}
-	err = templates.MovieDetails(*entry).Render(r.Context(), w)
 	if err != nil {
-		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+		slog.Error("Failed to get diary entry", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to save entry")
 		return
 	}
+
+	// Only a changed title is looked up on TMDB; the entry's own movie is in the library
+	titleChanged := !strings.EqualFold(strings.TrimSpace(r.FormValue("movie_title")), current.Movie.Title)
+	input, _, err := h.journal.ParseEntry(r.Context(), r.Form, time.Time{}, titleChanged)
+	if err == nil {
+		input.UpdatedAt = parseEntryVersion(r)
+		err = h.db.UpdateDiaryEntry(r.Context(), id, input)
+	}
+	if verr := journal.FormErrors(err); verr != nil {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		if err := templates.DiaryEditForm(entryFromForm(id, r), verr.Fields).Render(r.Context(), w); err != nil {
+			slog.Error("Failed to render form", slog.String("error", err.Error()))
+		}
+		return
+	}
+	if errors.Is(err, database.ErrConflict) {
+		h.renderEditConflict(w, r, id)
+		return
+	}
+	if errors.Is(err, database.ErrNotFound) {
+		errorPage(w, r, http.StatusNotFound, "Entry not found")
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to update diary entry", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to save entry")
+		return
+	}
+
+	h.renderDiaryEntry(w, r, func(entry models.DiaryEntry, w http.ResponseWriter, r *http.Request) error {
+		average, rated := h.movieAverageRating(r.Context(), entry.MovieID)
+		return templates.MovieDetails(entry, average, rated).Render(r.Context(), w)
+	})
+}
+
+// renderEditConflict re-renders the edit form after the entry changed elsewhere since it
+// was loaded. The user's changes are kept, and the form now carries the entry's current
+// version, so saving again knowingly overwrites the other changes.
+func (h *Handlers) renderEditConflict(w http.ResponseWriter, r *http.Request, id int64) {
+	current, err := h.db.GetDiaryEntry(r.Context(), id)
+	if err != nil {
+		slog.Error("Failed to reload diary entry", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusConflict, "This entry changed elsewhere. Reload it to see the changes.")
+		return
+	}
+
+	entry := entryFromForm(id, r)
+	entry.UpdatedAt = current.UpdatedAt
+	fieldErrors := map[string]string{
+		"updated_at": "This entry changed elsewhere since you opened it. Save again to overwrite those changes.",
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusConflict)
+	if err := templates.DiaryEditForm(entry, fieldErrors).Render(r.Context(), w); err != nil {
+		slog.Error("Failed to render form", slog.String("error", err.Error()))
+	}
 }
 
-// DeleteDiaryEntry deletes a diary entry (for HTMX).
+// DeleteDiaryEntry deletes a diary entry (for HTMX). It answers 404 when there is no
+// entry with that ID.
 func (h *Handlers) DeleteDiaryEntry(w http.ResponseWriter, r *http.Request) {
-	// Extract ID from URL path
-	idStr := r.PathValue("id")
-	// TODO: id will be used when we start working with DB
-	_, err := strconv.ParseInt(idStr, 10, 64)
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		errorPage(w, r, http.StatusBadRequest, "Invalid ID")
 		return
 	}
 
-	// Here you would add code to delete the entry from the database.
+	deleted, err := h.db.DeleteEntries(r.Context(), []int64{id})
+	if err != nil {
+		slog.Error("Failed to delete diary entry", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to delete entry")
+		return
+	}
+	if deleted == 0 {
+		errorPage(w, r, http.StatusNotFound, "Entry not found")
+		return
+	}
+
+	slog.Info("Deleted diary entry", slog.Int64("id", id))
+
 	// Return 200 OK with empty body - HTMX will replace the target with nothing (remove it).
 	// Note: 204 No Content doesn't trigger HTMX swaps by default.
 	w.Header().Set("Content-Type", "text/html")
 	w.WriteHeader(http.StatusOK)
-	// Empty response body - with hx-swap="outerHTML", this removes the element
-}
-
-// getSampleEntries returns sample diary entries for development.
-func getSampleEntries() []models.DiaryEntry {
-	return []models.DiaryEntry{
-		{
-			ID:      1,
-			MovieID: 1,
-			Movie: &models.Movie{
-				ID:        1,
-				TMDBID:    550,
-				Title:     "Fight Club",
-				Year:      1999,
-				PosterURL: "https://image.tmdb.org/t/p/w185/pB8BM7pdSp6B6Ih7QZ4DrQ3PmJK.jpg",
-				Director:  "David Fincher",
-				Genre:     "Drama",
-				Overview: "A depressed man suffering from insomnia meets a strange soap salesman " +
-					"named Tyler Durden and soon finds himself living in his squalid house " +
-					"after his perfect apartment is destroyed.",
-			},
-			WatchedDate:     time.Now().AddDate(0, 0, -2),
-			WatchedLocation: "Home",
-			Rating:          5,
-			Notes:           "First rule of Fight Club...",
-			WatchedWith:     "Sarah",
-			Lookups: []models.Lookup{
-				{
-					ID:       1,
-					Question: "Where was the Paper Street house?",
-					Answer:   "The house was located in Wilmington, Delaware",
-					Category: models.LookupCategoryLocation,
-				},
-			},
-		},
-		{
-			ID:      2,
-			MovieID: 2,
-			Movie: &models.Movie{
-				ID:        2,
-				TMDBID:    27205,
-				Title:     "Inception",
-				Year:      2010,
-				PosterURL: "https://image.tmdb.org/t/p/w185/oYuLEt3zVCKq57qu2F8dT7NIa6f.jpg",
-				Director:  "Christopher Nolan",
-				Genre:     "Sci-Fi",
-				Overview: "Cobb, a skilled thief who commits corporate espionage by infiltrating " +
-					"the subconscious of his targets is offered a chance to regain his old life " +
-					"as payment for a task considered to be impossible: inception.",
-			},
-			WatchedDate:     time.Now().AddDate(0, 0, -5),
-			WatchedLocation: "Cinema",
-			Rating:          3,
-			Notes:           "The ending still gets me every time. Is it real or not?",
-			WatchedWith:     "",
-			Lookups: []models.Lookup{
-				{
-					ID:       2,
-					Question: "Who composed the score?",
-					Answer:   "Hans Zimmer",
-					Category: models.LookupCategoryTrivia,
-				},
-				{
-					ID:       3,
-					Question: "Where was the rotating hallway filmed?",
-					Answer:   "Cardington Studios in Bedfordshire, UK",
-					Category: models.LookupCategoryLocation,
-				},
-			},
-		},
-		{
-			ID:      3,
-			MovieID: 3,
-			Movie: &models.Movie{
-				ID:        3,
-				TMDBID:    680,
-				Title:     "Pulp Fiction",
-				Year:      1994,
-				PosterURL: "https://image.tmdb.org/t/p/w185/d5iIlFn5s0ImszYzBPb8JPIfbXD.jpg",
-				Director:  "Quentin Tarantino",
-				Genre:     "Crime",
-				Overview: "A burger-loving hit man, his philosophical partner, a drug-addled " +
-					"gangster's moll and a washed-up boxer converge in this sprawling, " +
-					"comedic crime caper.",
-			},
-			WatchedDate:     time.Now().AddDate(0, 0, -10),
-			WatchedLocation: "In-flight",
-			Rating:          4,
-			Notes:           "A masterpiece of non-linear storytelling.",
-			WatchedWith:     "Mike",
-			Lookups:         []models.Lookup{},
-		},
-	}
-}
-
-func getEntryByID(id int64, entries []models.DiaryEntry) *models.DiaryEntry {
-	for i := range entries {
-		if entries[i].ID == id {
-			return &entries[i]
+}
+
+// DeleteEntries deletes several diary entries at once and reports how many were removed.
+func (h *Handlers) DeleteEntries(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		errorPage(w, r, http.StatusBadRequest, "Failed to parse form")
+		return
+	}
+
+	ids := make([]int64, 0, len(r.PostForm["id"]))
+	for _, idStr := range r.PostForm["id"] {
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			errorPage(w, r, http.StatusBadRequest, "Invalid ID")
+			return
 		}
+		ids = append(ids, id)
+	}
+
+	deleted, err := h.db.DeleteEntries(r.Context(), ids)
+	if err != nil {
+		slog.Error("Failed to delete diary entries", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to delete entries")
+		return
+	}
+
+	slog.Info("Deleted diary entries",
+		slog.Int("requested", len(ids)),
+		slog.Int("deleted", deleted),
+	)
+
+	err = templates.Toast(fmt.Sprintf("Deleted %d of %d entries", deleted, len(ids))).Render(r.Context(), w)
+	if err != nil {
+		errorPage(w, r, http.StatusInternalServerError, "Failed to render template")
+		return
+	}
+}
+
+// RateEntries gives several diary entries the same rating at once and reports how many
+// were updated. A rating outside 1 to 5 is rejected with 422.
+func (h *Handlers) RateEntries(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		errorPage(w, r, http.StatusBadRequest, "Failed to parse form")
+		return
+	}
+
+	ids := make([]int64, 0, len(r.PostForm["id"]))
+	for _, idStr := range r.PostForm["id"] {
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			errorPage(w, r, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	rating, err := strconv.Atoi(r.PostForm.Get("rating"))
+	if err != nil {
+		errorPage(w, r, http.StatusUnprocessableEntity, "Rating must be a number from 1 to 5")
+		return
+	}
+
+	updated, err := h.db.RateEntries(r.Context(), ids, rating)
+	if errors.Is(err, database.ErrInvalidInput) {
+		errorPage(w, r, http.StatusUnprocessableEntity, "Rating must be a number from 1 to 5")
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to rate diary entries", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to rate entries")
+		return
+	}
+
+	slog.Info("Rated diary entries",
+		slog.Int("requested", len(ids)),
+		slog.Int("updated", updated),
+		slog.Int("rating", rating),
+	)
+
+	err = templates.Toast(fmt.Sprintf("Rated %d of %d entries", updated, len(ids))).Render(r.Context(), w)
+	if err != nil {
+		errorPage(w, r, http.StatusInternalServerError, "Failed to render template")
+		return
 	}
-	return nil
 }
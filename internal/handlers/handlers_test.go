@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pavelanni/movie-journal/internal/database"
+	"github.com/pavelanni/movie-journal/internal/models"
+)
+
+// newTestHandlers returns handlers backed by a fresh database in a temporary directory,
+// without TMDB or answer suggestions.
+func newTestHandlers(t *testing.T) (*Handlers, *database.DB) {
+	t.Helper()
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return New(Config{DB: db, RecentLimit: 20, PerPage: 20}), db
+}
+
+// addTestEntry adds a movie with the given title to the library and logs a viewing of it,
+// returning the entry ID.
+func addTestEntry(t *testing.T, db *database.DB, tmdbID int, title string) int64 {
+	t.Helper()
+	return addRatedEntry(t, db, tmdbID, title, 4)
+}
+
+// addRatedEntry is like addTestEntry, but gives the viewing the rating.
+func addRatedEntry(t *testing.T, db *database.DB, tmdbID int, title string, rating int) int64 {
+	t.Helper()
+	ctx := context.Background()
+	movie, err := db.SaveMovie(ctx, models.Movie{TMDBID: tmdbID, Title: title, Year: 2021})
+	if err != nil {
+		t.Fatalf("saving movie: %v", err)
+	}
+	id, err := db.CreateDiaryEntry(ctx, models.DiaryEntryInput{
+		MovieID:   movie.ID,
+		WatchedAt: time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC),
+		Rating:    rating,
+	})
+	if err != nil {
+		t.Fatalf("creating entry: %v", err)
+	}
+	return id
+}
+
+func TestGetDiaryEntry(t *testing.T) {
+	h, db := newTestHandlers(t)
+	id := addTestEntry(t, db, 438631, "Dune")
+
+	tests := []struct {
+		name     string
+		htmx     bool
+		wantPage bool
+	}{
+		{name: "HTMX request gets a fragment", htmx: true, wantPage: false},
+		{name: "browser request gets a full page", htmx: false, wantPage: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/diary/"+strconv.FormatInt(id, 10), nil)
+			r.SetPathValue("id", strconv.FormatInt(id, 10))
+			if tt.htmx {
+				r.Header.Set("HX-Request", "true")
+			}
+			w := httptest.NewRecorder()
+
+			h.GetDiaryEntry(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+			body := w.Body.String()
+			if !strings.Contains(body, "Dune") {
+				t.Errorf("body doesn't mention the movie:\n%s", body)
+			}
+			if isPage := strings.HasPrefix(body, "<!doctype html>"); isPage != tt.wantPage {
+				t.Errorf("full page = %v, want %v", isPage, tt.wantPage)
+			}
+		})
+	}
+}
+
+func TestGetDiaryEntryNotFound(t *testing.T) {
+	h, _ := newTestHandlers(t)
+
+	r := httptest.NewRequest(http.MethodGet, "/diary/999", nil)
+	r.SetPathValue("id", "999")
+	r.Header.Set("HX-Request", "true")
+	w := httptest.NewRecorder()
+
+	h.GetDiaryEntry(w, r)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestDeleteDiaryEntry(t *testing.T) {
+	h, db := newTestHandlers(t)
+	id := strconv.FormatInt(addTestEntry(t, db, 438631, "Dune"), 10)
+
+	// The second attempt finds nothing left to delete
+	for _, want := range []int{http.StatusOK, http.StatusNotFound} {
+		r := httptest.NewRequest(http.MethodDelete, "/diary/"+id, nil)
+		r.SetPathValue("id", id)
+		r.Header.Set("HX-Request", "true")
+		w := httptest.NewRecorder()
+
+		h.DeleteDiaryEntry(w, r)
+
+		if w.Code != want {
+			t.Errorf("status = %d, want %d", w.Code, want)
+		}
+	}
+}
+
+func TestHomeEmptyState(t *testing.T) {
+	h, db := newTestHandlers(t)
+
+	get := func(target string) string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.Home(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: status = %d, want %d", target, w.Code, http.StatusOK)
+		}
+		return w.Body.String()
+	}
+
+	home := get("/")
+	if !strings.Contains(home, `id="empty-diary"`) || !strings.Contains(home, "Log your first film") {
+		t.Errorf("empty diary doesn't show the welcome:\n%s", home)
+	}
+	if strings.Contains(home, `id="entry-`) {
+		t.Error("empty diary renders entry cards")
+	}
+
+	// A filter that matches nothing isn't an empty diary
+	addTestEntry(t, db, 438631, "Dune")
+	if home := get("/?format=vhs"); strings.Contains(home, `id="empty-diary"`) {
+		t.Error("filtered home page shows the empty-diary welcome although the diary has entries")
+	}
+}
+
+func TestHomeShowsRecentLimit(t *testing.T) {
+	_, db := newTestHandlers(t)
+	h := New(Config{DB: db, RecentLimit: 3, PerPage: 2})
+	for i, title := range []string{"Alien", "Aliens", "Alien 3", "Prometheus", "Covenant"} {
+		addTestEntry(t, db, i+1, title)
+	}
+
+	get := func(handler http.HandlerFunc, target string) string {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		w := httptest.NewRecorder()
+		handler(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: status = %d, want %d", target, w.Code, http.StatusOK)
+		}
+		return w.Body.String()
+	}
+
+	// The home page shows the recent limit, independent of the diary's page size
+	home := get(h.Home, "/")
+	if n := strings.Count(home, `id="entry-`); n != 3 {
+		t.Errorf("home page shows %d entries, want 3", n)
+	}
+	if !strings.Contains(home, "View all entries") {
+		t.Error("home page doesn't link to the full diary")
+	}
+
+	diary := get(h.Diary, "/diary")
+	if n := strings.Count(diary, `id="entry-`); n != 2 {
+		t.Errorf("diary page shows %d entries, want 2", n)
+	}
+
+	// No link when everything fits
+	h = New(Config{DB: db, RecentLimit: 5, PerPage: 2})
+	if home := get(h.Home, "/"); strings.Contains(home, "View all entries") {
+		t.Error("home page links to the full diary although it shows every entry")
+	}
+}
+
+func TestYearCount(t *testing.T) {
+	h, db := newTestHandlers(t)
+	ctx := context.Background()
+	movie, err := db.SaveMovie(ctx, models.Movie{TMDBID: 438631, Title: "Dune", Year: 2021})
+	if err != nil {
+		t.Fatalf("saving movie: %v", err)
+	}
+	year := time.Now().Year()
+	// Two viewings this year and one last year, which the badge leaves out
+	for _, watched := range []time.Time{
+		time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(year, time.January, 2, 0, 0, 0, 0, time.UTC),
+		time.Date(year-1, time.December, 31, 0, 0, 0, 0, time.UTC),
+	} {
+		if _, err := db.CreateDiaryEntry(ctx, models.DiaryEntryInput{MovieID: movie.ID, WatchedAt: watched}); err != nil {
+			t.Fatalf("creating entry: %v", err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h.YearCount(w, httptest.NewRequest(http.MethodGet, "/year-count", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if want := "2 films in " + strconv.Itoa(year); !strings.Contains(w.Body.String(), want) {
+		t.Errorf("badge = %q, want %q", w.Body, want)
+	}
+}
+
+func TestTaste(t *testing.T) {
+	h, db := newTestHandlers(t)
+	ctx := context.Background()
+	for i, rating := range []int{5, 4, 3} {
+		id := addRatedEntry(t, db, 438631+i, "Dune "+strconv.Itoa(i+1), rating)
+		entry, err := db.GetDiaryEntry(ctx, id)
+		if err != nil {
+			t.Fatalf("getting entry: %v", err)
+		}
+		genres := []string{"Science Fiction"}
+		if i == 0 {
+			genres = append(genres, "Adventure")
+		}
+		if err := db.SetMovieGenres(ctx, entry.MovieID, genres); err != nil {
+			t.Fatalf("setting genres: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		target   string
+		want     []string
+		dontWant []string
+	}{
+		{
+			name:     "default minimum",
+			target:   "/taste",
+			want:     []string{"Science Fiction", "3 ratings", "4.0", "at least 3 rated entries"},
+			dontWant: []string{"Adventure"},
+		},
+		{
+			name:   "lower minimum",
+			target: "/taste?min=1",
+			want:   []string{"Science Fiction", "Adventure", "1 rating", "5.0", "at least 1 rated entry"},
+		},
+		{
+			name:   "invalid minimum",
+			target: "/taste?min=-2",
+			want:   []string{"at least 3 rated entries"},
+		},
+		{
+			name:     "none with enough",
+			target:   "/taste?min=10",
+			want:     []string{"Not enough rated entries yet."},
+			dontWant: []string{"Science Fiction"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.Taste(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+			body := w.Body.String()
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("page doesn't contain %q", want)
+				}
+			}
+			for _, dontWant := range tt.dontWant {
+				if strings.Contains(body, dontWant) {
+					t.Errorf("page contains %q", dontWant)
+				}
+			}
+		})
+	}
+}
+
+// rateEntries submits the bulk rating form for ids.
+func rateEntries(h *Handlers, rating string, ids ...int64) *httptest.ResponseRecorder {
+	form := url.Values{"rating": {rating}}
+	for _, id := range ids {
+		form.Add("id", strconv.FormatInt(id, 10))
+	}
+	r := httptest.NewRequest(http.MethodPost, "/entries/rate", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("HX-Request", "true")
+	w := httptest.NewRecorder()
+	h.RateEntries(w, r)
+	return w
+}
+
+func TestRateEntries(t *testing.T) {
+	h, db := newTestHandlers(t)
+	first := addTestEntry(t, db, 1, "Alien")
+	second := addTestEntry(t, db, 2, "Aliens")
+
+	w := rateEntries(h, "5", first, second, 9999)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d:\n%s", w.Code, http.StatusOK, w.Body)
+	}
+	if !strings.Contains(w.Body.String(), "Rated 2 of 3 entries") {
+		t.Errorf("toast doesn't count the rated entries:\n%s", w.Body)
+	}
+	for _, id := range []int64{first, second} {
+		entry, err := db.GetDiaryEntry(context.Background(), id)
+		if err != nil {
+			t.Fatalf("getting entry %d: %v", id, err)
+		}
+		if entry.Rating != 5 {
+			t.Errorf("entry %d rating = %d, want 5", id, entry.Rating)
+		}
+	}
+}
+
+func TestRateEntriesInvalidRating(t *testing.T) {
+	h, db := newTestHandlers(t)
+	id := addTestEntry(t, db, 1, "Alien")
+
+	for _, rating := range []string{"0", "6", "five", ""} {
+		t.Run(rating, func(t *testing.T) {
+			w := rateEntries(h, rating, id)
+
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+			}
+			entry, err := db.GetDiaryEntry(context.Background(), id)
+			if err != nil {
+				t.Fatalf("getting entry: %v", err)
+			}
+			if entry.Rating != 4 {
+				t.Errorf("rating = %d after a rejected rating, want 4", entry.Rating)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/pavelanni/movie-journal/internal/database"
+	"github.com/pavelanni/movie-journal/internal/models"
+	"github.com/pavelanni/movie-journal/templates"
+)
+
+const (
+	// idempotencyKeyHeader lets clients retry entry creation without creating duplicates.
+	idempotencyKeyHeader = "Idempotency-Key"
+	// idempotencyTTL is how long a key keeps returning the entry it created.
+	idempotencyTTL = 24 * time.Hour
+	// maxIdempotencyKeyLength caps the keys we're willing to store.
+	maxIdempotencyKeyLength = 255
+)
+
+// replayIdempotentEntry responds to a retried creation with the entry the key already
+// created and reports whether it did. Requests without a key, or with a key it hasn't
+// seen, are left to be handled normally. This only spares retries the work of reading the
+// form; saveEntry is what keeps concurrent retries from creating duplicates.
+func (h *Handlers) replayIdempotentEntry(w http.ResponseWriter, r *http.Request, key string) bool {
+	if key == "" {
+		return false
+	}
+
+	id, err := h.db.IdempotentEntry(r.Context(), key, time.Now().Add(-idempotencyTTL))
+	if errors.Is(err, database.ErrNotFound) {
+		return false
+	}
+	if err != nil {
+		slog.Error("Failed to look up idempotency key", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to save entry")
+		return true
+	}
+
+	h.renderReplayedEntry(w, r, id)
+	return true
+}
+
+// renderReplayedEntry responds with the entry an earlier request with the same key
+// created, as if it had just been created.
+func (h *Handlers) renderReplayedEntry(w http.ResponseWriter, r *http.Request, id int64) {
+	if !isHTMX(r) {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	entry, err := h.db.GetDiaryEntry(r.Context(), id)
+	if err != nil {
+		slog.Error("Failed to get diary entry", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to load entry")
+		return
+	}
+	// The first response already mentioned any earlier viewing
+	if err := templates.DiaryEntryCreated(*entry, nil).Render(r.Context(), w); err != nil {
+		errorPage(w, r, http.StatusInternalServerError, "Failed to render template")
+	}
+}
+
+// saveEntry creates the diary entry, or with a key, returns the entry an earlier
+// request with the same key created. It reports whether the entry is new. Expired keys
+// are cleared out along the way; failing to do so is only logged.
+func (h *Handlers) saveEntry(
+	ctx context.Context, key string, input models.DiaryEntryInput,
+) (int64, bool, error) {
+	if key == "" {
+		id, err := h.db.CreateDiaryEntry(ctx, input)
+		return id, err == nil, err
+	}
+
+	notBefore := time.Now().Add(-idempotencyTTL)
+	id, created, err := h.db.CreateDiaryEntryOnce(ctx, key, notBefore, input)
+	if err != nil {
+		return 0, false, err
+	}
+	if _, err := h.db.DeleteIdempotencyKeysBefore(ctx, notBefore); err != nil {
+		slog.Warn("Failed to delete expired idempotency keys", slog.String("error", err.Error()))
+	}
+	return id, created, nil
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCreateDiaryEntryIdempotencyKey(t *testing.T) {
+	h, db := newTestHandlers(t)
+	addTestEntry(t, db, 438631, "Dune")
+	ctx := context.Background()
+
+	post := func(key string) int {
+		t.Helper()
+		form := url.Values{"movie_title": {"Dune"}, "watched_date": {"2024-07-01"}, "rating": {"5"}}
+		r := httptest.NewRequest(http.MethodPost, "/diary", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("HX-Request", "true")
+		r.Header.Set(idempotencyKeyHeader, key)
+		w := httptest.NewRecorder()
+
+		h.CreateDiaryEntry(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d:\n%s", w.Code, http.StatusOK, w.Body)
+		}
+		count, err := db.CountDiaryEntries(ctx)
+		if err != nil {
+			t.Fatalf("counting entries: %v", err)
+		}
+		return count
+	}
+
+	if count := post("first"); count != 2 {
+		t.Errorf("after the first request the diary has %d entries, want 2", count)
+	}
+	if count := post("first"); count != 2 {
+		t.Errorf("after repeating the key the diary has %d entries, want still 2", count)
+	}
+	if count := post("second"); count != 3 {
+		t.Errorf("after a new key the diary has %d entries, want 3", count)
+	}
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/pavelanni/movie-journal/templates"
+)
+
+// maxIncompleteEntries caps the number of entries the home page asks to fill in.
+const maxIncompleteEntries = 5
+
+// IncompleteEntries lists recent entries still missing a rating or notes (HTML fragment
+// for HTMX). It renders nothing when every entry is complete.
+func (h *Handlers) IncompleteEntries(w http.ResponseWriter, r *http.Request) {
+	entries, err := h.db.IncompleteEntries(r.Context(), maxIncompleteEntries)
+	if err != nil {
+		slog.Error("Failed to list incomplete entries", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to load entries")
+		return
+	}
+
+	err = templates.IncompleteEntries(entries).Render(r.Context(), w)
+	if err != nil {
+		errorPage(w, r, http.StatusInternalServerError, "Failed to render template")
+		return
+	}
+}
@@ -0,0 +1,460 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/a-h/templ"
+	"github.com/pavelanni/movie-journal/internal/answers"
+	"github.com/pavelanni/movie-journal/internal/database"
+	"github.com/pavelanni/movie-journal/internal/models"
+	"github.com/pavelanni/movie-journal/templates"
+)
+
+// CreateLookup adds a research moment to a diary entry (HTML fragment for HTMX).
+func (h *Handlers) CreateLookup(w http.ResponseWriter, r *http.Request) {
+	entryID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		errorPage(w, r, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		errorPage(w, r, http.StatusBadRequest, "Failed to parse form")
+		return
+	}
+
+	lookup, err := h.db.CreateLookup(r.Context(), models.LookupInput{
+		DiaryEntryID: entryID,
+		Question:     r.FormValue("question"),
+		Answer:       r.FormValue("answer"),
+		Category:     models.LookupCategory(r.FormValue("category")),
+		URL:          r.FormValue("url"),
+	})
+	var verr *models.ValidationError
+	switch {
+	case errors.As(err, &verr):
+		errorPage(w, r, http.StatusUnprocessableEntity, verr.Error())
+		return
+	case errors.Is(err, database.ErrInvalidInput):
+		errorPage(w, r, http.StatusUnprocessableEntity, err.Error())
+		return
+	case errors.Is(err, database.ErrNotFound):
+		errorPage(w, r, http.StatusNotFound, "Entry not found")
+		return
+	case err != nil:
+		slog.Error("Failed to create lookup", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to save lookup")
+		return
+	}
+
+	err = templates.EditableLookup(*lookup).Render(r.Context(), w)
+	if err != nil {
+		errorPage(w, r, http.StatusInternalServerError, "Failed to render template")
+		return
+	}
+}
+
+// SearchEntryLookups returns an entry's lookups whose question or answer contains the
+// q query parameter (HTML fragment for HTMX). An empty query returns them all, and
+// unanswered=1 returns only the questions still waiting for an answer.
+func (h *Handlers) SearchEntryLookups(w http.ResponseWriter, r *http.Request) {
+	entryID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		errorPage(w, r, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+
+	query := r.URL.Query().Get("q")
+	var lookups []models.Lookup
+	empty := "No research moments yet."
+	if r.URL.Query().Get("unanswered") == "1" {
+		lookups, err = h.db.UnansweredLookups(r.Context(), entryID)
+		empty = "Every question has an answer."
+	} else {
+		lookups, err = h.db.SearchLookups(r.Context(), entryID, query)
+		if strings.TrimSpace(query) != "" {
+			empty = fmt.Sprintf(`No research moments match "%s".`, query)
+		}
+	}
+	if errors.Is(err, database.ErrNotFound) {
+		errorPage(w, r, http.StatusNotFound, "Entry not found")
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to search lookups", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to load lookups")
+		return
+	}
+
+	fragment := templates.EntryLookups(lookups, empty)
+	if r.URL.Query().Get("unanswered") != "1" && strings.TrimSpace(query) == "" {
+		// The whole list is shown, so it can be reordered
+		fragment = templates.SortableLookups(lookups)
+	}
+	err = renderFragment(w, r, "Research Moments", fragment)
+	if err != nil {
+		errorPage(w, r, http.StatusInternalServerError, "Failed to render template")
+		return
+	}
+}
+
+// ReorderEntryLookups saves a new order for a diary entry's lookups, given as repeated
+// lookup form values listing every lookup of the entry, and renders the reordered list.
+func (h *Handlers) ReorderEntryLookups(w http.ResponseWriter, r *http.Request) {
+	entryID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		errorPage(w, r, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		errorPage(w, r, http.StatusBadRequest, "Invalid form data")
+		return
+	}
+
+	ids := make([]int64, 0, len(r.PostForm["lookup"]))
+	for _, value := range r.PostForm["lookup"] {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			errorPage(w, r, http.StatusBadRequest, "Invalid lookup ID")
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	err = h.db.ReorderLookups(r.Context(), entryID, ids)
+	if errors.Is(err, database.ErrNotFound) {
+		errorPage(w, r, http.StatusNotFound, "Entry not found")
+		return
+	}
+	if errors.Is(err, database.ErrInvalidInput) {
+		errorPage(w, r, http.StatusBadRequest, "The new order must list each of the entry's research moments once")
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to reorder lookups", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to reorder lookups")
+		return
+	}
+
+	lookups, err := h.db.SearchLookups(r.Context(), entryID, "")
+	if err != nil {
+		slog.Error("Failed to list lookups", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to load lookups")
+		return
+	}
+
+	err = renderFragment(w, r, "Research Moments", templates.SortableLookups(lookups))
+	if err != nil {
+		errorPage(w, r, http.StatusInternalServerError, "Failed to render template")
+		return
+	}
+}
+
+// CiteLookup returns a lookup as a plain-text citation for sharing, naming the movie it
+// came up in and linking its source if it has one.
+func (h *Handlers) CiteLookup(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		errorPage(w, r, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+
+	lookup, err := h.db.GetLookup(r.Context(), id)
+	var entry *models.DiaryEntry
+	if err == nil {
+		entry, err = h.db.GetDiaryEntry(r.Context(), lookup.DiaryEntryID)
+	}
+	if errors.Is(err, database.ErrNotFound) {
+		errorPage(w, r, http.StatusNotFound, "Lookup not found")
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to load lookup for citation", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to load lookup")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = fmt.Fprintln(w, formatCitation(lookup, entry.Movie))
+}
+
+// formatCitation formats a lookup as `"<answer>" (<title>, <year>) — <url>`. The question
+// stands in for a missing answer, and the year and URL are left out when unknown.
+func formatCitation(lookup *models.Lookup, movie *models.Movie) string {
+	fact := lookup.Answer
+	if fact == "" {
+		fact = lookup.Question
+	}
+
+	citation := `"` + fact + `"`
+	if movie != nil {
+		if movie.Year != 0 {
+			citation += fmt.Sprintf(" (%s, %d)", movie.Title, movie.Year)
+		} else {
+			citation += " (" + movie.Title + ")"
+		}
+	}
+	if lookup.URL != "" {
+		citation += " — " + lookup.URL
+	}
+	return citation
+}
+
+// maxBatchLookups caps the number of lookups created by one batch request.
+const maxBatchLookups = 100
+
+// maxBatchBodyBytes caps the size of a batch request body.
+const maxBatchBodyBytes = 1 << 20
+
+// batchLookupError is the JSON body of a rejected batch, naming the failing item.
+type batchLookupError struct {
+	Fields map[string]string `json:"fields,omitempty"`
+	Error  string            `json:"error"`
+	Index  int               `json:"index"`
+}
+
+// CreateLookupsBatch adds several research moments to a diary entry from a JSON array of
+// lookups, all or nothing, and returns the created lookups as JSON. If any lookup is
+// invalid, none are saved and the 422 response names its index.
+func (h *Handlers) CreateLookupsBatch(w http.ResponseWriter, r *http.Request) {
+	entryID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		errorPage(w, r, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+
+	var inputs []models.LookupInput
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBodyBytes)).Decode(&inputs); err != nil {
+		errorPage(w, r, http.StatusBadRequest, "Request body must be a JSON array of lookups")
+		return
+	}
+	if len(inputs) == 0 || len(inputs) > maxBatchLookups {
+		errorPage(w, r, http.StatusUnprocessableEntity, fmt.Sprintf("A batch must have 1 to %d lookups", maxBatchLookups))
+		return
+	}
+
+	lookups, err := h.db.CreateLookups(r.Context(), entryID, inputs)
+	var berr *database.BatchError
+	switch {
+	case errors.As(err, &berr):
+		body := batchLookupError{Index: berr.Index, Error: berr.Err.Error()}
+		var verr *models.ValidationError
+		if errors.As(berr.Err, &verr) {
+			body.Error = "invalid lookup"
+			body.Fields = verr.Fields
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_ = json.NewEncoder(w).Encode(body)
+		return
+	case errors.Is(err, database.ErrNotFound):
+		errorPage(w, r, http.StatusNotFound, "Entry not found")
+		return
+	case err != nil:
+		slog.Error("Failed to create lookups", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to save lookups")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(lookups)
+}
+
+// LookupItem renders a single lookup, e.g. to swap it back in when an edit is canceled.
+func (h *Handlers) LookupItem(w http.ResponseWriter, r *http.Request) {
+	h.renderLookup(w, r, func(lookup models.Lookup) templ.Component {
+		return templates.EditableLookup(lookup)
+	})
+}
+
+// EditLookupForm renders the inline form for editing a lookup.
+func (h *Handlers) EditLookupForm(w http.ResponseWriter, r *http.Request) {
+	h.renderLookup(w, r, func(lookup models.Lookup) templ.Component {
+		return templates.LookupEditForm(lookup, nil)
+	})
+}
+
+// renderLookup loads the lookup named in the path and renders the component built from it.
+func (h *Handlers) renderLookup(w http.ResponseWriter, r *http.Request, component func(models.Lookup) templ.Component) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		errorPage(w, r, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+
+	lookup, err := h.db.GetLookup(r.Context(), id)
+	if errors.Is(err, database.ErrNotFound) {
+		errorPage(w, r, http.StatusNotFound, "Lookup not found")
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to get lookup", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to load lookup")
+		return
+	}
+
+	if err := component(*lookup).Render(r.Context(), w); err != nil {
+		errorPage(w, r, http.StatusInternalServerError, "Failed to render template")
+	}
+}
+
+// UpdateLookup saves an inline lookup edit and swaps the updated lookup back in.
+// Invalid input re-renders the edit form with the errors next to their fields.
+func (h *Handlers) UpdateLookup(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		errorPage(w, r, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		errorPage(w, r, http.StatusBadRequest, "Failed to parse form")
+		return
+	}
+
+	input := models.LookupInput{
+		Question: r.FormValue("question"),
+		Answer:   r.FormValue("answer"),
+		Category: models.LookupCategory(r.FormValue("category")),
+		URL:      r.FormValue("url"),
+	}
+	err = h.db.UpdateLookup(r.Context(), id, input)
+	var verr *models.ValidationError
+	switch {
+	case errors.As(err, &verr):
+		submitted := models.Lookup{
+			ID:       id,
+			Question: input.Question,
+			Answer:   input.Answer,
+			Category: input.Category,
+			URL:      input.URL,
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		if err := templates.LookupEditForm(submitted, verr.Fields).Render(r.Context(), w); err != nil {
+			slog.Error("Failed to render form", slog.String("error", err.Error()))
+		}
+		return
+	case errors.Is(err, database.ErrNotFound):
+		errorPage(w, r, http.StatusNotFound, "Lookup not found")
+		return
+	case err != nil:
+		slog.Error("Failed to update lookup", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to save lookup")
+		return
+	}
+
+	h.renderLookup(w, r, func(lookup models.Lookup) templ.Component {
+		return templates.EditableLookup(lookup)
+	})
+}
+
+// lookupsPageSize is the number of lookups loaded at a time on the category page.
+const lookupsPageSize = 20
+
+// LookupsByCategory browses lookups in one category, a page at a time. HTMX requests
+// for later pages get just the next page of lookups.
+func (h *Handlers) LookupsByCategory(w http.ResponseWriter, r *http.Request) {
+	category := models.LookupCategory(r.PathValue("category"))
+	if !category.Valid() {
+		errorPage(w, r, http.StatusNotFound, "Unknown category")
+		return
+	}
+
+	var after *database.LookupCursor
+	if raw := r.URL.Query().Get("after"); raw != "" {
+		cursor, err := database.ParseLookupCursor(raw)
+		if err != nil {
+			errorPage(w, r, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		after = &cursor
+	}
+
+	_, perPage := parsePagination(r)
+	if perPage == 0 {
+		perPage = lookupsPageSize
+	}
+
+	lookups, next, err := h.db.ListLookupsByCategory(r.Context(), category, after, perPage)
+	if err != nil {
+		slog.Error("Failed to list lookups", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to load lookups")
+		return
+	}
+	var nextURL string
+	if next != nil {
+		nextURL = lookupsNextURL(category, next, perPage)
+	}
+
+	if isHTMX(r) && after != nil {
+		err = templates.LookupsPage(lookups, nextURL).Render(r.Context(), w)
+	} else {
+		err = templates.LookupsByCategory(category, lookups, nextURL).Render(r.Context(), w)
+	}
+	if err != nil {
+		errorPage(w, r, http.StatusInternalServerError, "Failed to render template")
+		return
+	}
+}
+
+// lookupsNextURL returns the URL of the page of lookups after the cursor, keeping a
+// non-default page size.
+func lookupsNextURL(category models.LookupCategory, after *database.LookupCursor, perPage int) string {
+	params := url.Values{"after": {after.String()}}
+	if perPage != lookupsPageSize {
+		params.Set("per_page", strconv.Itoa(perPage))
+	}
+	return "/lookups/" + url.PathEscape(string(category)) + "?" + params.Encode()
+}
+
+// SuggestAnswer looks up a candidate answer for a lookup question, as JSON or as form
+// fields the user can edit and accept, depending on the Accept header.
+func (h *Handlers) SuggestAnswer(w http.ResponseWriter, r *http.Request) {
+	if h.answerer == nil {
+		errorPage(w, r, http.StatusNotFound, "Answer suggestions are disabled")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		errorPage(w, r, http.StatusBadRequest, "Failed to parse form")
+		return
+	}
+
+	question := strings.TrimSpace(r.FormValue("question"))
+	if question == "" {
+		errorPage(w, r, http.StatusUnprocessableEntity, "question: is required")
+		return
+	}
+
+	suggestion, err := h.answerer.Suggest(r.Context(), question)
+	if errors.Is(err, answers.ErrNoAnswer) {
+		errorPage(w, r, http.StatusNotFound, "No answer found")
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to suggest answer", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusBadGateway, "Failed to look up an answer")
+		return
+	}
+
+	w.Header().Add("Vary", "Accept")
+	if prefersJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(suggestion)
+	} else {
+		err = templates.AnswerSuggestion(suggestion.Answer, suggestion.URL).Render(r.Context(), w)
+	}
+	if err != nil {
+		errorPage(w, r, http.StatusInternalServerError, "Failed to render template")
+		return
+	}
+}
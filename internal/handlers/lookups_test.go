@@ -0,0 +1,554 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pavelanni/movie-journal/internal/models"
+)
+
+// postLookupsBatch posts a batch of lookups for the entry.
+func postLookupsBatch(h *Handlers, entryID int64, body string) *httptest.ResponseRecorder {
+	id := strconv.FormatInt(entryID, 10)
+	r := httptest.NewRequest(http.MethodPost, "/api/entries/"+id+"/lookups/batch", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	r.SetPathValue("id", id)
+	w := httptest.NewRecorder()
+	h.CreateLookupsBatch(w, r)
+	return w
+}
+
+func TestCreateLookupsBatch(t *testing.T) {
+	h, db := newTestHandlers(t)
+	entryID := addTestEntry(t, db, 438631, "Dune")
+
+	w := postLookupsBatch(h, entryID, `[
+		{"question": "Where was it filmed?", "answer": "Jordan", "category": "location"},
+		{"question": "Who plays Chani?", "answer": "Zendaya", "category": "actor"}
+	]`)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d:\n%s", w.Code, http.StatusCreated, w.Body)
+	}
+	var created []models.Lookup
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if len(created) != 2 || created[0].Answer != "Jordan" || created[1].Answer != "Zendaya" {
+		t.Errorf("created = %+v, want both lookups in order", created)
+	}
+
+	entry, err := db.GetDiaryEntry(context.Background(), entryID)
+	if err != nil {
+		t.Fatalf("GetDiaryEntry: %v", err)
+	}
+	if len(entry.Lookups) != 2 {
+		t.Errorf("entry has %d lookups, want 2", len(entry.Lookups))
+	}
+}
+
+func TestCreateLookupsBatchRollsBack(t *testing.T) {
+	h, db := newTestHandlers(t)
+	entryID := addTestEntry(t, db, 438631, "Dune")
+
+	w := postLookupsBatch(h, entryID, `[
+		{"question": "Where was it filmed?", "answer": "Jordan", "category": "location"},
+		{"question": "", "answer": "Nobody asked", "category": "nonsense"},
+		{"question": "Who plays Chani?", "answer": "Zendaya", "category": "actor"}
+	]`)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d:\n%s", w.Code, http.StatusUnprocessableEntity, w.Body)
+	}
+	var body batchLookupError
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if body.Index != 1 {
+		t.Errorf("index = %d, want 1", body.Index)
+	}
+	if body.Fields["question"] == "" || body.Fields["category"] == "" {
+		t.Errorf("fields = %v, want question and category errors", body.Fields)
+	}
+
+	entry, err := db.GetDiaryEntry(context.Background(), entryID)
+	if err != nil {
+		t.Fatalf("GetDiaryEntry: %v", err)
+	}
+	if len(entry.Lookups) != 0 {
+		t.Errorf("entry has %d lookups after a rejected batch, want none", len(entry.Lookups))
+	}
+}
+
+// submitLookupForm submits a lookup form to the handler with HTMX, setting the id path value.
+func submitLookupForm(handler http.HandlerFunc, method, path, id string, form url.Values) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("HX-Request", "true")
+	r.SetPathValue("id", id)
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func TestCreateLookupURL(t *testing.T) {
+	tests := []struct {
+		name       string
+		url        string
+		wantStatus int
+	}{
+		{name: "https", url: "https://en.wikipedia.org/wiki/Dune_(2021_film)", wantStatus: http.StatusOK},
+		{name: "http", url: "http://example.com/dune", wantStatus: http.StatusOK},
+		{name: "none", url: "", wantStatus: http.StatusOK},
+		{name: "javascript", url: "javascript:alert(document.cookie)", wantStatus: http.StatusUnprocessableEntity},
+		{name: "javascript uppercase", url: "JavaScript:alert(1)", wantStatus: http.StatusUnprocessableEntity},
+		{name: "data", url: "data:text/html,<script>alert(1)</script>", wantStatus: http.StatusUnprocessableEntity},
+		{name: "relative", url: "/diary/1", wantStatus: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, db := newTestHandlers(t)
+			entryID := addTestEntry(t, db, 438631, "Dune")
+			id := strconv.FormatInt(entryID, 10)
+
+			form := url.Values{"question": {"Where was it filmed?"}, "category": {"location"}, "url": {tt.url}}
+			w := submitLookupForm(h.CreateLookup, http.MethodPost, "/diary/"+id+"/lookups", id, form)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d:\n%s", w.Code, tt.wantStatus, w.Body)
+			}
+			entry, err := db.GetDiaryEntry(context.Background(), entryID)
+			if err != nil {
+				t.Fatalf("getting entry: %v", err)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if !strings.Contains(w.Body.String(), "url") {
+					t.Errorf("response doesn't say the URL is invalid: %s", w.Body)
+				}
+				if len(entry.Lookups) != 0 {
+					t.Errorf("entry has %d lookups after a rejected URL, want none", len(entry.Lookups))
+				}
+				return
+			}
+			if len(entry.Lookups) != 1 || entry.Lookups[0].URL != tt.url {
+				t.Fatalf("lookups = %+v, want one with URL %q", entry.Lookups, tt.url)
+			}
+			body := w.Body.String()
+			if hasLink := strings.Contains(body, `rel="noopener noreferrer"`); hasLink != (tt.url != "") {
+				t.Errorf("source link shown = %t, want %t:\n%s", hasLink, tt.url != "", body)
+			}
+			if tt.url != "" && !strings.Contains(body, `target="_blank"`) {
+				t.Errorf("source link doesn't open in a new tab:\n%s", body)
+			}
+		})
+	}
+}
+
+func TestUpdateLookupRejectsUnsafeURL(t *testing.T) {
+	h, db := newTestHandlers(t)
+	ctx := context.Background()
+	entryID := addTestEntry(t, db, 438631, "Dune")
+	const source = "https://en.wikipedia.org/wiki/Wadi_Rum"
+	lookup, err := db.CreateLookup(ctx, models.LookupInput{
+		DiaryEntryID: entryID, Question: "Where was it filmed?", Category: models.LookupCategoryLocation, URL: source,
+	})
+	if err != nil {
+		t.Fatalf("creating lookup: %v", err)
+	}
+	id := strconv.FormatInt(lookup.ID, 10)
+
+	form := url.Values{"question": {"Where was it filmed?"}, "category": {"location"}, "url": {"javascript:alert(1)"}}
+	w := submitLookupForm(h.UpdateLookup, http.MethodPut, "/lookups/"+id, id, form)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+	entry, err := db.GetDiaryEntry(ctx, entryID)
+	if err != nil {
+		t.Fatalf("getting entry: %v", err)
+	}
+	if got := entry.Lookups[0].URL; got != source {
+		t.Errorf("URL = %q after a rejected update, want it unchanged", got)
+	}
+}
+
+func TestEditLookupForm(t *testing.T) {
+	h, db := newTestHandlers(t)
+	entryID := addTestEntry(t, db, 438631, "Dune")
+	lookup, err := db.CreateLookup(context.Background(), models.LookupInput{
+		DiaryEntryID: entryID, Question: "Where was it filmed?", Answer: "Jordn", Category: models.LookupCategoryLocation,
+	})
+	if err != nil {
+		t.Fatalf("creating lookup: %v", err)
+	}
+	id := strconv.FormatInt(lookup.ID, 10)
+
+	tests := []struct {
+		name       string
+		id         string
+		want       []string
+		wantStatus int
+	}{
+		{
+			name: "existing lookup", id: id, wantStatus: http.StatusOK,
+			want: []string{
+				`<form id="lookup-` + id + `"`,
+				`hx-put="/lookups/` + id + `"`,
+				`value="Where was it filmed?"`,
+				">Jordn</textarea>",
+				`<option value="location" selected>`,
+			},
+		},
+		{name: "missing lookup", id: "999", wantStatus: http.StatusNotFound},
+		{name: "invalid ID", id: "abc", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/lookups/"+tt.id+"/edit", nil)
+			r.Header.Set("HX-Request", "true")
+			r.SetPathValue("id", tt.id)
+			w := httptest.NewRecorder()
+
+			h.EditLookupForm(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(w.Body.String(), want) {
+					t.Errorf("edit form doesn't contain %s:\n%s", want, w.Body)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateLookupInline(t *testing.T) {
+	h, db := newTestHandlers(t)
+	ctx := context.Background()
+	entryID := addTestEntry(t, db, 438631, "Dune")
+	lookup, err := db.CreateLookup(ctx, models.LookupInput{
+		DiaryEntryID: entryID, Question: "Where was it filmed?", Answer: "Jordn", Category: models.LookupCategoryLocation,
+	})
+	if err != nil {
+		t.Fatalf("creating lookup: %v", err)
+	}
+	id := strconv.FormatInt(lookup.ID, 10)
+
+	form := url.Values{
+		"question": {"Where was it filmed?"},
+		"answer":   {"Wadi Rum, Jordan"},
+		"category": {"location"},
+		"url":      {"https://en.wikipedia.org/wiki/Wadi_Rum"},
+	}
+	w := submitLookupForm(h.UpdateLookup, http.MethodPut, "/lookups/"+id, id, form)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d:\n%s", w.Code, http.StatusOK, w.Body)
+	}
+	body := w.Body.String()
+	// The saved lookup replaces the form in place
+	if !strings.HasPrefix(body, `<div id="lookup-`+id+`"`) || strings.Contains(body, "<form") {
+		t.Errorf("response isn't the rendered lookup:\n%s", body)
+	}
+	if !strings.Contains(body, "Wadi Rum, Jordan") {
+		t.Errorf("response doesn't show the new answer:\n%s", body)
+	}
+	saved, err := db.GetLookup(ctx, lookup.ID)
+	if err != nil {
+		t.Fatalf("getting lookup: %v", err)
+	}
+	if saved.Answer != "Wadi Rum, Jordan" || saved.URL != form.Get("url") {
+		t.Errorf("saved lookup = %+v, want the edit applied", saved)
+	}
+}
+
+func TestUpdateLookupInvalidCategory(t *testing.T) {
+	h, db := newTestHandlers(t)
+	ctx := context.Background()
+	entryID := addTestEntry(t, db, 438631, "Dune")
+	lookup, err := db.CreateLookup(ctx, models.LookupInput{
+		DiaryEntryID: entryID, Question: "Who plays Chani?", Answer: "Zendaya", Category: models.LookupCategoryActor,
+	})
+	if err != nil {
+		t.Fatalf("creating lookup: %v", err)
+	}
+	id := strconv.FormatInt(lookup.ID, 10)
+
+	form := url.Values{"question": {"Who plays Chani?"}, "answer": {"Zendaya"}, "category": {"gossip"}}
+	w := submitLookupForm(h.UpdateLookup, http.MethodPut, "/lookups/"+id, id, form)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+	if !strings.Contains(w.Body.String(), `<form id="lookup-`+id+`"`) {
+		t.Errorf("response doesn't re-render the edit form:\n%s", w.Body)
+	}
+	saved, err := db.GetLookup(ctx, lookup.ID)
+	if err != nil {
+		t.Fatalf("getting lookup: %v", err)
+	}
+	if saved.Category != models.LookupCategoryActor {
+		t.Errorf("category = %q after a rejected edit, want it unchanged", saved.Category)
+	}
+}
+
+func TestFormatCitation(t *testing.T) {
+	inception := &models.Movie{Title: "Inception", Year: 2010}
+	tests := []struct {
+		movie  *models.Movie
+		name   string
+		want   string
+		lookup models.Lookup
+	}{
+		{
+			name:   "with URL",
+			lookup: models.Lookup{Answer: "Hans Zimmer composed the score.", URL: "https://en.wikipedia.org/wiki/Inception"},
+			movie:  inception,
+			want:   `"Hans Zimmer composed the score." (Inception, 2010) — https://en.wikipedia.org/wiki/Inception`,
+		},
+		{
+			name:   "without URL",
+			lookup: models.Lookup{Answer: "Hans Zimmer composed the score."},
+			movie:  inception,
+			want:   `"Hans Zimmer composed the score." (Inception, 2010)`,
+		},
+		{
+			name:   "unanswered",
+			lookup: models.Lookup{Question: "Who composed the score?"},
+			movie:  inception,
+			want:   `"Who composed the score?" (Inception, 2010)`,
+		},
+		{
+			name:   "year unknown",
+			lookup: models.Lookup{Answer: "Shot in secret."},
+			movie:  &models.Movie{Title: "Untitled Project"},
+			want:   `"Shot in secret." (Untitled Project)`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatCitation(&tt.lookup, tt.movie); got != tt.want {
+				t.Errorf("formatCitation() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCiteLookup(t *testing.T) {
+	h, db := newTestHandlers(t)
+	entryID := addTestEntry(t, db, 27205, "Inception")
+	lookup, err := db.CreateLookup(context.Background(), models.LookupInput{
+		DiaryEntryID: entryID, Question: "Who composed the score?", Answer: "Hans Zimmer composed the score.",
+		Category: models.LookupCategoryTrivia, URL: "https://en.wikipedia.org/wiki/Inception",
+	})
+	if err != nil {
+		t.Fatalf("creating lookup: %v", err)
+	}
+	id := strconv.FormatInt(lookup.ID, 10)
+
+	r := httptest.NewRequest(http.MethodGet, "/lookups/"+id+"/cite", nil)
+	r.SetPathValue("id", id)
+	w := httptest.NewRecorder()
+	h.CiteLookup(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if got := w.Header().Get("Content-Type"); got != "text/plain; charset=utf-8" {
+		t.Errorf("Content-Type = %q, want text/plain", got)
+	}
+	// The test helper saves every movie as released in 2021
+	want := `"Hans Zimmer composed the score." (Inception, 2021) — https://en.wikipedia.org/wiki/Inception` + "\n"
+	if w.Body.String() != want {
+		t.Errorf("citation = %q, want %q", w.Body.String(), want)
+	}
+}
+
+func TestSearchEntryLookups(t *testing.T) {
+	h, db := newTestHandlers(t)
+	ctx := context.Background()
+	entryID := addTestEntry(t, db, 438631, "Dune")
+	_, err := db.CreateLookups(ctx, entryID, []models.LookupInput{
+		{Question: "Who composed the score?", Answer: "Hans Zimmer", Category: models.LookupCategoryTrivia},
+		{Question: "Who plays Chani?", Answer: "Zendaya", Category: models.LookupCategoryActor},
+		{Question: "Where was it filmed?", Answer: "Wadi Rum, Jordan", Category: models.LookupCategoryLocation},
+	})
+	if err != nil {
+		t.Fatalf("creating lookups: %v", err)
+	}
+	// Another entry's lookups never match
+	if _, err := db.CreateLookups(ctx, addTestEntry(t, db, 693134, "Dune: Part Two"), []models.LookupInput{
+		{Question: "Who composed the score?", Answer: "Hans Zimmer again", Category: models.LookupCategoryTrivia},
+	}); err != nil {
+		t.Fatalf("creating lookups: %v", err)
+	}
+	id := strconv.FormatInt(entryID, 10)
+	answers := []string{"Hans Zimmer", "Zendaya", "Wadi Rum, Jordan"}
+
+	tests := []struct {
+		name      string
+		query     string
+		want      []string
+		wantEmpty bool
+	}{
+		{name: "empty query", query: "", want: answers},
+		{name: "blank query", query: "   ", want: answers},
+		{name: "answer, ignoring case", query: "ZIMMER", want: []string{"Hans Zimmer"}},
+		{name: "question", query: "chani", want: []string{"Zendaya"}},
+		{name: "several matches", query: "who", want: []string{"Hans Zimmer", "Zendaya"}},
+		{name: "no match", query: "spice", wantEmpty: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/diary/"+id+"/lookups?q="+url.QueryEscape(tt.query), nil)
+			r.Header.Set("HX-Request", "true")
+			r.SetPathValue("id", id)
+			w := httptest.NewRecorder()
+
+			h.SearchEntryLookups(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+			body := w.Body.String()
+			for _, answer := range answers {
+				want := slices.Contains(tt.want, answer)
+				if got := strings.Contains(body, ">"+answer+"<"); got != want {
+					t.Errorf("lists %q: %t, want %t", answer, got, want)
+				}
+			}
+			if strings.Contains(body, "Hans Zimmer again") {
+				t.Error("lists another entry's lookup")
+			}
+			if got := strings.Contains(body, "No research moments match"); got != tt.wantEmpty {
+				t.Errorf("shows the no-match message: %t, want %t:\n%s", got, tt.wantEmpty, body)
+			}
+		})
+	}
+}
+
+func TestSearchEntryLookupsMissingEntry(t *testing.T) {
+	h, _ := newTestHandlers(t)
+
+	r := httptest.NewRequest(http.MethodGet, "/diary/999/lookups?q=score", nil)
+	r.Header.Set("HX-Request", "true")
+	r.SetPathValue("id", "999")
+	w := httptest.NewRecorder()
+	h.SearchEntryLookups(w, r)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestSearchEntryLookupsUnanswered(t *testing.T) {
+	h, db := newTestHandlers(t)
+	entryID := addTestEntry(t, db, 438631, "Dune")
+	id := strconv.FormatInt(entryID, 10)
+
+	get := func() string {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodGet, "/diary/"+id+"/lookups?unanswered=1", nil)
+		r.Header.Set("HX-Request", "true")
+		r.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		h.SearchEntryLookups(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+		}
+		return w.Body.String()
+	}
+
+	if _, err := db.CreateLookups(context.Background(), entryID, []models.LookupInput{
+		{Question: "Where was Arrakis filmed?", Answer: "Wadi Rum"},
+	}); err != nil {
+		t.Fatalf("creating lookups: %v", err)
+	}
+	if body := get(); !strings.Contains(body, "Every question has an answer.") || strings.Contains(body, "Arrakis") {
+		t.Errorf("with every question answered, list = %s", body)
+	}
+
+	if _, err := db.CreateLookups(context.Background(), entryID, []models.LookupInput{
+		{Question: "Who plays Chani?"},
+	}); err != nil {
+		t.Fatalf("creating lookups: %v", err)
+	}
+	body := get()
+	if !strings.Contains(body, "Who plays Chani?") || !strings.Contains(body, "Not answered yet") {
+		t.Errorf("list doesn't show the unanswered question:\n%s", body)
+	}
+	if strings.Contains(body, "Arrakis") {
+		t.Errorf("list shows an answered question:\n%s", body)
+	}
+}
+
+func TestReorderEntryLookups(t *testing.T) {
+	h, db := newTestHandlers(t)
+	entryID := addTestEntry(t, db, 438631, "Dune")
+	lookups, err := db.CreateLookups(context.Background(), entryID, []models.LookupInput{
+		{Question: "Who plays Paul?", Answer: "Timothée Chalamet"},
+		{Question: "Where was Arrakis filmed?", Answer: "Wadi Rum"},
+	})
+	if err != nil {
+		t.Fatalf("creating lookups: %v", err)
+	}
+	foreign, err := db.CreateLookups(context.Background(), addTestEntry(t, db, 949, "Heat"), []models.LookupInput{
+		{Question: "Where is the diner?", Answer: "Kate Mantilini"},
+	})
+	if err != nil {
+		t.Fatalf("creating lookups: %v", err)
+	}
+	id := strconv.FormatInt(entryID, 10)
+	a, b := strconv.FormatInt(lookups[0].ID, 10), strconv.FormatInt(lookups[1].ID, 10)
+
+	tests := []struct {
+		name       string
+		id         string
+		order      []string
+		wantStatus int
+	}{
+		{name: "foreign lookup", id: id, order: []string{b, strconv.FormatInt(foreign[0].ID, 10)}, wantStatus: http.StatusBadRequest},
+		{name: "not a number", id: id, order: []string{b, "first"}, wantStatus: http.StatusBadRequest},
+		{name: "unknown entry", id: "999", order: []string{b, a}, wantStatus: http.StatusNotFound},
+		{name: "new order", id: id, order: []string{b, a}, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := "/diary/" + tt.id + "/lookups/reorder"
+			w := submitLookupForm(h.ReorderEntryLookups, http.MethodPost, path, tt.id, url.Values{"lookup": tt.order})
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d:\n%s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			// The list comes back in the new order
+			body := w.Body.String()
+			if arrakis, paul := strings.Index(body, "Wadi Rum"), strings.Index(body, "Timothée Chalamet"); arrakis < 0 || paul < arrakis {
+				t.Errorf("list isn't in the new order:\n%s", body)
+			}
+		})
+	}
+
+	saved, err := db.SearchLookups(context.Background(), entryID, "")
+	if err != nil {
+		t.Fatalf("listing lookups: %v", err)
+	}
+	if len(saved) != 2 || saved[0].ID != lookups[1].ID || saved[1].ID != lookups[0].ID {
+		t.Errorf("saved order = %+v, want Arrakis first", saved)
+	}
+}
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pavelanni/movie-journal/internal/database"
+	"github.com/pavelanni/movie-journal/internal/models"
+	"github.com/pavelanni/movie-journal/templates"
+)
+
+const (
+	// minSearchLength is the shortest query worth searching for.
+	minSearchLength = 2
+	// minLocalResults is how many library matches make a TMDB search unnecessary.
+	minLocalResults = 3
+	// maxSearchResults caps the number of suggestions returned.
+	maxSearchResults = 10
+)
+
+// SearchMovies suggests movies for the title field, checking the local library
+// before falling through to TMDB (HTML fragment for HTMX).
+func (h *Handlers) SearchMovies(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("movie_title"))
+	if len([]rune(query)) < minSearchLength {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	local, err := h.db.SearchMovies(r.Context(), query, maxSearchResults)
+	if err != nil {
+		slog.Error("Failed to search movies", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to search movies")
+		return
+	}
+
+	results := make([]models.MovieSearchResult, 0, maxSearchResults)
+	seen := make(map[int]bool, len(local))
+	for i := range local {
+		results = append(results, models.MovieSearchResult{Movie: local[i], InLibrary: true})
+		seen[local[i].TMDBID] = true
+	}
+
+	if len(local) < minLocalResults && h.tmdb != nil {
+		remote, err := h.tmdb.SearchMovies(r.Context(), query)
+		if err != nil {
+			// Local results are still useful, so degrade instead of failing
+			slog.Warn("TMDB search failed", slog.String("error", err.Error()))
+		}
+		for i := range remote {
+			if len(results) == maxSearchResults {
+				break
+			}
+			if seen[remote[i].TMDBID] {
+				continue
+			}
+			results = append(results, models.MovieSearchResult{Movie: remote[i]})
+			seen[remote[i].TMDBID] = true
+		}
+	}
+
+	err = templates.MovieSearchResults(results).Render(r.Context(), w)
+	if err != nil {
+		errorPage(w, r, http.StatusInternalServerError, "Failed to render template")
+		return
+	}
+}
+
+// MovieDetail renders a movie with all of its viewings and the lookups gathered across them.
+func (h *Handlers) MovieDetail(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		errorPage(w, r, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+
+	movie, err := h.db.GetMovie(r.Context(), id)
+	if errors.Is(err, database.ErrNotFound) {
+		errorPage(w, r, http.StatusNotFound, "Movie not found")
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to get movie", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to load movie")
+		return
+	}
+
+	h.fillIMDbID(r.Context(), movie)
+	h.fillMovieFacts(r.Context(), movie)
+
+	viewings, err := h.db.ListViewings(r.Context(), id)
+	if err != nil {
+		slog.Error("Failed to list viewings", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to load movie")
+		return
+	}
+
+	trivia, err := h.db.ListLookupsByMovie(r.Context(), id)
+	if err != nil {
+		slog.Error("Failed to list movie lookups", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to load movie")
+		return
+	}
+
+	err = templates.MoviePage(*movie, viewings, trivia).Render(r.Context(), w)
+	if err != nil {
+		errorPage(w, r, http.StatusInternalServerError, "Failed to render template")
+		return
+	}
+}
+
+// prefetchMovie fetches the TMDB details a movie's page shows, its IMDb ID, tagline and
+// keywords, as a background job, so the page needn't wait for TMDB on its first visit.
+func (h *Handlers) prefetchMovie(ctx context.Context, movie models.Movie) {
+	if h.tmdb == nil {
+		return
+	}
+	h.goJob(ctx, "prefetch movie details", func(ctx context.Context) {
+		h.fillIMDbID(ctx, &movie)
+		h.fillMovieFacts(ctx, &movie)
+	})
+}
+
+// fillIMDbID looks up the IMDb ID of a movie saved without one and stores it, so its
+// external links show up. Failures are logged and leave the movie unchanged.
+func (h *Handlers) fillIMDbID(ctx context.Context, movie *models.Movie) {
+	if movie.IMDbID != "" || h.tmdb == nil {
+		return
+	}
+
+	ids, err := h.tmdb.GetExternalIDs(ctx, movie.TMDBID)
+	if err != nil {
+		slog.Warn("TMDB external IDs lookup failed", slog.String("error", err.Error()))
+		return
+	}
+	if ids.IMDbID == "" {
+		return
+	}
+	if err := h.db.SetMovieIMDbID(ctx, movie.ID, ids.IMDbID); err != nil {
+		slog.Error("Failed to save IMDb ID", slog.String("error", err.Error()))
+		return
+	}
+	movie.IMDbID = ids.IMDbID
+}
+
+// fillMovieFacts adds the movie's TMDB tagline and keywords, fetching and caching them
+// on the first visit. Failures are logged and leave the movie without them; they're
+// fetched again on the next visit.
+func (h *Handlers) fillMovieFacts(ctx context.Context, movie *models.Movie) {
+	facts, err := h.db.MovieFacts(ctx, movie.ID)
+	if errors.Is(err, database.ErrNotFound) && h.tmdb != nil {
+		facts, err = h.fetchMovieFacts(ctx, movie)
+	}
+	if errors.Is(err, database.ErrNotFound) {
+		return
+	}
+	if err != nil {
+		slog.Warn("Failed to load movie facts", slog.String("error", err.Error()))
+		return
+	}
+	movie.Tagline = facts.Tagline
+	movie.Keywords = facts.Keywords
+}
+
+// fetchMovieFacts gets a movie's tagline and keywords from TMDB and caches them.
+func (h *Handlers) fetchMovieFacts(ctx context.Context, movie *models.Movie) (*models.MovieFacts, error) {
+	details, err := h.tmdb.GetMovie(ctx, movie.TMDBID)
+	if err != nil {
+		return nil, fmt.Errorf("getting TMDB movie: %w", err)
+	}
+	keywords, err := h.tmdb.GetKeywords(ctx, movie.TMDBID)
+	if err != nil {
+		return nil, fmt.Errorf("getting TMDB keywords: %w", err)
+	}
+
+	facts := &models.MovieFacts{Tagline: details.Tagline, Keywords: keywords}
+	if err := h.db.SetMovieFacts(ctx, movie.ID, *facts); err != nil {
+		return nil, err
+	}
+	return facts, nil
+}
+
+// curiousFilmsLimit is the number of films shown on the most curious films page.
+const curiousFilmsLimit = 20
+
+// CuriousFilms ranks the films that prompted the most lookups.
+func (h *Handlers) CuriousFilms(w http.ResponseWriter, r *http.Request) {
+	ranking, err := h.db.MostLookedUpFilms(r.Context(), curiousFilmsLimit)
+	if err != nil {
+		slog.Error("Failed to rank films by lookups", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to load films")
+		return
+	}
+
+	err = templates.CuriousFilms(ranking).Render(r.Context(), w)
+	if err != nil {
+		errorPage(w, r, http.StatusInternalServerError, "Failed to render template")
+		return
+	}
+}
+
+// maxDiscoverPage is the last page of a list TMDB will return.
+const maxDiscoverPage = 500
+
+// Discover lists the movies currently popular on TMDB, a page at a time, each with a
+// link to log it. Without a TMDB API key it explains that discovery is unavailable.
+func (h *Handlers) Discover(w http.ResponseWriter, r *http.Request) {
+	if h.tmdb == nil {
+		if err := templates.DiscoverUnavailable().Render(r.Context(), w); err != nil {
+			errorPage(w, r, http.StatusInternalServerError, "Failed to render template")
+		}
+		return
+	}
+
+	page, _ := parsePagination(r)
+	page = min(page, maxDiscoverPage)
+
+	popular, err := h.tmdb.Popular(r.Context(), page)
+	if err != nil {
+		slog.Error("Failed to load popular movies", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusBadGateway, "Couldn't load popular movies from TMDB")
+		return
+	}
+
+	var prevURL, nextURL string
+	if page > 1 {
+		prevURL = fmt.Sprintf("/discover?page=%d", page-1)
+	}
+	if page < min(popular.TotalPages, maxDiscoverPage) {
+		nextURL = fmt.Sprintf("/discover?page=%d", page+1)
+	}
+
+	err = templates.Discover(popular.Movies, prevURL, nextURL).Render(r.Context(), w)
+	if err != nil {
+		errorPage(w, r, http.StatusInternalServerError, "Failed to render template")
+		return
+	}
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pavelanni/movie-journal/internal/tmdb"
+)
+
+// searchMovies runs a movie search for the title and returns the rendered options.
+func searchMovies(h *Handlers, title string) string {
+	r := httptest.NewRequest(http.MethodGet, "/movies/search?movie_title="+title, nil)
+	w := httptest.NewRecorder()
+	h.SearchMovies(w, r)
+	return w.Body.String()
+}
+
+func TestSearchMoviesCombinesLibraryAndTMDB(t *testing.T) {
+	h, db := newTestHandlers(t)
+	addTestEntry(t, db, 438631, "Dune")
+	var searches atomic.Int32
+	useTMDB(h, fakeTMDB(t, `{"results":[
+		{"id":438631,"title":"Dune","release_date":"2021-09-15"},
+		{"id":693134,"title":"Dune: Part Two","release_date":"2024-02-27"}
+	]}`, &searches))
+
+	body := searchMovies(h, "dune")
+
+	if searches.Load() != 1 {
+		t.Errorf("searched TMDB %d times, want 1", searches.Load())
+	}
+	if !strings.Contains(body, `label="Dune (2021) · in your library"`) {
+		t.Errorf("the library match isn't labeled as such:\n%s", body)
+	}
+	if !strings.Contains(body, `label="Dune: Part Two (2024) · from TMDB"`) {
+		t.Errorf("the TMDB-only result isn't labeled as such:\n%s", body)
+	}
+	if n := strings.Count(body, `value="Dune"`); n != 1 {
+		t.Errorf("Dune is suggested %d times, want once:\n%s", n, body)
+	}
+}
+
+func TestSearchMoviesSkipsTMDBWithEnoughLocalMatches(t *testing.T) {
+	h, db := newTestHandlers(t)
+	for i, title := range []string{"Alien", "Aliens", "Alien 3"} {
+		addTestEntry(t, db, i+1, title)
+	}
+	var searches atomic.Int32
+	useTMDB(h, fakeTMDB(t, `{"results":[{"id":99,"title":"Alien: Romulus","release_date":"2024-08-13"}]}`, &searches))
+
+	body := searchMovies(h, "alien")
+
+	if searches.Load() != 0 {
+		t.Errorf("searched TMDB %d times with %d library matches, want 0", searches.Load(), minLocalResults)
+	}
+	if strings.Contains(body, "from TMDB") {
+		t.Errorf("results include TMDB suggestions:\n%s", body)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	h, _ := newTestHandlers(t)
+	var requestedPage atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/movie/popular" {
+			http.NotFound(w, r)
+			return
+		}
+		requestedPage.Store(r.URL.Query().Get("page"))
+		_, _ = w.Write([]byte(`{"page":1,"total_pages":3,"results":[
+			{"id":693134,"title":"Dune: Part Two","release_date":"2024-02-27","poster_path":"/dune2.jpg"},
+			{"id":1,"title":"Untitled Project","release_date":""}
+		]}`))
+	}))
+	t.Cleanup(server.Close)
+	useTMDB(h, tmdb.NewClient("test-key", tmdb.WithBaseURL(server.URL), tmdb.WithRetry(1, 0)))
+
+	tests := []struct {
+		target   string
+		wantPage string
+		wantPrev string
+		wantNext string
+	}{
+		{target: "/discover", wantPage: "1", wantNext: "/discover?page=2"},
+		{target: "/discover?page=2", wantPage: "2", wantPrev: "/discover?page=1", wantNext: "/discover?page=3"},
+		{target: "/discover?page=3", wantPage: "3", wantPrev: "/discover?page=2"},
+		{target: "/discover?page=nope", wantPage: "1", wantNext: "/discover?page=2"},
+		{target: "/discover?page=100000", wantPage: "500", wantPrev: "/discover?page=499"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.Discover(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+			if got := requestedPage.Load(); got != tt.wantPage {
+				t.Errorf("requested TMDB page %v, want %s", got, tt.wantPage)
+			}
+			body := w.Body.String()
+			for _, want := range []string{
+				"Dune: Part Two", "https://image.tmdb.org/t/p/w185/dune2.jpg",
+				`href="/diary/new?movie_title=Dune%3A+Part+Two"`, "Untitled Project", "Year unknown",
+			} {
+				if !strings.Contains(body, want) {
+					t.Errorf("page doesn't contain %s", want)
+				}
+			}
+			var want []string
+			for _, link := range []string{tt.wantPrev, tt.wantNext} {
+				if link != "" {
+					want = append(want, link)
+				}
+			}
+			if links := pageLinks(body); !slices.Equal(links, want) {
+				t.Errorf("page links = %v, want %v", links, want)
+			}
+		})
+	}
+}
+
+func TestDiscoverWithoutTMDB(t *testing.T) {
+	h, _ := newTestHandlers(t)
+
+	w := httptest.NewRecorder()
+	h.Discover(w, httptest.NewRequest(http.MethodGet, "/discover", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if !strings.Contains(w.Body.String(), "needs a TMDB API key") {
+		t.Errorf("page doesn't explain that discovery needs an API key:\n%s", w.Body)
+	}
+}
+
+func TestDiscoverTMDBFailure(t *testing.T) {
+	h, _ := newTestHandlers(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(server.Close)
+	useTMDB(h, tmdb.NewClient("test-key", tmdb.WithBaseURL(server.URL), tmdb.WithRetry(1, 0)))
+
+	w := httptest.NewRecorder()
+	h.Discover(w, httptest.NewRequest(http.MethodGet, "/discover", nil))
+
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadGateway)
+	}
+}
+
+// fakeMovieFactsTMDB serves the tagline and keywords of Dune, counting the requests for
+// its details.
+func fakeMovieFactsTMDB(t *testing.T, tagline string, fetches *atomic.Int32) *tmdb.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/movie/438631":
+			fetches.Add(1)
+			_, _ = fmt.Fprintf(w, `{"id":438631,"tagline":%q}`, tagline)
+		case "/movie/438631/keywords":
+			_, _ = w.Write([]byte(`{"id":438631,"keywords":[{"id":1,"name":"desert"},{"id":2,"name":"prophecy"}]}`))
+		case "/movie/438631/external_ids":
+			_, _ = w.Write([]byte(`{"id":438631,"imdb_id":"tt1160419"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return tmdb.NewClient("test-key", tmdb.WithBaseURL(server.URL), tmdb.WithRetry(1, 0))
+}
+
+// getMoviePage renders the page of the movie with id.
+func getMoviePage(h *Handlers, id string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/movies/"+id, nil)
+	r.SetPathValue("id", id)
+	w := httptest.NewRecorder()
+	h.MovieDetail(w, r)
+	return w
+}
+
+func TestMovieDetailFacts(t *testing.T) {
+	tests := []struct {
+		name        string
+		tagline     string
+		wantTagline bool
+	}{
+		{name: "with tagline", tagline: "Beyond fear, destiny awaits.", wantTagline: true},
+		{name: "without tagline", tagline: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, db := newTestHandlers(t)
+			var fetches atomic.Int32
+			useTMDB(h, fakeMovieFactsTMDB(t, tt.tagline, &fetches))
+			entry, err := db.GetDiaryEntry(context.Background(), addTestEntry(t, db, 438631, "Dune"))
+			if err != nil {
+				t.Fatalf("getting entry: %v", err)
+			}
+			id := strconv.FormatInt(entry.MovieID, 10)
+
+			// The second visit uses the cached facts
+			for range 2 {
+				w := getMoviePage(h, id)
+				if w.Code != http.StatusOK {
+					t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+				}
+				body := w.Body.String()
+				for _, keyword := range []string{">desert<", ">prophecy<"} {
+					if !strings.Contains(body, keyword) {
+						t.Errorf("page doesn't show the keyword %s", keyword)
+					}
+				}
+				if got := strings.Contains(body, `<p class="text-gray-600 italic">`); got != tt.wantTagline {
+					t.Errorf("shows a tagline: %t, want %t", got, tt.wantTagline)
+				}
+				if tt.wantTagline && !strings.Contains(body, tt.tagline) {
+					t.Errorf("page doesn't show the tagline %q", tt.tagline)
+				}
+			}
+			if n := fetches.Load(); n != 1 {
+				t.Errorf("fetched the movie from TMDB %d times, want 1", n)
+			}
+		})
+	}
+}
+
+func TestMovieDetailFactsUnavailable(t *testing.T) {
+	h, db := newTestHandlers(t)
+	entry, err := db.GetDiaryEntry(context.Background(), addTestEntry(t, db, 438631, "Dune"))
+	if err != nil {
+		t.Fatalf("getting entry: %v", err)
+	}
+
+	// Without TMDB, the page leaves out the facts rather than failing
+	w := getMoviePage(h, strconv.FormatInt(entry.MovieID, 10))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if strings.Contains(w.Body.String(), "bg-gray-100 rounded-full") {
+		t.Errorf("page shows keyword chips for a movie without facts:\n%s", w.Body)
+	}
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pavelanni/movie-journal/internal/models"
+)
+
+func TestPrefersJSON(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{accept: "", want: false},
+		{accept: "application/json", want: true},
+		{accept: "text/html", want: false},
+		{accept: "*/*", want: false},
+		{accept: "application/*", want: true},
+		{accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", want: false},
+		{accept: "application/json, text/html", want: false},
+		{accept: "application/json;q=0.9, text/html;q=0.8", want: true},
+		{accept: "text/html;q=0.5, application/json", want: true},
+		{accept: "application/json, */*;q=0.1", want: true},
+		{accept: "application/json;q=0", want: false},
+		{accept: "application/json;q=bogus", want: true},
+		{accept: "garbage;;;", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/entry/1", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			if got := prefersJSON(r); got != tt.want {
+				t.Errorf("prefersJSON(%q) = %t, want %t", tt.accept, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAcceptQuality(t *testing.T) {
+	tests := []struct {
+		accept    string
+		mediaType string
+		want      float64
+	}{
+		{accept: "application/json", mediaType: "application/json", want: 1},
+		{accept: "application/json;q=0.4", mediaType: "application/json", want: 0.4},
+		{accept: "text/html", mediaType: "application/json", want: 0},
+		{accept: "*/*;q=0.2", mediaType: "application/json", want: 0.2},
+		{accept: "application/*;q=0.6", mediaType: "application/json", want: 0.6},
+		// The most specific range wins, whatever its order or q-value
+		{accept: "application/json;q=0.3, application/*;q=0.9, */*", mediaType: "application/json", want: 0.3},
+		{accept: "*/*;q=0.1, text/*;q=0.7", mediaType: "text/html", want: 0.7},
+		{accept: "TEXT/HTML;q=0.5", mediaType: "text/html", want: 0.5},
+		{accept: "text/html;q=nope", mediaType: "text/html", want: 1},
+		{accept: "", mediaType: "text/html", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept+" "+tt.mediaType, func(t *testing.T) {
+			if got := acceptQuality(tt.accept, tt.mediaType); got != tt.want {
+				t.Errorf("acceptQuality(%q, %q) = %v, want %v", tt.accept, tt.mediaType, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetEntryNegotiates(t *testing.T) {
+	h, db := newTestHandlers(t)
+	id := addTestEntry(t, db, 438631, "Dune")
+
+	tests := []struct {
+		accept   string
+		wantType string
+	}{
+		{accept: "application/json", wantType: "application/json"},
+		{accept: "text/html", wantType: "text/html"},
+		{accept: "*/*", wantType: "text/html"},
+		{accept: "", wantType: "text/html"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/entry/"+strconv.FormatInt(id, 10), nil)
+			r.SetPathValue("id", strconv.FormatInt(id, 10))
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+
+			h.GetEntry(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+			if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, tt.wantType) {
+				t.Errorf("Content-Type = %q, want %s", got, tt.wantType)
+			}
+			if w.Header().Get("Vary") != "Accept" {
+				t.Errorf("Vary = %q, want Accept", w.Header().Get("Vary"))
+			}
+			if tt.wantType != "application/json" {
+				return
+			}
+			var entry models.DiaryEntry
+			if err := json.NewDecoder(w.Body).Decode(&entry); err != nil {
+				t.Fatalf("decoding entry: %v", err)
+			}
+			if entry.ID != id || entry.Movie == nil || entry.Movie.Title != "Dune" {
+				t.Errorf("entry = %+v, want entry %d for Dune", entry, id)
+			}
+		})
+	}
+}
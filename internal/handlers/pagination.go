@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/pavelanni/movie-journal/internal/models"
+)
+
+// parsePagination reads the page number and page size from the query. The page defaults
+// to 1 and the page size to 0, meaning none was requested and the caller's default applies.
+// Non-numeric values are ignored; numeric ones are clamped to valid ranges.
+func parsePagination(r *http.Request) (page, perPage int) {
+	query := r.URL.Query()
+
+	page = 1
+	if n, err := strconv.Atoi(query.Get("page")); err == nil {
+		page = max(n, 1)
+	}
+
+	return page, parsePerPage(query.Get("per_page"))
+}
+
+// parsePerPage parses a page size, clamping it to [1, maxPerPage]. It returns 0 when the
+// value is missing or not a number.
+func parsePerPage(value string) int {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0
+	}
+	return min(max(n, 1), maxPerPage)
+}
+
+// pageBaseURL returns the recent entries URL for the filter in effect and a page size other
+// than the default, for linking the other pages of the same list.
+func (h *Handlers) pageBaseURL(filter models.EntryFilter, perPage int) string {
+	query := filter.Query()
+	if perPage != h.perPage {
+		query.Set("per_page", strconv.Itoa(perPage))
+	}
+	if len(query) == 0 {
+		return "/recent-entries"
+	}
+	return "/recent-entries?" + query.Encode()
+}
+
+// diaryURL returns the URL of the full diary listing the entries that match filter.
+func diaryURL(filter models.EntryFilter) string {
+	query := filter.Query()
+	if len(query) == 0 {
+		return "/diary"
+	}
+	return "/diary?" + query.Encode()
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// hrefPattern matches the link targets in rendered HTML.
+var hrefPattern = regexp.MustCompile(`href="([^"]*)"`)
+
+// pageLinks returns the targets of the links in body that go to another page of the list.
+func pageLinks(body string) []string {
+	var links []string
+	for _, m := range hrefPattern.FindAllStringSubmatch(body, -1) {
+		if link := html.UnescapeString(m[1]); strings.Contains(link, "page=") {
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+func TestDiaryPagesWithSavedPreferences(t *testing.T) {
+	h, db := newTestHandlers(t)
+	for i, title := range []string{"Heat", "Ronin", "Collateral"} {
+		addRatedEntry(t, db, i+1, title, 5)
+	}
+	for i, title := range []string{"Miami Vice", "Blackhat"} {
+		addRatedEntry(t, db, i+10, title, 2)
+	}
+	saved := &http.Cookie{Name: preferencesCookie, Value: "min_rating=4&per_page=2"}
+
+	get := func(handler http.HandlerFunc, target string) string {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.AddCookie(saved)
+		w := httptest.NewRecorder()
+		handler(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: status = %d, want %d", target, w.Code, http.StatusOK)
+		}
+		return w.Body.String()
+	}
+	// titles returns which of the movies body lists.
+	titles := func(body string) []string {
+		var found []string
+		for _, title := range []string{"Heat", "Ronin", "Collateral", "Miami Vice", "Blackhat"} {
+			if strings.Contains(body, ">"+title+"<") {
+				found = append(found, title)
+			}
+		}
+		return found
+	}
+
+	diary := get(h.Diary, "/diary")
+	if got := titles(diary); !slices.Equal(got, []string{"Ronin", "Collateral"}) {
+		t.Errorf("diary lists %v, want the 2 newest rated 4 or more", got)
+	}
+	wantNext := "/recent-entries?min_rating=4&page=2&per_page=2"
+	if links := pageLinks(diary); !slices.Contains(links, wantNext) {
+		t.Fatalf("diary links %v, want one to %s", links, wantNext)
+	}
+
+	// The linked page continues the same filtered list
+	next := get(h.GetRecentEntries, wantNext)
+	if got := titles(next); !slices.Equal(got, []string{"Heat"}) {
+		t.Errorf("page 2 lists %v, want the last entry rated 4 or more", got)
+	}
+	if links := pageLinks(next); slices.Contains(links, "/recent-entries?min_rating=4&page=3&per_page=2") {
+		t.Errorf("page 2 links to a third page: %v", links)
+	}
+
+	// Query parameters that aren't filters keep the saved ones
+	for _, target := range []string{"/diary?page=2", "/diary?private=1&page=2"} {
+		if got := titles(get(h.Diary, target)); !slices.Equal(got, []string{"Heat"}) {
+			t.Errorf("GET %s lists %v, want the last entry rated 4 or more", target, got)
+		}
+	}
+}
+
+func TestParsePagination(t *testing.T) {
+	tests := []struct {
+		query       string
+		wantPage    int
+		wantPerPage int
+	}{
+		{query: "", wantPage: 1, wantPerPage: 0},
+		{query: "page=3&per_page=25", wantPage: 3, wantPerPage: 25},
+		{query: "page=0&per_page=0", wantPage: 1, wantPerPage: 1},
+		{query: "page=-4&per_page=-10", wantPage: 1, wantPerPage: 1},
+		{query: "page=1000000&per_page=1000000", wantPage: 1000000, wantPerPage: maxPerPage},
+		{query: "page=99999999999999999999&per_page=99999999999999999999", wantPage: 1, wantPerPage: 0},
+		{query: "page=two&per_page=lots", wantPage: 1, wantPerPage: 0},
+		{query: "page=2.5&per_page=1e3", wantPage: 1, wantPerPage: 0},
+		{query: "page=%2B2&per_page=%2B5", wantPage: 2, wantPerPage: 5},
+		// A plus in a query string is a space, which isn't a number
+		{query: "page=+2&per_page=+5", wantPage: 1, wantPerPage: 0},
+		{query: "page=&per_page=", wantPage: 1, wantPerPage: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/recent-entries?"+tt.query, nil)
+			page, perPage := parsePagination(r)
+			if page != tt.wantPage || perPage != tt.wantPerPage {
+				t.Errorf("parsePagination(%q) = %d, %d, want %d, %d", tt.query, page, perPage, tt.wantPage, tt.wantPerPage)
+			}
+		})
+	}
+}
+
+func TestParsePerPage(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+	}{
+		{value: "", want: 0},
+		{value: "abc", want: 0},
+		{value: "10", want: 10},
+		{value: "1", want: 1},
+		{value: "0", want: 1},
+		{value: "-5", want: 1},
+		{value: "100", want: maxPerPage},
+		{value: "101", want: maxPerPage},
+		{value: "9223372036854775807", want: maxPerPage},
+		{value: " 10", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := parsePerPage(tt.value); got != tt.want {
+				t.Errorf("parsePerPage(%q) = %d, want %d", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/pavelanni/movie-journal/internal/models"
+)
+
+// preferencesCookie is the name of the cookie holding the user's default filters.
+const preferencesCookie = "mj_prefs"
+
+// preferencesMaxAge keeps saved preferences for a year.
+const preferencesMaxAge = 365 * 24 * 60 * 60
+
+// Sort orders for diary entry lists.
+const (
+	sortWatchedDate = models.SortWatchedDate
+	sortRating      = models.SortRating
+)
+
+// Layouts for diary entry lists.
+const (
+	viewGrid = "grid"
+	viewList = "list"
+)
+
+// maxPerPage caps the number of entries shown on a single page.
+const maxPerPage = 100
+
+// preferences holds the user's default diary list filters.
+// Empty values mean "no preference" and fall back to the built-in defaults.
+type preferences struct {
+	MinRating string
+	Sort      string
+	View      string
+	PerPage   int
+}
+
+// parsePreferences extracts preferences from query or form values,
+// dropping anything that doesn't validate.
+func parsePreferences(values url.Values) preferences {
+	var p preferences
+
+	if rating, err := strconv.Atoi(values.Get("min_rating")); err == nil && rating >= 1 && rating <= 5 {
+		p.MinRating = strconv.Itoa(rating)
+	}
+
+	switch s := values.Get("sort"); s {
+	case sortWatchedDate, sortRating:
+		p.Sort = s
+	}
+
+	switch v := values.Get("view"); v {
+	case viewGrid, viewList:
+		p.View = v
+	}
+
+	p.PerPage = parsePerPage(values.Get("per_page"))
+
+	return p
+}
+
+// mergePreferences returns p with the preferences present in values replaced. A value
+// that's present but empty or invalid clears that preference.
+func mergePreferences(p preferences, values url.Values) preferences {
+	parsed := parsePreferences(values)
+	if values.Has("min_rating") {
+		p.MinRating = parsed.MinRating
+	}
+	if values.Has("sort") {
+		p.Sort = parsed.Sort
+	}
+	if values.Has("view") {
+		p.View = parsed.View
+	}
+	if values.Has("per_page") {
+		p.PerPage = parsed.PerPage
+	}
+	return p
+}
+
+// encode returns the preferences as URL-encoded values.
+func (p preferences) encode() string {
+	values := url.Values{}
+	if p.MinRating != "" {
+		values.Set("min_rating", p.MinRating)
+	}
+	if p.Sort != "" {
+		values.Set("sort", p.Sort)
+	}
+	if p.View != "" {
+		values.Set("view", p.View)
+	}
+	if p.PerPage > 0 {
+		values.Set("per_page", strconv.Itoa(p.PerPage))
+	}
+	return values.Encode()
+}
+
+// loadPreferences reads the preferences cookie, returning empty preferences if it's missing or invalid.
+func loadPreferences(r *http.Request) preferences {
+	cookie, err := r.Cookie(preferencesCookie)
+	if err != nil {
+		return preferences{}
+	}
+	values, err := url.ParseQuery(cookie.Value)
+	if err != nil {
+		return preferences{}
+	}
+	return parsePreferences(values)
+}
+
+// savePreferences stores the preferences in a cookie.
+func savePreferences(w http.ResponseWriter, p preferences) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     preferencesCookie,
+		Value:    p.encode(),
+		Path:     "/",
+		MaxAge:   preferencesMaxAge,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// viewPreference returns the list layout requested in the query, saving it as the default,
+// or else the saved layout. It defaults to the grid.
+func viewPreference(w http.ResponseWriter, r *http.Request, saved *preferences) string {
+	if requested := parsePreferences(r.URL.Query()).View; requested != "" {
+		if requested != saved.View {
+			saved.View = requested
+			savePreferences(w, *saved)
+		}
+		return requested
+	}
+	if saved.View != "" {
+		return saved.View
+	}
+	return viewGrid
+}
+
+// sortPreference returns the sort order requested in the query, remembering it for later
+// requests, or else the remembered order. Empty means the default, by watched date.
+func sortPreference(w http.ResponseWriter, r *http.Request, saved *preferences) string {
+	if requested := parsePreferences(r.URL.Query()).Sort; requested != "" {
+		if requested != saved.Sort {
+			saved.Sort = requested
+			savePreferences(w, *saved)
+		}
+		return requested
+	}
+	return saved.Sort
+}
+
+// sortFilter returns the sort order to show as an active filter: none for the default.
+func sortFilter(sort string) string {
+	if sort == sortWatchedDate {
+		return ""
+	}
+	return sort
+}
+
+// SavePreferences stores the submitted list filters as the user's defaults. Preferences
+// not in the form, such as the remembered sort, are kept.
+func (h *Handlers) SavePreferences(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		errorPage(w, r, http.StatusBadRequest, "Failed to parse form")
+		return
+	}
+
+	savePreferences(w, mergePreferences(loadPreferences(r), r.PostForm))
+
+	if isHTMX(r) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
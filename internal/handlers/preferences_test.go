@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// preferencesFrom returns the preferences cookie set by the response, failing the test
+// if there's none.
+func preferencesFrom(t *testing.T, w *httptest.ResponseRecorder) *http.Cookie {
+	t.Helper()
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == preferencesCookie {
+			return cookie
+		}
+	}
+	t.Fatal("response doesn't set the preferences cookie")
+	return nil
+}
+
+// savePreferencesForm posts form to SavePreferences with the cookie, if any, and returns
+// the updated cookie.
+func savePreferencesForm(t *testing.T, h *Handlers, form url.Values, cookie *http.Cookie) *http.Cookie {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/preferences", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("HX-Request", "true")
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	h.SavePreferences(w, r)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+	return preferencesFrom(t, w)
+}
+
+func TestSavePreferencesKeepsSort(t *testing.T) {
+	h, db := newTestHandlers(t)
+	addRatedEntry(t, db, 1, "Heat", 5)
+
+	// Choosing a sort remembers it
+	r := httptest.NewRequest(http.MethodGet, "/diary?sort=rating", nil)
+	w := httptest.NewRecorder()
+	h.Diary(w, r)
+	cookie := preferencesFrom(t, w)
+
+	// Saving the default filter and layout doesn't forget it
+	cookie = savePreferencesForm(t, h, url.Values{"min_rating": {"4"}, "view": {"list"}}, cookie)
+	values, err := url.ParseQuery(cookie.Value)
+	if err != nil {
+		t.Fatalf("parsing cookie: %v", err)
+	}
+	want := url.Values{"min_rating": {"4"}, "sort": {"rating"}, "view": {"list"}}
+	if values.Encode() != want.Encode() {
+		t.Errorf("saved preferences = %q, want %q", values.Encode(), want.Encode())
+	}
+
+	// Reloading without a sort applies the remembered one
+	r = httptest.NewRequest(http.MethodGet, "/diary", nil)
+	r.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	h.Diary(w, r)
+	if body := w.Body.String(); !strings.Contains(body, "Sorted by rating") {
+		t.Errorf("reloaded diary isn't sorted by rating:\n%s", body)
+	}
+}
+
+func TestSavePreferencesClearsPostedEmptyValue(t *testing.T) {
+	h, _ := newTestHandlers(t)
+	saved := &http.Cookie{Name: preferencesCookie, Value: "min_rating=4&per_page=10"}
+
+	cookie := savePreferencesForm(t, h, url.Values{"min_rating": {""}, "view": {"grid"}}, saved)
+
+	if cookie.Value != "per_page=10&view=grid" {
+		t.Errorf("saved preferences = %q, want the minimum rating cleared and the page size kept", cookie.Value)
+	}
+}
+
+func TestSavedPreferencesApplyOnReload(t *testing.T) {
+	h, db := newTestHandlers(t)
+	low := addRatedEntry(t, db, 1, "Cats", 2)
+	high := addRatedEntry(t, db, 2, "Heat", 5)
+
+	cookie := savePreferencesForm(t, h, url.Values{"min_rating": {"4"}, "sort": {"rating"}, "per_page": {"10"}}, nil)
+	if cookie.Value != "min_rating=4&per_page=10&sort=rating" {
+		t.Errorf("saved preferences = %q", cookie.Value)
+	}
+
+	tests := []struct {
+		cookie  *http.Cookie
+		name    string
+		path    string
+		wantLow bool
+	}{
+		{name: "saved defaults", path: "/", cookie: cookie},
+		{name: "query overrides", path: "/?min_rating=1", cookie: cookie, wantLow: true},
+		{name: "no cookie", path: "/", wantLow: true},
+		{name: "tampered cookie", path: "/", cookie: &http.Cookie{Name: preferencesCookie, Value: "min_rating=%zz"}, wantLow: true},
+		{name: "out of range", path: "/", cookie: &http.Cookie{Name: preferencesCookie, Value: "min_rating=9"}, wantLow: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.cookie != nil {
+				r.AddCookie(tt.cookie)
+			}
+			w := httptest.NewRecorder()
+
+			h.Home(w, r)
+
+			body := w.Body.String()
+			if !strings.Contains(body, fmt.Sprintf(`id="entry-%d"`, high)) {
+				t.Errorf("the 5-star entry isn't listed")
+			}
+			if gotLow := strings.Contains(body, fmt.Sprintf(`id="entry-%d"`, low)); gotLow != tt.wantLow {
+				t.Errorf("2-star entry listed = %t, want %t", gotLow, tt.wantLow)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/pavelanni/movie-journal/templates"
+)
+
+const (
+	// rewatchMinRating is the rating a movie needs to be suggested for a rewatch.
+	rewatchMinRating = 5
+	// rewatchAfter is how long since the last viewing before a movie is suggested.
+	rewatchAfter = 365 * 24 * time.Hour
+	// maxRewatchCandidates caps the number of suggestions on the home page.
+	maxRewatchCandidates = 3
+)
+
+// RewatchCandidates suggests favorites that haven't been watched in a while (HTML fragment for HTMX).
+// It renders nothing when there's nothing to suggest.
+func (h *Handlers) RewatchCandidates(w http.ResponseWriter, r *http.Request) {
+	entries, err := h.db.RewatchCandidates(r.Context(), rewatchMinRating, rewatchAfter, maxRewatchCandidates)
+	if err != nil {
+		slog.Error("Failed to list rewatch candidates", slog.String("error", err.Error()))
+		errorPage(w, r, http.StatusInternalServerError, "Failed to load suggestions")
+		return
+	}
+
+	err = templates.RewatchCandidates(entries, time.Now()).Render(r.Context(), w)
+	if err != nil {
+		errorPage(w, r, http.StatusInternalServerError, "Failed to render template")
+		return
+	}
+}
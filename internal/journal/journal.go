@@ -0,0 +1,201 @@
+// Package journal creates diary entries from the values typed into an entry form,
+// validating them and resolving the movie, for both the web handlers and the command line.
+package journal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pavelanni/movie-journal/internal/database"
+	"github.com/pavelanni/movie-journal/internal/models"
+	"github.com/pavelanni/movie-journal/internal/tmdb"
+)
+
+// Service validates and saves diary entries.
+type Service struct {
+	db *database.DB
+	// tmdb is nil when no TMDB API key is configured.
+	tmdb *tmdb.Client
+	// maxNotesLength caps entry notes, in characters; zero means no limit.
+	maxNotesLength int
+}
+
+// Config holds the settings for a Service. Only DB is required.
+type Config struct {
+	DB *database.DB
+	// TMDB looks up movies not yet in the library; nil disables TMDB lookups.
+	TMDB *tmdb.Client
+	// MaxNotesLength caps entry notes, in characters; zero means no limit.
+	MaxNotesLength int
+}
+
+// New creates a new Service.
+func New(cfg Config) *Service {
+	return &Service{
+		db:             cfg.DB,
+		tmdb:           cfg.TMDB,
+		maxNotesLength: cfg.MaxNotesLength,
+	}
+}
+
+// ParseEntry reads the values of a diary entry form, resolving the movie title, and
+// release year if given, against the library, and with searchTMDB, against TMDB. The
+// movie is only resolved once the other fields are valid. A movie found on TMDB is set as
+// the input's NewMovie, to be saved along with the entry. Problems with individual fields
+// are returned as a *models.ValidationError. A missing watched date defaults to the date
+// of today, unless today is zero, in which case the date is required.
+func (s *Service) ParseEntry(
+	ctx context.Context, form url.Values, today time.Time, searchTMDB bool,
+) (models.DiaryEntryInput, *models.Movie, error) {
+	var input models.DiaryEntryInput
+	var verr models.ValidationError
+
+	watchedDate := form.Get("watched_date")
+	if watchedDate == "" && !today.IsZero() {
+		watchedDate = today.Format("2006-01-02")
+	}
+	watchedAt, err := time.Parse("2006-01-02", watchedDate)
+	if err != nil {
+		verr.Add("watched_date", "Enter the date you watched the movie")
+	}
+
+	var rating int
+	if ratingStr := form.Get("rating"); ratingStr != "" {
+		rating, err = strconv.Atoi(ratingStr)
+		if err != nil || rating < 1 || rating > 5 {
+			verr.Add("rating", "Rating must be between 1 and 5")
+		}
+	}
+
+	notes := form.Get("notes")
+	if s.maxNotesLength > 0 && utf8.RuneCountInString(notes) > s.maxNotesLength {
+		verr.Add("notes", fmt.Sprintf("Notes can be at most %d characters", s.maxNotesLength))
+	}
+
+	var year int
+	if yearStr := form.Get("movie_year"); yearStr != "" {
+		year, err = strconv.Atoi(yearStr)
+		if err != nil || year < 1 {
+			verr.Add("movie_year", "Enter the year the movie was released")
+		}
+	}
+
+	if err := verr.Err(); err != nil {
+		return input, nil, err
+	}
+
+	movie, err := s.resolveMovie(ctx, form.Get("movie_title"), year, searchTMDB)
+	if errors.Is(err, database.ErrNotFound) {
+		verr.Add("movie_title", "This movie isn't in your library yet; pick one from the suggestions")
+		return input, nil, verr.Err()
+	}
+	if err != nil {
+		return input, nil, fmt.Errorf("finding movie: %w", err)
+	}
+
+	input = models.DiaryEntryInput{
+		MovieID:     movie.ID,
+		WatchedAt:   watchedAt,
+		Location:    form.Get("watched_location"),
+		Format:      NormalizeFormat(form.Get("format")),
+		Rating:      rating,
+		Notes:       notes,
+		WatchedWith: form.Get("watched_with"),
+	}
+	if movie.ID == 0 {
+		input.NewMovie = movie
+	}
+	return input, movie, nil
+}
+
+// AddEntry creates a diary entry from the values of a new entry form, validated and with
+// the movie resolved by ParseEntry, and returns its ID along with the movie. Invalid
+// values are reported as a *models.ValidationError naming the form fields.
+func (s *Service) AddEntry(ctx context.Context, form url.Values, today time.Time) (int64, *models.Movie, error) {
+	input, movie, err := s.ParseEntry(ctx, form, today, true)
+	if err == nil {
+		var id int64
+		if id, err = s.db.CreateDiaryEntry(ctx, input); err == nil {
+			return id, movie, nil
+		}
+	}
+	if verr := FormErrors(err); verr != nil {
+		return 0, nil, verr
+	}
+	return 0, nil, err
+}
+
+// resolveMovie finds the movie with the given title in the library, released in year
+// unless year is zero. Failing that, with searchTMDB and if TMDB is configured, it returns
+// the best TMDB match, not yet saved: an exact title match if there is one, preferring one
+// from year, otherwise the top result. TMDB failures are logged and treated as no match,
+// so they surface as the usual "not in your library" form error.
+func (s *Service) resolveMovie(ctx context.Context, title string, year int, searchTMDB bool) (*models.Movie, error) {
+	movie, err := s.db.FindMovieByTitle(ctx, title, year)
+	if !errors.Is(err, database.ErrNotFound) || !searchTMDB || s.tmdb == nil || strings.TrimSpace(title) == "" {
+		return movie, err
+	}
+
+	results, tmdbErr := s.tmdb.SearchMovies(ctx, strings.TrimSpace(title))
+	if tmdbErr != nil {
+		slog.Warn("TMDB lookup for new entry failed", slog.String("error", tmdbErr.Error()))
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, err
+	}
+
+	// Rank 1 is an exact title match, rank 2 one from the requested year too
+	match, matchRank := results[0], 0
+	for i := range results {
+		if !strings.EqualFold(results[i].Title, strings.TrimSpace(title)) {
+			continue
+		}
+		rank := 1
+		if year != 0 && results[i].Year == year {
+			rank = 2
+		}
+		if rank > matchRank {
+			match, matchRank = results[i], rank
+		}
+	}
+
+	return &match, nil
+}
+
+// NormalizeFormat trims a watch format and collapses runs of spaces in it. Differences in
+// case are settled when the entry is saved, by reusing the spelling logged before.
+func NormalizeFormat(format string) string {
+	return strings.Join(strings.Fields(format), " ")
+}
+
+// formFields maps DiaryEntryInput field names to the new entry form's input names.
+var formFields = map[string]string{
+	"movie_id":   "movie_title",
+	"watched_at": "watched_date",
+}
+
+// FormErrors extracts the validation error from err, renaming its fields to match the
+// entry form. It returns nil if err isn't a validation error.
+func FormErrors(err error) *models.ValidationError {
+	var verr *models.ValidationError
+	if !errors.As(err, &verr) {
+		return nil
+	}
+
+	var renamed models.ValidationError
+	for field, message := range verr.Fields {
+		if formField, ok := formFields[field]; ok {
+			field = formField
+		}
+		renamed.Add(field, message)
+	}
+	return &renamed
+}
@@ -0,0 +1,168 @@
+package journal
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pavelanni/movie-journal/internal/database"
+	"github.com/pavelanni/movie-journal/internal/models"
+)
+
+// newTestService returns a Service on a fresh database with Dune in the library.
+func newTestService(t *testing.T, cfg Config) (*Service, *database.DB) {
+	t.Helper()
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if _, err := db.SaveMovie(context.Background(), models.Movie{TMDBID: 438631, Title: "Dune", Year: 2021}); err != nil {
+		t.Fatalf("saving movie: %v", err)
+	}
+	cfg.DB = db
+	return New(cfg), db
+}
+
+func TestAddEntry(t *testing.T) {
+	s, db := newTestService(t, Config{})
+	today := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+
+	form := url.Values{"movie_title": {"dune"}, "rating": {"4"}, "format": {"  IMAX   70mm "}}
+	id, movie, err := s.AddEntry(context.Background(), form, today)
+	if err != nil {
+		t.Fatalf("AddEntry: %v", err)
+	}
+	if movie.Title != "Dune" {
+		t.Errorf("movie = %q, want Dune", movie.Title)
+	}
+
+	entry, err := db.GetDiaryEntry(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetDiaryEntry(%d): %v", id, err)
+	}
+	if !entry.WatchedDate.Equal(today) || entry.Rating != 4 || entry.Format != "IMAX 70mm" {
+		t.Errorf("entry = %+v, want watched today, rated 4, in IMAX 70mm", entry)
+	}
+}
+
+func TestAddEntryInvalid(t *testing.T) {
+	tests := []struct {
+		form      url.Values
+		name      string
+		wantField string
+	}{
+		{name: "rating too high", form: url.Values{"movie_title": {"Dune"}, "rating": {"9"}}, wantField: "rating"},
+		{name: "bad date", form: url.Values{"movie_title": {"Dune"}, "watched_date": {"June 1st"}}, wantField: "watched_date"},
+		{name: "bad year", form: url.Values{"movie_title": {"Dune"}, "movie_year": {"-1"}}, wantField: "movie_year"},
+		{name: "notes too long", form: url.Values{"movie_title": {"Dune"}, "notes": {"Spice must flow"}}, wantField: "notes"},
+		{name: "unknown movie", form: url.Values{"movie_title": {"Dune Messiah"}}, wantField: "movie_title"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, db := newTestService(t, Config{MaxNotesLength: 10})
+
+			_, _, err := s.AddEntry(context.Background(), tt.form, time.Now())
+
+			var verr *models.ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("AddEntry = %v, want a validation error", err)
+			}
+			if _, ok := verr.Fields[tt.wantField]; !ok {
+				t.Errorf("errors = %v, want one for %s", verr.Fields, tt.wantField)
+			}
+			if count, err := db.CountDiaryEntries(context.Background()); err != nil || count != 0 {
+				t.Errorf("diary has %d entries (%v) after a rejected entry, want none", count, err)
+			}
+		})
+	}
+}
+
+func TestAddEntryNotesLengthCountsCharacters(t *testing.T) {
+	tests := []struct {
+		name    string
+		notes   string
+		wantErr bool
+	}{
+		{name: "at the limit", notes: "éééé"},
+		{name: "multi-byte at the limit", notes: "千と千尋"},
+		{name: "over the limit", notes: "ééééé", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestService(t, Config{MaxNotesLength: 4})
+
+			_, _, err := s.AddEntry(context.Background(), url.Values{"movie_title": {"Dune"}, "notes": {tt.notes}}, time.Now())
+
+			var verr *models.ValidationError
+			if gotErr := errors.As(err, &verr) && verr.Fields["notes"] != ""; gotErr != tt.wantErr {
+				t.Errorf("AddEntry with %d-byte notes = %v, want a notes error: %t", len(tt.notes), err, tt.wantErr)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("AddEntry: %v", err)
+			}
+		})
+	}
+}
+
+func TestFormErrorsRenamesFields(t *testing.T) {
+	var verr models.ValidationError
+	verr.Add("movie_id", "Pick a movie")
+	verr.Add("watched_at", "Enter a date")
+	verr.Add("rating", "Too high")
+
+	got := FormErrors(verr.Err())
+
+	want := map[string]string{"movie_title": "Pick a movie", "watched_date": "Enter a date", "rating": "Too high"}
+	if len(got.Fields) != len(want) {
+		t.Fatalf("fields = %v, want %v", got.Fields, want)
+	}
+	for field, message := range want {
+		if got.Fields[field] != message {
+			t.Errorf("fields[%q] = %q, want %q", field, got.Fields[field], message)
+		}
+	}
+	if FormErrors(errors.New("disk full")) != nil {
+		t.Error("FormErrors returned an error for a non-validation error")
+	}
+}
+
+func TestParseEntryWatchedDate(t *testing.T) {
+	today := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		today   time.Time
+		want    time.Time
+		name    string
+		date    string
+		wantErr bool
+	}{
+		{name: "empty defaults to today", today: today, want: today},
+		{name: "explicit date is kept", date: "2024-05-20", today: today, want: time.Date(2024, time.May, 20, 0, 0, 0, 0, time.UTC)},
+		{name: "empty without a today", wantErr: true},
+		{name: "unparseable", date: "June 1st", today: today, wantErr: true},
+		{name: "impossible date", date: "2024-02-30", today: today, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestService(t, Config{})
+			form := url.Values{"movie_title": {"Dune"}, "watched_date": {tt.date}}
+
+			input, _, err := s.ParseEntry(context.Background(), form, tt.today, false)
+
+			var verr *models.ValidationError
+			if gotErr := errors.As(err, &verr) && verr.Fields["watched_date"] != ""; gotErr != tt.wantErr {
+				t.Fatalf("ParseEntry(watched_date=%q) = %v, want a watched_date error: %t", tt.date, err, tt.wantErr)
+			}
+			if !tt.wantErr && !input.WatchedAt.Equal(tt.want) {
+				t.Errorf("WatchedAt = %v, want %v", input.WatchedAt, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+// Package linkcheck finds dead links, such as lookup URLs that have rotted over time.
+package linkcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// userAgent identifies the checker to the sites it visits.
+	userAgent = "movie-journal link checker (https://github.com/pavelanni/movie-journal)"
+	// defaultConcurrency is how many links are checked at once.
+	defaultConcurrency = 4
+	// defaultInterval spaces out the start of requests, to be polite to the sites checked.
+	defaultInterval = 250 * time.Millisecond
+	// defaultTimeout bounds each request, redirects included.
+	defaultTimeout = 10 * time.Second
+)
+
+// Result is the outcome of checking one link.
+type Result struct {
+	// Err is set when the request failed without a response, e.g. an unknown host.
+	Err    error
+	URL    string
+	Status int
+}
+
+// OK reports whether the link works: it answered with a success or redirect status.
+func (r Result) OK() bool {
+	return r.Err == nil && r.Status >= 200 && r.Status < 400
+}
+
+// Problem describes why a dead link failed, such as "404 Not Found".
+func (r Result) Problem() string {
+	if r.Err != nil {
+		return r.Err.Error()
+	}
+	return fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status))
+}
+
+// Checker checks links with HEAD requests.
+type Checker struct {
+	httpClient  *http.Client
+	concurrency int
+	interval    time.Duration
+}
+
+// Option configures a Checker.
+type Option func(*Checker)
+
+// WithTimeout sets how long each link gets to answer.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Checker) {
+		c.httpClient.Timeout = timeout
+	}
+}
+
+// WithConcurrency sets how many links are checked at once, at least one.
+func WithConcurrency(n int) Option {
+	return func(c *Checker) {
+		c.concurrency = max(n, 1)
+	}
+}
+
+// WithInterval sets the minimum time between the start of two requests. Zero doesn't
+// limit the rate.
+func WithInterval(interval time.Duration) Option {
+	return func(c *Checker) {
+		c.interval = max(interval, 0)
+	}
+}
+
+// New creates a Checker.
+func New(opts ...Option) *Checker {
+	c := &Checker{
+		httpClient:  &http.Client{Timeout: defaultTimeout},
+		concurrency: defaultConcurrency,
+		interval:    defaultInterval,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Check checks every link and returns the results in the order of urls. It stops early,
+// leaving the remaining results empty, if ctx is done.
+func (c *Checker) Check(ctx context.Context, urls []string) []Result {
+	results := make([]Result, len(urls))
+
+	// Workers take turns on the ticker, so requests start at most once per interval
+	var tick <-chan time.Time
+	if c.interval > 0 {
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(c.concurrency, len(urls)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = c.check(ctx, urls[i])
+			}
+		}()
+	}
+
+feed:
+	for i := range urls {
+		if tick != nil {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				break feed
+			}
+		}
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	return results
+}
+
+// check requests a single link. Servers that don't support HEAD are asked again with GET,
+// so they aren't reported as dead.
+func (c *Checker) check(ctx context.Context, link string) Result {
+	status, err := c.request(ctx, http.MethodHead, link)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+		status, err = c.request(ctx, http.MethodGet, link)
+	}
+	return Result{URL: link, Status: status, Err: err}
+}
+
+// request sends a request without reading the body and returns the response status.
+func (c *Checker) request(ctx context.Context, method, link string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, link, http.NoBody)
+	if err != nil {
+		return 0, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := c.httpClient.Do(req)
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		// The result already names the URL
+		return 0, urlErr.Err
+	}
+	if err != nil {
+		return 0, err
+	}
+	_ = resp.Body.Close()
+	return resp.StatusCode, nil
+}
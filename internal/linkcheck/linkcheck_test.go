@@ -0,0 +1,202 @@
+package linkcheck
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newSite serves links that work, links that are dead, and links that only answer GET.
+func newSite(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		if ua := r.Header.Get("User-Agent"); ua != userAgent {
+			t.Errorf("User-Agent = %q, want %q", ua, userAgent)
+		}
+		if r.Method != http.MethodHead {
+			t.Errorf("method = %s, want HEAD", r.Method)
+		}
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/broken", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	mux.HandleFunc("/get-only", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/get-only-gone", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusNotImplemented)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCheck(t *testing.T) {
+	site := newSite(t)
+	// A server that has shut down refuses connections
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		url        string
+		wantStatus int
+		wantOK     bool
+		wantErr    bool
+	}{
+		{url: site.URL + "/ok", wantStatus: http.StatusOK, wantOK: true},
+		{url: site.URL + "/moved", wantStatus: http.StatusOK, wantOK: true},
+		{url: site.URL + "/gone", wantStatus: http.StatusNotFound},
+		{url: site.URL + "/broken", wantStatus: http.StatusInternalServerError},
+		{url: site.URL + "/get-only", wantStatus: http.StatusOK, wantOK: true},
+		{url: site.URL + "/get-only-gone", wantStatus: http.StatusNotFound},
+		{url: closed.URL + "/ok", wantErr: true},
+		{url: "not a url\x7f", wantErr: true},
+	}
+	urls := make([]string, len(tests))
+	for i, tt := range tests {
+		urls[i] = tt.url
+	}
+
+	results := New(WithInterval(0), WithTimeout(time.Second)).Check(context.Background(), urls)
+
+	if len(results) != len(tests) {
+		t.Fatalf("got %d results, want %d", len(results), len(tests))
+	}
+	for i, tt := range tests {
+		got := results[i]
+		if got.URL != tt.url {
+			t.Errorf("result %d is for %q, want %q", i, got.URL, tt.url)
+		}
+		if got.Status != tt.wantStatus || got.OK() != tt.wantOK || (got.Err != nil) != tt.wantErr {
+			t.Errorf("%s: status %d, OK %t, error %v; want status %d, OK %t, error %t",
+				tt.url, got.Status, got.OK(), got.Err, tt.wantStatus, tt.wantOK, tt.wantErr)
+		}
+	}
+}
+
+func TestCheckConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	var inFlight, most int
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		mu.Lock()
+		inFlight++
+		most = max(most, inFlight)
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+	urls := make([]string, 8)
+	for i := range urls {
+		urls[i] = server.URL
+	}
+
+	results := New(WithConcurrency(2), WithInterval(0)).Check(context.Background(), urls)
+
+	for _, r := range results {
+		if !r.OK() {
+			t.Errorf("%s: %s, want OK", r.URL, r.Problem())
+		}
+	}
+	if most != 2 {
+		t.Errorf("at most %d requests in flight, want 2", most)
+	}
+}
+
+func TestCheckInterval(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		requests.Add(1)
+	}))
+	t.Cleanup(server.Close)
+	const interval = 30 * time.Millisecond
+
+	start := time.Now()
+	New(WithConcurrency(3), WithInterval(interval)).Check(context.Background(), []string{server.URL, server.URL, server.URL})
+
+	// Even with a worker for each link, each request waits its turn
+	if elapsed := time.Since(start); elapsed < 3*interval {
+		t.Errorf("checked 3 links in %v, want at least %v", elapsed, 3*interval)
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("got %d requests, want 3", n)
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		<-release
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	results := New(WithTimeout(20*time.Millisecond), WithInterval(0)).Check(context.Background(), []string{server.URL})
+
+	if results[0].OK() || results[0].Err == nil {
+		t.Errorf("result = %+v, want a timeout error", results[0])
+	}
+}
+
+func TestCheckCanceled(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		requests.Add(1)
+	}))
+	t.Cleanup(server.Close)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results := New(WithInterval(time.Hour)).Check(ctx, []string{server.URL, server.URL})
+
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	for i, r := range results {
+		if r.URL != "" {
+			t.Errorf("result %d = %+v, want it left empty", i, r)
+		}
+	}
+	if n := requests.Load(); n != 0 {
+		t.Errorf("got %d requests after cancellation, want none", n)
+	}
+}
+
+func TestResultProblem(t *testing.T) {
+	tests := []struct {
+		want   string
+		result Result
+	}{
+		{result: Result{Status: http.StatusNotFound}, want: "404 Not Found"},
+		{result: Result{Status: http.StatusServiceUnavailable}, want: "503 Service Unavailable"},
+		{result: Result{Err: errors.New("connection refused")}, want: "connection refused"},
+	}
+	for _, tt := range tests {
+		if got := tt.result.Problem(); got != tt.want {
+			t.Errorf("Problem() = %q, want %q", got, tt.want)
+		}
+		if tt.result.OK() {
+			t.Errorf("%+v is OK, want dead", tt.result)
+		}
+	}
+}
@@ -1,7 +1,11 @@
 // Package models defines the data structures for Movie Journal.
 package models
 
-import "time"
+import (
+	"errors"
+	"net/url"
+	"time"
+)
 
 // Movie represents a movie from TMDB with cached metadata.
 type Movie struct {
@@ -10,25 +14,117 @@ type Movie struct {
 	Director  string `json:"director"`
 	Genre     string `json:"genre"`
 	Overview  string `json:"overview"`
-	ID        int64  `json:"id"`
-	TMDBID    int    `json:"tmdb_id"`
-	Year      int    `json:"year"`
+	// IMDbID is the IMDb title ID, such as "tt0113277", or empty if unknown.
+	IMDbID string `json:"imdb_id,omitempty"`
+	// Tagline and Keywords come from TMDB and are only loaded for the movie page.
+	Tagline  string   `json:"tagline,omitempty"`
+	Keywords []string `json:"keywords,omitempty"`
+	Genres   []string `json:"genres,omitempty"`
+	ID       int64    `json:"id"`
+	TMDBID   int      `json:"tmdb_id"`
+	// Year is zero when the release year is unknown.
+	Year int `json:"year,omitempty"`
+}
+
+// MovieFacts is the extra detail about a movie fetched from TMDB for its page. Either
+// field is empty when TMDB doesn't know it.
+type MovieFacts struct {
+	Tagline  string   `json:"tagline"`
+	Keywords []string `json:"keywords"`
+}
+
+// MovieLookups pairs a movie with the number of lookups made across all of its viewings.
+type MovieLookups struct {
+	Movie       Movie `json:"movie"`
+	LookupCount int   `json:"lookup_count"`
+}
+
+// GenreRating is the average rating given to movies of a genre.
+type GenreRating struct {
+	// Genre is empty for movies without any genre.
+	Genre   string  `json:"genre"`
+	Average float64 `json:"average"`
+	// Count is the number of rated entries the average is taken over.
+	Count int `json:"count"`
+}
+
+// MovieSearchResult is a movie returned by search, labeled with where it was found.
+type MovieSearchResult struct {
+	Movie Movie `json:"movie"`
+	// InLibrary is true for movies already in the local database, false for TMDB results.
+	InLibrary bool `json:"in_library"`
 }
 
 // DiaryEntry represents a movie viewing session.
 type DiaryEntry struct {
 	WatchedDate     time.Time `json:"watched_date"`
 	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
 	Movie           *Movie    `json:"movie,omitempty"`
 	WatchedLocation string    `json:"watched_location,omitempty"`
+	Format          string    `json:"format,omitempty"`
 	WatchedWith     string    `json:"watched_with"`
 	Notes           string    `json:"notes"`
+	Slug            string    `json:"slug,omitempty"`
 	Lookups         []Lookup  `json:"lookups,omitempty"`
 	ID              int64     `json:"id"`
 	MovieID         int64     `json:"movie_id"`
 	Rating          int       `json:"rating"`
+	// LookupCount is the number of lookups, filled in even when Lookups isn't loaded.
+	LookupCount int `json:"lookup_count"`
+}
+
+// EntryFilter describes the filters applied to a list of diary entries.
+// Empty fields mean the filter isn't applied.
+type EntryFilter struct {
+	// WatchedFrom and WatchedTo limit entries to those watched within [WatchedFrom,
+	// WatchedTo) when set. Handlers fill them in from DateRange, which is relative to today.
+	WatchedFrom time.Time `json:"-"`
+	WatchedTo   time.Time `json:"-"`
+	MinRating   string    `json:"min_rating,omitempty"`
+	Genre       string    `json:"genre,omitempty"`
+	// DateRange is a quick filter preset such as "today" or "month".
+	DateRange string `json:"range,omitempty"`
+	Sort      string `json:"sort,omitempty"`
+	// Decade is the release decade, such as "1990", or "unknown" for movies without a year.
+	Decade string `json:"decade,omitempty"`
+	// Format is the watch format, matched ignoring case.
+	Format string `json:"format,omitempty"`
+}
+
+// Query returns the filter as the query parameters of the recent entries list.
+func (f EntryFilter) Query() url.Values {
+	params := url.Values{}
+	if f.MinRating != "" {
+		params.Set("min_rating", f.MinRating)
+	}
+	if f.Genre != "" {
+		params.Set("genre", f.Genre)
+	}
+	if f.DateRange != "" {
+		params.Set("range", f.DateRange)
+	}
+	if f.Sort != "" {
+		params.Set("sort", f.Sort)
+	}
+	if f.Decade != "" {
+		params.Set("decade", f.Decade)
+	}
+	if f.Format != "" {
+		params.Set("format", f.Format)
+	}
+	return params
 }
 
+// DecadeUnknown is the EntryFilter.Decade value for movies without a release year.
+const DecadeUnknown = "unknown"
+
+// Sort orders for diary entry lists. The zero value sorts by watched date.
+const (
+	SortWatchedDate = "date"
+	SortRating      = "rating"
+)
+
 // LookupCategory represents the type of research moment.
 type LookupCategory string
 
@@ -40,6 +136,30 @@ const (
 	LookupCategoryOther    LookupCategory = "other"
 )
 
+// Valid reports whether the category is one of the known lookup categories.
+func (c LookupCategory) Valid() bool {
+	switch c {
+	case LookupCategoryActor, LookupCategoryLocation, LookupCategoryTrivia, LookupCategoryOther:
+		return true
+	}
+	return false
+}
+
+// ErrInvalidLookupURL is returned when a lookup URL isn't an absolute http or https link.
+var ErrInvalidLookupURL = errors.New("lookup URL must be an http or https link")
+
+// ValidateLookupURL checks that a lookup URL, if present, is an absolute http or https link.
+func ValidateLookupURL(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return ErrInvalidLookupURL
+	}
+	return nil
+}
+
 // Lookup represents a research moment during viewing.
 type Lookup struct {
 	CreatedAt    time.Time      `json:"created_at"`
@@ -51,14 +171,30 @@ type Lookup struct {
 	DiaryEntryID int64          `json:"diary_entry_id"`
 }
 
+// ExportedLookup is a lookup together with the movie it came up in, as exported.
+type ExportedLookup struct {
+	Question string         `json:"question"`
+	Answer   string         `json:"answer"`
+	URL      string         `json:"url,omitempty"`
+	Category LookupCategory `json:"category"`
+	Movie    string         `json:"movie"`
+}
+
 // DiaryEntryInput is used for creating/updating diary entries.
 type DiaryEntryInput struct {
-	WatchedAt   time.Time `json:"watched_at"`
-	Location    string    `json:"location,omitempty"`
-	Notes       string    `json:"notes"`
-	WatchedWith string    `json:"watched_with"`
-	MovieID     int64     `json:"movie_id"`
-	Rating      int       `json:"rating"`
+	WatchedAt time.Time `json:"watched_at"`
+	// UpdatedAt is when the entry being edited was last changed, as loaded. When set, the
+	// update fails with a conflict if the entry has changed since.
+	UpdatedAt time.Time `json:"updated_at"`
+	// NewMovie is a movie to add to the library along with the entry, which is then
+	// logged for it instead of MovieID. Its ID is filled in once the entry is saved.
+	NewMovie    *Movie `json:"-"`
+	Location    string `json:"location,omitempty"`
+	Format      string `json:"format,omitempty"`
+	Notes       string `json:"notes"`
+	WatchedWith string `json:"watched_with"`
+	MovieID     int64  `json:"movie_id"`
+	Rating      int    `json:"rating"`
 }
 
 // LookupInput is used for creating/updating lookups.
@@ -69,3 +205,22 @@ type LookupInput struct {
 	URL          string         `json:"url"`
 	DiaryEntryID int64          `json:"diary_entry_id"`
 }
+
+// Stats summarizes the diary as a whole.
+type Stats struct {
+	// TopGenre is the genre with the most entries, empty when no movie has a genre.
+	TopGenre string `json:"top_genre"`
+	// AverageRating is the mean rating of rated entries.
+	AverageRating float64 `json:"average_rating"`
+	TotalEntries  int     `json:"total_entries"`
+	// TotalMovies counts distinct movies, so rewatches count once.
+	TotalMovies int `json:"total_movies"`
+	// EntriesThisYear counts entries watched in the current calendar year.
+	EntriesThisYear int `json:"entries_this_year"`
+	// TotalWords counts the words across all entry notes.
+	TotalWords int `json:"total_words"`
+	// AverageNoteWords is the mean word count of entries that have notes.
+	AverageNoteWords int `json:"average_note_words"`
+	// CurrentStreak is the number of consecutive days with an entry, ending today or yesterday.
+	CurrentStreak int `json:"current_streak"`
+}
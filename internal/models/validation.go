@@ -0,0 +1,69 @@
+package models
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// ValidationError reports which input fields are invalid and why, keyed by field name.
+type ValidationError struct {
+	Fields map[string]string `json:"fields"`
+}
+
+// Add records a message for the field. The first message recorded for a field wins.
+func (e *ValidationError) Add(field, message string) {
+	if e.Fields == nil {
+		e.Fields = make(map[string]string)
+	}
+	if _, ok := e.Fields[field]; !ok {
+		e.Fields[field] = message
+	}
+}
+
+// Err returns e if any field was reported, or nil otherwise.
+func (e *ValidationError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Error lists the field messages in field name order.
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Fields))
+	for _, field := range slices.Sorted(maps.Keys(e.Fields)) {
+		parts = append(parts, field+": "+e.Fields[field])
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Validate checks a diary entry input before it's saved.
+func (input DiaryEntryInput) Validate() error {
+	var verr ValidationError
+	if input.MovieID <= 0 && input.NewMovie == nil {
+		verr.Add("movie_id", "movie is required")
+	}
+	if input.WatchedAt.IsZero() {
+		verr.Add("watched_at", "watched date is required")
+	}
+	if input.Rating < 0 || input.Rating > 5 {
+		verr.Add("rating", "rating must be between 1 and 5")
+	}
+	return verr.Err()
+}
+
+// Validate checks a lookup input before it's saved. The category must already be set.
+func (input LookupInput) Validate() error {
+	var verr ValidationError
+	if strings.TrimSpace(input.Question) == "" {
+		verr.Add("question", "question is required")
+	}
+	if !input.Category.Valid() {
+		verr.Add("category", "unknown category "+string(input.Category))
+	}
+	if err := ValidateLookupURL(input.URL); err != nil {
+		verr.Add("url", err.Error())
+	}
+	return verr.Err()
+}
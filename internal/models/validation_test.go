@@ -0,0 +1,74 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestValidationError(t *testing.T) {
+	var verr ValidationError
+	if err := verr.Err(); err != nil {
+		t.Errorf("Err() with no fields = %v, want nil", err)
+	}
+
+	verr.Add("rating", "rating must be between 1 and 5")
+	verr.Add("movie_id", "movie is required")
+	verr.Add("rating", "a later message")
+
+	if got, want := verr.Error(), "movie_id: movie is required; rating: rating must be between 1 and 5"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+
+	// Wrapped errors can still be matched by callers in other layers
+	wrapped := fmt.Errorf("saving entry: %w", verr.Err())
+	var target *ValidationError
+	if !errors.As(wrapped, &target) {
+		t.Fatalf("errors.As didn't find the ValidationError in %v", wrapped)
+	}
+	if target.Fields["rating"] != "rating must be between 1 and 5" {
+		t.Errorf("rating message = %q, want the first one added", target.Fields["rating"])
+	}
+}
+
+func TestDiaryEntryInputValidate(t *testing.T) {
+	watched := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		wantFields []string
+		input      DiaryEntryInput
+	}{
+		{name: "valid", input: DiaryEntryInput{MovieID: 1, WatchedAt: watched, Rating: 5}},
+		{name: "unrated", input: DiaryEntryInput{MovieID: 1, WatchedAt: watched}},
+		{name: "new movie", input: DiaryEntryInput{NewMovie: &Movie{Title: "Dune"}, WatchedAt: watched}},
+		{name: "rating too high", input: DiaryEntryInput{MovieID: 1, WatchedAt: watched, Rating: 7}, wantFields: []string{"rating"}},
+		{name: "negative rating", input: DiaryEntryInput{MovieID: 1, WatchedAt: watched, Rating: -1}, wantFields: []string{"rating"}},
+		{name: "empty", input: DiaryEntryInput{}, wantFields: []string{"movie_id", "watched_at"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.input.Validate()
+			if len(tt.wantFields) == 0 {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("Validate() = %v, want a ValidationError", err)
+			}
+			if len(verr.Fields) != len(tt.wantFields) {
+				t.Errorf("fields = %v, want %v", verr.Fields, tt.wantFields)
+			}
+			for _, field := range tt.wantFields {
+				if _, ok := verr.Fields[field]; !ok {
+					t.Errorf("fields = %v, missing %q", verr.Fields, field)
+				}
+			}
+		})
+	}
+}
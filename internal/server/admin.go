@@ -0,0 +1,54 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// adminUser is the basic auth username for admin endpoints.
+const adminUser = "admin"
+
+// requireAdmin guards an admin endpoint with basic auth against the configured admin
+// password. Admin endpoints don't exist at all when no password is configured.
+func (s *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.config.AdminPassword == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		user, password, ok := r.BasicAuth()
+		userOK := subtle.ConstantTimeCompare([]byte(user), []byte(adminUser)) == 1
+		passwordOK := subtle.ConstantTimeCompare([]byte(password), []byte(s.config.AdminPassword)) == 1
+		if !ok || !userOK || !passwordOK {
+			w.Header().Set("WWW-Authenticate", `Basic realm="movie-journal admin", charset="UTF-8"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// handleMigrate applies any pending database migrations and reports the resulting schema version.
+func (s *Server) handleMigrate(w http.ResponseWriter, r *http.Request) {
+	if err := s.config.DB.Migrate(r.Context()); err != nil {
+		slog.Error("Failed to run migrations", slog.String("error", err.Error()))
+		http.Error(w, "Failed to run migrations", http.StatusInternalServerError)
+		return
+	}
+
+	version, err := s.config.DB.SchemaVersion(r.Context())
+	if err != nil {
+		slog.Error("Failed to get schema version", slog.String("error", err.Error()))
+		http.Error(w, "Failed to get schema version", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(struct {
+		SchemaVersion int `json:"schema_version"`
+	}{version})
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/pavelanni/movie-journal/internal/database"
+)
+
+// openPinnedDB opens a database and rolls back its latest migration, leaving it one
+// version behind the binary.
+func openPinnedDB(t *testing.T) (*database.DB, int) {
+	t.Helper()
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	ctx := context.Background()
+	latest, err := db.SchemaVersion(ctx)
+	if err != nil {
+		t.Fatalf("SchemaVersion: %v", err)
+	}
+	// Undo the latest migration, which adds lookups.link_ok
+	_, err = db.ExecContext(ctx, "ALTER TABLE lookups DROP COLUMN link_ok")
+	if err != nil {
+		t.Fatalf("undoing the latest migration: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = ?", latest); err != nil {
+		t.Fatalf("unrecording the latest migration: %v", err)
+	}
+	return db, latest
+}
+
+func TestHandleMigrate(t *testing.T) {
+	db, latest := openPinnedDB(t)
+	s := New(Config{DB: db, AdminPassword: "secret", Port: 8080})
+
+	r := httptest.NewRequest(http.MethodPost, "/admin/migrate", nil)
+	r.SetBasicAuth(adminUser, "secret")
+	w := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	var got struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if got.SchemaVersion != latest {
+		t.Errorf("reported schema version = %d, want %d", got.SchemaVersion, latest)
+	}
+	var linkOK int
+	err := db.QueryRowContext(context.Background(),
+		"SELECT COUNT(*) FROM pragma_table_info('lookups') WHERE name = 'link_ok'").Scan(&linkOK)
+	if err != nil || linkOK != 1 {
+		t.Errorf("lookups.link_ok exists = %d (%v), want the pending migration applied", linkOK, err)
+	}
+}
+
+func TestHandleMigrateRequiresAdmin(t *testing.T) {
+	tests := []struct {
+		name       string
+		password   string
+		user       string
+		sent       string
+		wantStatus int
+	}{
+		{name: "admin disabled", user: adminUser, sent: "", wantStatus: http.StatusNotFound},
+		{name: "no credentials", password: "secret", wantStatus: http.StatusUnauthorized},
+		{name: "wrong password", password: "secret", user: adminUser, sent: "guess", wantStatus: http.StatusUnauthorized},
+		{name: "wrong user", password: "secret", user: "root", sent: "secret", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, latest := openPinnedDB(t)
+			s := New(Config{DB: db, AdminPassword: tt.password, Port: 8080})
+
+			r := httptest.NewRequest(http.MethodPost, "/admin/migrate", nil)
+			if tt.user != "" {
+				r.SetBasicAuth(tt.user, tt.sent)
+			}
+			w := httptest.NewRecorder()
+			s.httpServer.Handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if version, err := db.SchemaVersion(context.Background()); err != nil || version != latest-1 {
+				t.Errorf("schema version = %d (%v), want still %d", version, err, latest-1)
+			}
+		})
+	}
+}
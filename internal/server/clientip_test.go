@@ -0,0 +1,70 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies: %v", err)
+	}
+	s := &Server{config: Config{TrustedProxies: proxies}}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{
+			name:       "untrusted peer's header is ignored",
+			remoteAddr: "203.0.113.7:5000",
+			forwarded:  "198.51.100.1",
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "trusted peer without a header",
+			remoteAddr: "10.0.0.2:5000",
+			want:       "10.0.0.2",
+		},
+		{
+			name:       "trusted peer names the client",
+			remoteAddr: "10.0.0.2:5000",
+			forwarded:  "198.51.100.1",
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "spoofed entries left of the client are ignored",
+			remoteAddr: "192.168.1.1:5000",
+			forwarded:  "1.2.3.4, 198.51.100.1, 10.1.2.3",
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "garbage stops the walk at the last good address",
+			remoteAddr: "10.0.0.2:5000",
+			forwarded:  "198.51.100.1, not-an-ip, 10.1.2.3",
+			want:       "10.1.2.3",
+		},
+		{
+			name:       "IPv4-mapped IPv6 peer is unmapped",
+			remoteAddr: "[::ffff:10.0.0.2]:5000",
+			forwarded:  "198.51.100.1",
+			want:       "198.51.100.1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if got := s.clientIP(r); got != tt.want {
+				t.Errorf("clientIP = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestContentSecurityPolicyHeader(t *testing.T) {
+	s := New(Config{ContentSecurityPolicy: DefaultContentSecurityPolicy})
+	r := httptest.NewRequest(http.MethodGet, "/static/js/missing.js", nil)
+	w := httptest.NewRecorder()
+
+	s.httpServer.Handler.ServeHTTP(w, r)
+
+	policy := w.Header().Get("Content-Security-Policy")
+	if policy == "" {
+		t.Fatal("no Content-Security-Policy header")
+	}
+	if !strings.Contains(policy, "img-src 'self' data: https://image.tmdb.org") {
+		t.Errorf("policy doesn't allow TMDB posters: %s", policy)
+	}
+	if strings.Contains(policy, "'unsafe-eval'") {
+		t.Errorf("policy allows eval: %s", policy)
+	}
+}
+
+func TestContentSecurityPolicyDisabled(t *testing.T) {
+	s := New(Config{})
+	r := httptest.NewRequest(http.MethodGet, "/static/js/missing.js", nil)
+	w := httptest.NewRecorder()
+
+	s.httpServer.Handler.ServeHTTP(w, r)
+
+	if policy := w.Header().Get("Content-Security-Policy"); policy != "" {
+		t.Errorf("Content-Security-Policy = %q, want none for an empty policy", policy)
+	}
+}
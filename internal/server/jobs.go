@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// jobTracker runs background jobs, such as prefetching TMDB movie details, and lets
+// shutdown wait for them. Jobs get a context that's canceled if shutdown gives up waiting.
+type jobTracker struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	closed bool
+}
+
+// newJobTracker returns a tracker ready to run jobs.
+func newJobTracker() *jobTracker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &jobTracker{ctx: ctx, cancel: cancel}
+}
+
+// Go runs job in the background. Jobs started after shutdown has begun are dropped.
+func (t *jobTracker) Go(name string, job func(ctx context.Context)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		slog.Warn("Dropping background job during shutdown", slog.String("job", name))
+		return
+	}
+
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		job(t.ctx)
+	}()
+}
+
+// wait stops accepting jobs and waits for running ones to finish. If ctx ends first,
+// it cancels the jobs' context and returns without waiting any longer.
+func (t *jobTracker) wait(ctx context.Context) error {
+	t.mu.Lock()
+	t.closed = true
+	t.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.cancel()
+		return nil
+	case <-ctx.Done():
+		t.cancel()
+		return fmt.Errorf("waiting for background jobs: %w", ctx.Err())
+	}
+}
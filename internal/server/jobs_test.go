@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestJobTrackerWaitsForJobs(t *testing.T) {
+	jobs := newJobTracker()
+	release := make(chan struct{})
+	finished := make(chan struct{})
+	jobs.Go("blocking", func(context.Context) {
+		<-release
+		close(finished)
+	})
+
+	waited := make(chan error, 1)
+	go func() { waited <- jobs.wait(context.Background()) }()
+
+	select {
+	case err := <-waited:
+		t.Fatalf("wait returned %v while the job was still running", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-waited; err != nil {
+		t.Errorf("wait: %v", err)
+	}
+	select {
+	case <-finished:
+	default:
+		t.Error("wait returned before the job finished")
+	}
+}
+
+func TestShutdownWithBlockingJob(t *testing.T) {
+	s := New(Config{})
+	canceled := make(chan struct{})
+	s.jobs.Go("blocking", func(ctx context.Context) {
+		<-ctx.Done()
+		close(canceled)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := s.Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown = %v, want the deadline to be exceeded", err)
+	}
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("the job's context wasn't canceled when shutdown gave up")
+	}
+
+	// Jobs started once shutdown has begun are dropped
+	ran := make(chan struct{}, 1)
+	s.jobs.Go("late", func(context.Context) { ran <- struct{}{} })
+	time.Sleep(10 * time.Millisecond)
+	if len(ran) != 0 {
+		t.Error("a job started after shutdown ran")
+	}
+}
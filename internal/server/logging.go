@@ -0,0 +1,61 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// statusRecorder captures the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status before passing it on.
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// logRequests logs each request with its status, duration, and client address.
+// Successful requests under the sampled paths are only logged at the sample rate.
+func (s *Server) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r)
+
+		if !s.shouldLog(r, rec.status) {
+			return
+		}
+		slog.Info("Request handled",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.status),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("client_ip", s.clientIP(r)),
+		)
+	})
+}
+
+// shouldLog reports whether a request with the given response status gets logged. Failed
+// requests always are; successful ones under a sampled path prefix only at the sample rate.
+func (s *Server) shouldLog(r *http.Request, status int) bool {
+	if status < 200 || status > 299 {
+		return true
+	}
+	for _, prefix := range s.config.LogSamplePaths {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return s.sample() < s.config.LogSampleRate
+		}
+	}
+	return true
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleManifest(t *testing.T) {
+	s := New(Config{AppName: "My Movies", Port: 8080})
+	w := httptest.NewRecorder()
+
+	s.httpServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/manifest.webmanifest", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/manifest+json" {
+		t.Errorf("Content-Type = %q, want application/manifest+json", got)
+	}
+	var manifest webManifest
+	if err := json.NewDecoder(w.Body).Decode(&manifest); err != nil {
+		t.Fatalf("decoding manifest: %v", err)
+	}
+	if manifest.Name != "My Movies" || manifest.ShortName != "My Movies" {
+		t.Errorf("name = %q, short_name = %q, want the app name", manifest.Name, manifest.ShortName)
+	}
+	if manifest.ThemeColor != themeColor {
+		t.Errorf("theme_color = %q, want %q", manifest.ThemeColor, themeColor)
+	}
+	if len(manifest.Icons) == 0 || manifest.Icons[0].Src != "/static/favicon.svg" {
+		t.Errorf("icons = %+v, want the favicon", manifest.Icons)
+	}
+}
+
+func TestHandleFavicon(t *testing.T) {
+	// The favicon path is relative to the repository root, where the server runs.
+	t.Chdir("../..")
+	s := New(Config{Port: 8080})
+	w := httptest.NewRecorder()
+
+	s.httpServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/favicon.ico", nil))
+
+	if got := w.Header().Get("Content-Type"); got != "image/svg+xml" {
+		t.Errorf("Content-Type = %q, want image/svg+xml", got)
+	}
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/pavelanni/movie-journal/templates"
+)
+
+// privateCookie is the name of the cookie that keeps private mode on between requests.
+const privateCookie = "mj_private"
+
+// privateCookieMaxAge keeps private mode on for a year unless it's turned off.
+const privateCookieMaxAge = 365 * 24 * 60 * 60
+
+// withPrivateMode hides ratings from rendered pages while private mode is on.
+// "?private=1" turns it on and "?private=0" turns it off; either choice is remembered
+// in a cookie.
+func withPrivateMode(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		private := false
+		if cookie, err := r.Cookie(privateCookie); err == nil && cookie.Value == "1" {
+			private = true
+		}
+
+		switch r.URL.Query().Get("private") {
+		case "1":
+			private = true
+			setPrivateCookie(w, "1", privateCookieMaxAge)
+		case "0":
+			private = false
+			setPrivateCookie(w, "", -1)
+		}
+
+		if private {
+			r = r.WithContext(templates.WithHiddenRatings(r.Context()))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setPrivateCookie sets or, with a negative maxAge, clears the private mode cookie.
+func setPrivateCookie(w http.ResponseWriter, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     privateCookie,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
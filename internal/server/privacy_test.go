@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pavelanni/movie-journal/internal/database"
+	"github.com/pavelanni/movie-journal/internal/models"
+)
+
+func TestPrivateModeHidesRatings(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	ctx := context.Background()
+	movie, err := db.SaveMovie(ctx, models.Movie{TMDBID: 438631, Title: "Dune", Year: 2021})
+	if err != nil {
+		t.Fatalf("saving movie: %v", err)
+	}
+	id, err := db.CreateDiaryEntry(ctx, models.DiaryEntryInput{
+		MovieID: movie.ID, WatchedAt: time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC), Rating: 4,
+	})
+	if err != nil {
+		t.Fatalf("creating entry: %v", err)
+	}
+	s := New(Config{DB: db, Port: 8080})
+	path := "/diary/" + strconv.FormatInt(id, 10)
+
+	get := func(target string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: status = %d, want %d", target, w.Code, http.StatusOK)
+		}
+		return w
+	}
+	showsRating := func(w *httptest.ResponseRecorder) bool {
+		return strings.Contains(w.Body.String(), "Rating:")
+	}
+	privateCookieFrom := func(w *httptest.ResponseRecorder) *http.Cookie {
+		for _, c := range w.Result().Cookies() {
+			if c.Name == privateCookie {
+				return c
+			}
+		}
+		return nil
+	}
+
+	if w := get(path, nil); !showsRating(w) {
+		t.Fatal("entry page doesn't show the rating outside private mode")
+	}
+
+	w := get(path+"?private=1", nil)
+	if showsRating(w) {
+		t.Errorf("private mode shows the rating:\n%s", w.Body)
+	}
+	cookie := privateCookieFrom(w)
+	if cookie == nil || cookie.Value != "1" {
+		t.Fatalf("private mode cookie = %v, want it turned on", cookie)
+	}
+
+	// The cookie keeps private mode on without the query parameter
+	if w := get(path, cookie); showsRating(w) {
+		t.Error("private mode isn't remembered by the cookie")
+	}
+
+	w = get(path+"?private=0", cookie)
+	if !showsRating(w) {
+		t.Error("?private=0 doesn't show the rating again")
+	}
+	if c := privateCookieFrom(w); c == nil || c.MaxAge >= 0 {
+		t.Errorf("private mode cookie = %v, want it cleared", c)
+	}
+
+	entry, err := db.GetDiaryEntry(ctx, id)
+	if err != nil || entry.Rating != 4 {
+		t.Errorf("stored rating = %v (%v), want it unchanged at 4", entry, err)
+	}
+}
@@ -3,66 +3,219 @@ package server
 
 import (
 	"context"
-	"fmt"
+	"encoding/json"
 	"log/slog"
+	"math/rand/v2"
+	"net"
 	"net/http"
+	"net/netip"
+	"strconv"
 	"time"
 
+	"github.com/pavelanni/movie-journal/internal/answers"
 	"github.com/pavelanni/movie-journal/internal/database"
 	"github.com/pavelanni/movie-journal/internal/handlers"
+	"github.com/pavelanni/movie-journal/internal/tmdb"
+	"github.com/pavelanni/movie-journal/templates"
 )
 
 // Config holds server configuration.
 type Config struct {
-	DB   *database.DB
-	Port int
+	DB *database.DB
+	// TMDB is optional; movie search falls back to the local library without it.
+	TMDB *tmdb.Client
+	// Answerer is optional; answer suggestions for lookups are off without it.
+	Answerer answers.Answerer
+	// RatingColors overrides the star colors; nil keeps the defaults.
+	RatingColors *templates.RatingColors
+	// RatingSymbol is the glyph ratings are drawn with; empty keeps stars.
+	RatingSymbol templates.RatingSymbol
+	// AppName is shown when the app is installed to a home screen.
+	AppName string
+	// Host is the interface to bind to; empty means all interfaces.
+	Host string
+	// AdminPassword enables the admin endpoints behind basic auth; empty disables them.
+	AdminPassword string
+	// Version is the build version reported by the health check.
+	Version string
+	// DateFormat is the Go time layout used to display dates; empty keeps each page's default.
+	DateFormat string
+	// ContentSecurityPolicy is sent as the Content-Security-Policy header; empty sends none.
+	ContentSecurityPolicy string
+	// TLSCertFile and TLSKeyFile serve HTTPS when both are set; otherwise the server speaks plain HTTP.
+	TLSCertFile string
+	TLSKeyFile  string
+	// LogSamplePaths lists path prefixes, such as "/static/", whose successful requests
+	// are logged only at LogSampleRate.
+	LogSamplePaths []string
+	// TrustedProxies lists the reverse proxies whose X-Forwarded-For headers are believed.
+	TrustedProxies []netip.Prefix
+	// RequestTimeout cuts off slow requests with a 503; zero means no limit.
+	RequestTimeout time.Duration
+	// LogSampleRate is the fraction, from 0 to 1, of successful LogSamplePaths requests that are logged.
+	LogSampleRate float64
+	// DraftTTL is how long an untouched new entry draft is kept; zero keeps drafts forever.
+	DraftTTL time.Duration
+	Port     int
+	// MaxNotesLength caps entry notes, in characters; zero means no limit.
+	MaxNotesLength int
+	// RecentLimit is how many of the most recent entries the home page shows.
+	RecentLimit int
+	// PerPage is the number of entries on each page of the diary; zero shows them all.
+	PerPage int
+	// NotesMarkdown renders entry notes as Markdown on detail views.
+	NotesMarkdown bool
 }
 
 // Server is the Movie Journal HTTP server.
 type Server struct {
+	startedAt  time.Time
 	httpServer *http.Server
 	mux        *http.ServeMux
 	handlers   *handlers.Handlers
-	config     Config
+	jobs       *jobTracker
+	// sample returns a number in [0, 1) to decide whether a sampled request is logged.
+	sample func() float64
+	config Config
 }
 
 // New creates a new server with the given configuration.
 func New(cfg Config) *Server {
 	mux := http.NewServeMux()
+	jobs := newJobTracker()
 
 	s := &Server{
-		config:   cfg,
-		mux:      mux,
-		handlers: handlers.New(cfg.DB),
+		startedAt: time.Now(),
+		config:    cfg,
+		mux:       mux,
+		jobs:      jobs,
+		sample:    rand.Float64,
+		handlers: handlers.New(handlers.Config{
+			DB:             cfg.DB,
+			TMDB:           cfg.TMDB,
+			Answerer:       cfg.Answerer,
+			RunJob:         jobs.Go,
+			MaxNotesLength: cfg.MaxNotesLength,
+			RecentLimit:    cfg.RecentLimit,
+			PerPage:        cfg.PerPage,
+			DraftTTL:       cfg.DraftTTL,
+		}),
 		httpServer: &http.Server{
-			Addr:         fmt.Sprintf(":%d", cfg.Port),
-			Handler:      mux,
+			Addr:         net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
 			ReadTimeout:  15 * time.Second,
 			WriteTimeout: 15 * time.Second,
 			IdleTimeout:  60 * time.Second,
 		},
 	}
 
+	s.httpServer.Handler = withTracing(s.logRequests(s.withContentSecurityPolicy(s.withTimeout(s.withDisplaySettings(withPrivateMode(mux))))))
 	s.setupRoutes()
 
 	return s
 }
 
+// withDisplaySettings makes the configured date layout, rating colors, rating symbol, and
+// notes Markdown mode available to templates.
+func (s *Server) withDisplaySettings(next http.Handler) http.Handler {
+	if s.config.DateFormat == "" && s.config.RatingColors == nil && s.config.RatingSymbol == "" && !s.config.NotesMarkdown {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if s.config.DateFormat != "" {
+			ctx = templates.WithDateFormat(ctx, s.config.DateFormat)
+		}
+		if s.config.RatingColors != nil {
+			ctx = templates.WithRatingColors(ctx, *s.config.RatingColors)
+		}
+		if s.config.RatingSymbol != "" {
+			ctx = templates.WithRatingSymbol(ctx, s.config.RatingSymbol)
+		}
+		if s.config.NotesMarkdown {
+			ctx = templates.WithNotesMarkdown(ctx)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 // setupRoutes configures all HTTP routes.
 func (s *Server) setupRoutes() {
 	// Static files
-	fs := http.FileServer(http.Dir("static"))
-	s.mux.Handle("GET /static/", http.StripPrefix("/static/", fs))
+	s.mux.Handle("GET /static/", http.StripPrefix("/static/", staticFileHandler(staticDir)))
+
+	// Icons and web app manifest
+	s.mux.HandleFunc("GET /favicon.ico", s.handleFavicon)
+	s.mux.HandleFunc("GET /manifest.webmanifest", s.handleManifest)
 
 	// Health check
 	s.mux.HandleFunc("GET /health", s.handleHealth)
 
-	// Home page
-	s.mux.HandleFunc("GET /", s.handlers.Home)
+	// Admin operations
+	s.mux.HandleFunc("POST /admin/migrate", s.requireAdmin(s.handleMigrate))
+
+	// Home page with the most recent entries, and the full diary a page at a time
+	s.mux.HandleFunc("GET /{$}", s.handlers.Home)
+	s.mux.HandleFunc("GET /diary", s.handlers.Diary)
+
+	// Anything else
+	s.mux.HandleFunc("GET /", s.handlers.NotFound)
 
 	// About page
 	s.mux.HandleFunc("GET /about", s.handlers.About)
 
+	// "Watch again?" suggestions for the home page
+	s.mux.HandleFunc("GET /rewatch-candidates", s.handlers.RewatchCandidates)
+
+	// Entries still missing a rating or notes, for the home page
+	s.mux.HandleFunc("GET /incomplete-entries", s.handlers.IncompleteEntries)
+
+	// Atom feed of recent entries
+	s.mux.HandleFunc("GET /feed.xml", s.handlers.Feed)
+
+	// Stats page
+	s.mux.HandleFunc("GET /stats", s.handlers.Stats)
+
+	// Films watched this year, for the header
+	s.mux.HandleFunc("GET /year-count", s.handlers.YearCount)
+
+	// Films ranked by how many lookups they prompted
+	s.mux.HandleFunc("GET /curious", s.handlers.CuriousFilms)
+
+	// Average rating per genre
+	s.mux.HandleFunc("GET /taste", s.handlers.Taste)
+
+	// Two entries side by side
+	s.mux.HandleFunc("GET /compare", s.handlers.Compare)
+
+	// Popular movies from TMDB
+	s.mux.HandleFunc("GET /discover", s.handlers.Discover)
+
+	// Entries grouped by release decade
+	s.mux.HandleFunc("GET /decades", s.handlers.Decades)
+
+	// Diary entry as HTML or JSON, depending on the Accept header
+	s.mux.HandleFunc("GET /entry/{id}", s.handlers.GetEntry)
+
+	// Formats watched in before, suggested on the entry forms
+	s.mux.HandleFunc("GET /formats", s.handlers.FormatSuggestions)
+
+	// Movie search (local library first, then TMDB)
+	s.mux.HandleFunc("GET /movies/search", s.handlers.SearchMovies)
+
+	// Movie page with every viewing and the trivia looked up across them
+	s.mux.HandleFunc("GET /movies/{id}", s.handlers.MovieDetail)
+
+	// Lookups browsed by category
+	s.mux.HandleFunc("GET /lookups/{category}", s.handlers.LookupsByCategory)
+
+	// Shareable entry pages
+	s.mux.HandleFunc("GET /v/{slug}", s.handlers.SharedDiaryEntry)
+
+	// JSON API
+	s.mux.HandleFunc("GET /api/entries", s.handlers.ListEntries)
+	s.mux.HandleFunc("POST /api/entries", s.handlers.CreateEntry)
+	s.mux.HandleFunc("POST /api/entries/{id}/lookups/batch", s.handlers.CreateLookupsBatch)
+
 	// HTMX endpoints
 	s.mux.HandleFunc("GET /diary/{id}", s.handlers.GetDiaryEntry)
 	s.mux.HandleFunc("DELETE /diary/{id}", s.handlers.DeleteDiaryEntry)
@@ -70,27 +223,65 @@ func (s *Server) setupRoutes() {
 	s.mux.HandleFunc("GET /recent-entries", s.handlers.GetRecentEntries)
 	s.mux.HandleFunc("GET /diary/new", s.handlers.NewDiaryEntryForm)
 	s.mux.HandleFunc("POST /diary/new", s.handlers.CreateDiaryEntry)
+	s.mux.HandleFunc("POST /diary/draft", s.handlers.SaveDraft)
+	s.mux.HandleFunc("GET /diary/{id}/duplicate", s.handlers.DuplicateDiaryEntry)
 	s.mux.HandleFunc("GET /diary-form/{id}", s.handlers.EditDiaryEntryForm)
 	s.mux.HandleFunc("PUT /diary/{id}", s.handlers.EditDiaryEntry)
+	s.mux.HandleFunc("GET /diary/{id}/lookups", s.handlers.SearchEntryLookups)
+	s.mux.HandleFunc("POST /diary/{id}/lookups", s.handlers.CreateLookup)
+	s.mux.HandleFunc("POST /diary/{id}/lookups/reorder", s.handlers.ReorderEntryLookups)
+	s.mux.HandleFunc("POST /lookups/suggest", s.handlers.SuggestAnswer)
+	s.mux.HandleFunc("GET /lookups/{id}/item", s.handlers.LookupItem)
+	s.mux.HandleFunc("GET /lookups/{id}/edit", s.handlers.EditLookupForm)
+	s.mux.HandleFunc("GET /lookups/{id}/cite", s.handlers.CiteLookup)
+	s.mux.HandleFunc("PUT /lookups/{id}", s.handlers.UpdateLookup)
+	s.mux.HandleFunc("POST /entries/delete", s.handlers.DeleteEntries)
+	s.mux.HandleFunc("POST /entries/rate", s.handlers.RateEntries)
+	s.mux.HandleFunc("POST /preferences", s.handlers.SavePreferences)
 }
 
-// Start starts the HTTP server.
+// Start starts the HTTP server, serving HTTPS when a certificate and key are configured.
 func (s *Server) Start() error {
+	checkStaticAssets(staticDir)
 	slog.Info("Starting server",
 		slog.String("addr", s.httpServer.Addr),
+		slog.Bool("tls", s.TLS()),
 	)
+	if s.TLS() {
+		return s.httpServer.ListenAndServeTLS(s.config.TLSCertFile, s.config.TLSKeyFile)
+	}
 	return s.httpServer.ListenAndServe()
 }
 
-// Shutdown gracefully shuts down the server.
+// TLS reports whether the server serves HTTPS.
+func (s *Server) TLS() bool {
+	return s.config.TLSCertFile != "" && s.config.TLSKeyFile != ""
+}
+
+// Shutdown gracefully shuts down the server, then waits for background jobs to finish.
+// Both share ctx's deadline.
 func (s *Server) Shutdown(ctx context.Context) error {
 	slog.Info("Shutting down server")
-	return s.httpServer.Shutdown(ctx)
+	if err := s.httpServer.Shutdown(ctx); err != nil {
+		return err
+	}
+	return s.jobs.wait(ctx)
+}
+
+// healthStatus is the body of the health check response.
+type healthStatus struct {
+	Status        string  `json:"status"`
+	Version       string  `json:"version"`
+	UptimeSeconds float64 `json:"uptime_seconds"`
 }
 
-// handleHealth returns server health status.
+// handleHealth returns server health status, build version, and uptime.
 func (s *Server) handleHealth(w http.ResponseWriter, _ *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	_, _ = fmt.Fprint(w, `{"status":"ok"}`)
+	_ = json.NewEncoder(w).Encode(healthStatus{
+		Status:        "ok",
+		Version:       s.config.Version,
+		UptimeSeconds: time.Since(s.startedAt).Seconds(),
+	})
 }
@@ -0,0 +1,24 @@
+package server
+
+import "testing"
+
+func TestNewBindAddress(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{host: "", want: ":8080"},
+		{host: "127.0.0.1", want: "127.0.0.1:8080"},
+		{host: "::1", want: "[::1]:8080"},
+		{host: "localhost", want: "localhost:8080"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			s := New(Config{Host: tt.host, Port: 8080})
+			if s.httpServer.Addr != tt.want {
+				t.Errorf("Addr = %q, want %q", s.httpServer.Addr, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pavelanni/movie-journal/templates"
+)
+
+// staticDir is where static assets are served from, relative to the working directory.
+const staticDir = "static"
+
+// criticalAssets are the static files the UI can't work without.
+var criticalAssets = []string{"js/htmx.min.js"}
+
+// checkStaticAssets warns when a critical asset is missing from dir, which usually means
+// the server was started from the wrong directory and pages will load without scripts.
+// It reports whether every critical asset was found.
+func checkStaticAssets(dir string) bool {
+	ok := true
+	for _, asset := range criticalAssets {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(asset))); err != nil {
+			abs, _ := filepath.Abs(dir)
+			slog.Warn("Static asset missing; is the server running from the project directory?",
+				slog.String("asset", asset),
+				slog.String("dir", abs),
+				slog.String("error", err.Error()),
+			)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// staticFileHandler serves files from dir. Missing files are logged at debug level;
+// browsers navigating to one get the styled 404 page, everything else a plain 404.
+func staticFileHandler(dir string) http.Handler {
+	root := http.Dir(dir)
+	fileServer := http.FileServer(root)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, err := root.Open(path.Clean("/" + r.URL.Path))
+		if err != nil {
+			slog.Debug("Static asset not found", slog.String("path", r.URL.Path))
+			if !isNavigation(r) {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(http.StatusNotFound)
+			_ = templates.ErrorPage(http.StatusNotFound, "The file you're looking for doesn't exist.").Render(r.Context(), w)
+			return
+		}
+		_ = f.Close()
+
+		fileServer.ServeHTTP(w, r)
+	})
+}
+
+// isNavigation reports whether the request is a browser loading a page,
+// as opposed to fetching an asset such as a script or stylesheet.
+func isNavigation(r *http.Request) bool {
+	if mode := r.Header.Get("Sec-Fetch-Mode"); mode != "" {
+		return mode == "navigate"
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
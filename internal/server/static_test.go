@@ -0,0 +1,143 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStaticFileHandler(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "css"), 0o755); err != nil {
+		t.Fatalf("creating dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "css", "app.css"), []byte("body{}"), 0o600); err != nil {
+		t.Fatalf("writing file: %v", err)
+	}
+	handler := staticFileHandler(dir)
+
+	tests := []struct {
+		headers    map[string]string
+		name       string
+		path       string
+		wantStatus int
+		wantPage   bool
+	}{
+		{name: "existing file", path: "/css/app.css", wantStatus: http.StatusOK},
+		{
+			name: "missing asset", path: "/css/missing.css",
+			headers:    map[string]string{"Accept": "text/css,*/*;q=0.1"},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "missing asset fetched by script", path: "/js/missing.js",
+			headers:    map[string]string{"Sec-Fetch-Mode": "no-cors", "Accept": "text/html"},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "navigation to missing file", path: "/missing.html",
+			headers:    map[string]string{"Sec-Fetch-Mode": "navigate"},
+			wantStatus: http.StatusNotFound, wantPage: true,
+		},
+		{
+			name: "browser without fetch metadata", path: "/missing.html",
+			headers:    map[string]string{"Accept": "text/html,application/xhtml+xml"},
+			wantStatus: http.StatusNotFound, wantPage: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if isPage := strings.Contains(w.Body.String(), "<!doctype html>"); isPage != tt.wantPage {
+				t.Errorf("error page = %t, want %t:\n%s", isPage, tt.wantPage, w.Body)
+			}
+		})
+	}
+}
+
+// staticWarning is a missing asset warning as logged in JSON.
+type staticWarning struct {
+	Msg   string `json:"msg"`
+	Level string `json:"level"`
+	Asset string `json:"asset"`
+	Dir   string `json:"dir"`
+}
+
+// captureWarnings sends warnings from the default logger to the returned function, which
+// decodes those logged so far.
+func captureWarnings(t *testing.T) func() []staticWarning {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn})))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	return func() []staticWarning {
+		var warnings []staticWarning
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			var warning staticWarning
+			if line != "" && json.Unmarshal([]byte(line), &warning) == nil {
+				warnings = append(warnings, warning)
+			}
+		}
+		return warnings
+	}
+}
+
+func TestCheckStaticAssets(t *testing.T) {
+	warnings := captureWarnings(t)
+	dir := t.TempDir()
+	if checkStaticAssets(dir) {
+		t.Error("checkStaticAssets = true for an empty directory")
+	}
+	logged := warnings()
+	if len(logged) != len(criticalAssets) {
+		t.Fatalf("logged %d warnings, want one per critical asset: %+v", len(logged), logged)
+	}
+	for i, warning := range logged {
+		if warning.Level != "WARN" || warning.Asset != criticalAssets[i] || warning.Dir != dir {
+			t.Errorf("warning = %+v, want one naming %s in %s", warning, criticalAssets[i], dir)
+		}
+	}
+
+	for _, asset := range criticalAssets {
+		path := filepath.Join(dir, filepath.FromSlash(asset))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("creating dir: %v", err)
+		}
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatalf("writing %s: %v", asset, err)
+		}
+	}
+	if !checkStaticAssets(dir) {
+		t.Error("checkStaticAssets = false with every critical asset present")
+	}
+	if n := len(warnings()); n != len(logged) {
+		t.Errorf("logged %d more warnings with every asset present, want none", n-len(logged))
+	}
+}
+
+func TestCheckStaticAssetsInRepository(t *testing.T) {
+	// The server runs from the repository root, where the shipped assets must resolve
+	t.Chdir("../..")
+	if !checkStaticAssets(staticDir) {
+		t.Errorf("critical assets are missing from %s", staticDir)
+	}
+}
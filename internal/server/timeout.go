@@ -0,0 +1,46 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/pavelanni/movie-journal/templates"
+)
+
+// timeoutMessage is shown when a request takes longer than the configured timeout.
+const timeoutMessage = "The server took too long to respond. Please try again."
+
+// withTimeout cuts off requests that run longer than the configured timeout with a 503
+// error page, so a hung handler doesn't hold its connection until the write timeout.
+// HTMX requests get just the message, as it's swapped into the page. A zero timeout
+// disables it. http.TimeoutHandler buffers responses, so static files, which may be
+// large, are served without it, and any streaming route added later must be too.
+func (s *Server) withTimeout(next http.Handler) http.Handler {
+	if s.config.RequestTimeout <= 0 {
+		return next
+	}
+
+	var page bytes.Buffer
+	err := templates.ErrorPage(http.StatusServiceUnavailable, timeoutMessage).Render(context.Background(), &page)
+	if err != nil {
+		slog.Error("Failed to render timeout page", slog.String("error", err.Error()))
+		page.Reset()
+		page.WriteString(timeoutMessage)
+	}
+
+	full := http.TimeoutHandler(next, s.config.RequestTimeout, page.String())
+	fragment := http.TimeoutHandler(next, s.config.RequestTimeout, timeoutMessage)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/static/"):
+			next.ServeHTTP(w, r)
+		case r.Header.Get("HX-Request") == "true":
+			fragment.ServeHTTP(w, r)
+		default:
+			full.ServeHTTP(w, r)
+		}
+	})
+}
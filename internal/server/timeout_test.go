@@ -0,0 +1,68 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithTimeout(t *testing.T) {
+	s := New(Config{RequestTimeout: 20 * time.Millisecond})
+	slow := s.withTimeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(200 * time.Millisecond):
+		}
+		_, _ = w.Write([]byte("finally"))
+	}))
+
+	tests := []struct {
+		name       string
+		path       string
+		wantBody   string
+		wantStatus int
+		htmx       bool
+		wantPage   bool
+	}{
+		{name: "page", path: "/stats", wantStatus: http.StatusServiceUnavailable, wantBody: timeoutMessage, wantPage: true},
+		{name: "htmx", path: "/stats", htmx: true, wantStatus: http.StatusServiceUnavailable, wantBody: timeoutMessage},
+		{name: "static file", path: "/static/css/style.css", wantStatus: http.StatusOK, wantBody: "finally"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.htmx {
+				r.Header.Set("HX-Request", "true")
+			}
+			w := httptest.NewRecorder()
+
+			slow.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			body := w.Body.String()
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("body doesn't contain %q:\n%s", tt.wantBody, body)
+			}
+			if isPage := strings.Contains(body, "<!doctype html>"); isPage != tt.wantPage {
+				t.Errorf("full page = %t, want %t:\n%s", isPage, tt.wantPage, body)
+			}
+		})
+	}
+}
+
+func TestWithTimeoutDisabled(t *testing.T) {
+	s := New(Config{})
+	called := false
+	next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) { called = true })
+
+	s.withTimeout(next).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if !called {
+		t.Error("the handler wasn't called")
+	}
+}
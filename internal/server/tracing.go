@@ -0,0 +1,19 @@
+package server
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// withTracing wraps the handler in a server span per request. Once routing is done the
+// span is renamed after the matched pattern, for example "GET /diary/{id}".
+func withTracing(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, "HTTP request",
+		otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
+			if r.Pattern != "" {
+				return r.Pattern
+			}
+			return operation
+		}))
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pavelanni/movie-journal/internal/database"
+	"github.com/pavelanni/movie-journal/internal/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	spanRecorder        = tracetest.NewSpanRecorder()
+	installSpanRecorder sync.Once
+)
+
+// recordSpans returns the recorder of every span ended. Tracers obtained before the first
+// global provider is set keep using that provider, so it is installed once and stays in
+// place for the rest of the tests.
+func recordSpans() *tracetest.SpanRecorder {
+	installSpanRecorder.Do(func() {
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder)))
+	})
+	return spanRecorder
+}
+
+func TestTracingSpanHierarchy(t *testing.T) {
+	recorder := recordSpans()
+
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	ctx := context.Background()
+	movie, err := db.SaveMovie(ctx, models.Movie{TMDBID: 438631, Title: "Dune", Year: 2021})
+	if err != nil {
+		t.Fatalf("saving movie: %v", err)
+	}
+	id, err := db.CreateDiaryEntry(ctx, models.DiaryEntryInput{
+		MovieID:   movie.ID,
+		WatchedAt: time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("creating entry: %v", err)
+	}
+
+	// Only the spans of the request itself are of interest
+	before := len(recorder.Ended())
+
+	s := New(Config{DB: db})
+	r := httptest.NewRequest(http.MethodGet, "/diary/"+strconv.FormatInt(id, 10), nil)
+	w := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	spans := recorder.Ended()[before:]
+	var request sdktrace.ReadOnlySpan
+	for _, span := range spans {
+		if span.SpanKind() == trace.SpanKindServer {
+			request = span
+		}
+	}
+	if request == nil {
+		t.Fatal("no span for the HTTP request")
+	}
+	if request.Name() != "GET /diary/{id}" {
+		t.Errorf("request span is named %q, want the route pattern", request.Name())
+	}
+	if request.Parent().IsValid() {
+		t.Error("request span has a parent, want it to be the root")
+	}
+
+	var queries int
+	for _, span := range spans {
+		if !hasAttribute(span, attribute.String("db.system.name", "sqlite")) {
+			continue
+		}
+		queries++
+		if span.SpanContext().TraceID() != request.SpanContext().TraceID() {
+			t.Errorf("query span %q is in another trace", span.Name())
+		}
+		if span.Parent().SpanID() != request.SpanContext().SpanID() {
+			t.Errorf("query span %q isn't a child of the request span", span.Name())
+		}
+		if span.SpanKind() != trace.SpanKindClient {
+			t.Errorf("query span %q has kind %v, want client", span.Name(), span.SpanKind())
+		}
+	}
+	if queries == 0 {
+		t.Error("the request made no query spans")
+	}
+}
+
+// hasAttribute reports whether the span has the attribute with the same value.
+func hasAttribute(span sdktrace.ReadOnlySpan, want attribute.KeyValue) bool {
+	for _, attr := range span.Attributes() {
+		if attr == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,249 @@
+// Package tmdb provides a minimal client for The Movie Database (TMDB) API.
+package tmdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pavelanni/movie-journal/internal/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// defaultBaseURL is the TMDB v3 API endpoint.
+	defaultBaseURL = "https://api.themoviedb.org/3"
+	// posterBaseURL serves posters at the w185 size used by the cards.
+	posterBaseURL = "https://image.tmdb.org/t/p/w185"
+)
+
+// tracer creates spans for TMDB calls. It's a no-op unless tracing is configured.
+var tracer = otel.Tracer("github.com/pavelanni/movie-journal/internal/tmdb")
+
+// Client calls the TMDB API.
+type Client struct {
+	httpClient  *http.Client
+	apiKey      string
+	baseURL     string
+	maxAttempts int
+	baseDelay   time.Duration
+}
+
+// NewClient creates a TMDB client authenticating with the given API key.
+func NewClient(apiKey string, opts ...Option) *Client {
+	c := &Client{
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		apiKey:      apiKey,
+		baseURL:     defaultBaseURL,
+		maxAttempts: defaultMaxAttempts,
+		baseDelay:   defaultBaseDelay,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithBaseURL sends requests to another API endpoint than TMDB's, such as a test server.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = baseURL
+	}
+}
+
+// movieResult is a movie as returned by TMDB list endpoints.
+type movieResult struct {
+	Title       string `json:"title"`
+	ReleaseDate string `json:"release_date"`
+	PosterPath  string `json:"poster_path"`
+	Overview    string `json:"overview"`
+	ID          int    `json:"id"`
+}
+
+// toMovie converts a TMDB result into a Movie.
+func (r movieResult) toMovie() models.Movie {
+	movie := models.Movie{
+		TMDBID:   r.ID,
+		Title:    r.Title,
+		Overview: r.Overview,
+	}
+	if len(r.ReleaseDate) >= 4 {
+		movie.Year, _ = strconv.Atoi(r.ReleaseDate[:4])
+	}
+	if r.PosterPath != "" {
+		movie.PosterURL = posterBaseURL + r.PosterPath
+	}
+	return movie
+}
+
+// SearchMovies searches TMDB for movies matching the query.
+func (c *Client) SearchMovies(ctx context.Context, query string) ([]models.Movie, error) {
+	var resp struct {
+		Results []movieResult `json:"results"`
+	}
+	if err := c.get(ctx, "/search/movie", url.Values{"query": {query}}, &resp); err != nil {
+		return nil, err
+	}
+
+	movies := make([]models.Movie, 0, len(resp.Results))
+	for _, r := range resp.Results {
+		movies = append(movies, r.toMovie())
+	}
+	return movies, nil
+}
+
+// MoviePage is one page of a TMDB movie list. Pages count from 1.
+type MoviePage struct {
+	Movies     []models.Movie
+	Page       int
+	TotalPages int
+}
+
+// Popular returns the given page of the movies currently popular on TMDB.
+func (c *Client) Popular(ctx context.Context, page int) (*MoviePage, error) {
+	var resp struct {
+		Results    []movieResult `json:"results"`
+		Page       int           `json:"page"`
+		TotalPages int           `json:"total_pages"`
+	}
+	if err := c.get(ctx, "/movie/popular", url.Values{"page": {strconv.Itoa(page)}}, &resp); err != nil {
+		return nil, err
+	}
+
+	movies := make([]models.Movie, 0, len(resp.Results))
+	for _, r := range resp.Results {
+		movies = append(movies, r.toMovie())
+	}
+	return &MoviePage{Movies: movies, Page: resp.Page, TotalPages: resp.TotalPages}, nil
+}
+
+// ExternalIDs holds a movie's IDs on other sites. Unknown IDs are empty.
+type ExternalIDs struct {
+	IMDbID string `json:"imdb_id"`
+}
+
+// GetExternalIDs returns the IDs other sites use for the movie with the given TMDB ID.
+func (c *Client) GetExternalIDs(ctx context.Context, tmdbID int) (ExternalIDs, error) {
+	var ids ExternalIDs
+	if err := c.get(ctx, fmt.Sprintf("/movie/%d/external_ids", tmdbID), url.Values{}, &ids); err != nil {
+		return ExternalIDs{}, err
+	}
+	return ids, nil
+}
+
+// MovieDetails holds the details of a movie that its list entries leave out. Unknown
+// details are empty.
+type MovieDetails struct {
+	Tagline string `json:"tagline"`
+}
+
+// GetMovie returns the details of the movie with the given TMDB ID.
+func (c *Client) GetMovie(ctx context.Context, tmdbID int) (MovieDetails, error) {
+	var details MovieDetails
+	if err := c.get(ctx, fmt.Sprintf("/movie/%d", tmdbID), url.Values{}, &details); err != nil {
+		return MovieDetails{}, err
+	}
+	return details, nil
+}
+
+// GetKeywords returns the keyword tags of the movie with the given TMDB ID, such as
+// "time travel", in TMDB's order.
+func (c *Client) GetKeywords(ctx context.Context, tmdbID int) ([]string, error) {
+	var resp struct {
+		Keywords []struct {
+			Name string `json:"name"`
+		} `json:"keywords"`
+	}
+	if err := c.get(ctx, fmt.Sprintf("/movie/%d/keywords", tmdbID), url.Values{}, &resp); err != nil {
+		return nil, err
+	}
+
+	keywords := make([]string, 0, len(resp.Keywords))
+	for _, k := range resp.Keywords {
+		keywords = append(keywords, k.Name)
+	}
+	return keywords, nil
+}
+
+// get performs a GET request against the API and decodes the JSON response into out.
+// Rate-limited and transient server errors are retried with exponential backoff.
+func (c *Client) get(ctx context.Context, path string, params url.Values, out any) (err error) {
+	// The span records the endpoint only; the request URL carries the API key
+	ctx, span := tracer.Start(ctx, "tmdb "+path,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("tmdb.endpoint", path)))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	params.Set("api_key", c.apiKey)
+
+	for attempt := 1; ; attempt++ {
+		span.SetAttributes(attribute.Int("tmdb.attempts", attempt))
+
+		var retryAfter time.Duration
+		var retryable bool
+		retryAfter, retryable, err = c.getOnce(ctx, path, params, out)
+		if err == nil || !retryable || attempt >= c.maxAttempts {
+			return err
+		}
+
+		delay := max(c.backoff(attempt), retryAfter)
+		slog.Debug("Retrying TMDB request",
+			slog.String("path", path),
+			slog.Int("attempt", attempt),
+			slog.Duration("delay", delay),
+			slog.String("error", err.Error()))
+		if sleepErr := sleep(ctx, delay); sleepErr != nil {
+			return fmt.Errorf("requesting %s: %w", path, sleepErr)
+		}
+	}
+}
+
+// getOnce makes a single request attempt. It reports whether a failure may be retried
+// and, for rate-limited responses, how long the server asked us to wait.
+func (c *Client) getOnce(ctx context.Context, path string, params url.Values, out any) (time.Duration, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path+"?"+params.Encode(), http.NoBody)
+	if err != nil {
+		return 0, false, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		// Unwrap url.Error so the API key in the request URL doesn't end up in logs
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return 0, ctx.Err() == nil, fmt.Errorf("requesting %s: %w", path, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		var retryAfter time.Duration
+		if resp.StatusCode == http.StatusTooManyRequests {
+			retryAfter, _ = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		}
+		return retryAfter, retryableStatus(resp.StatusCode),
+			fmt.Errorf("requesting %s: unexpected status %s", path, resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return 0, false, fmt.Errorf("decoding %s response: %w", path, err)
+	}
+	return 0, false, nil
+}
@@ -0,0 +1,117 @@
+package tmdb
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestGetExternalIDs(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{name: "known", body: `{"id":438631,"imdb_id":"tt1160419","wikidata_id":"Q63985561"}`, want: "tt1160419"},
+		{name: "unknown", body: `{"id":438631,"imdb_id":null}`, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/movie/438631/external_ids" {
+					t.Errorf("path = %q, want /movie/438631/external_ids", r.URL.Path)
+				}
+				if key := r.URL.Query().Get("api_key"); key != "test-key" {
+					t.Errorf("api_key = %q, want test-key", key)
+				}
+				_, _ = w.Write([]byte(tt.body))
+			})
+
+			ids, err := c.GetExternalIDs(context.Background(), 438631)
+			if err != nil {
+				t.Fatalf("GetExternalIDs: %v", err)
+			}
+			if ids.IMDbID != tt.want {
+				t.Errorf("IMDbID = %q, want %q", ids.IMDbID, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetExternalIDsNotFound(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, `{"status_code":34}`, http.StatusNotFound)
+	})
+
+	if _, err := c.GetExternalIDs(context.Background(), 1); err == nil {
+		t.Error("GetExternalIDs succeeded for a movie TMDB doesn't know")
+	}
+}
+
+func TestGetMovie(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{name: "with tagline", body: `{"id":438631,"title":"Dune","tagline":"Beyond fear, destiny awaits."}`, want: "Beyond fear, destiny awaits."},
+		{name: "empty tagline", body: `{"id":438631,"title":"Dune","tagline":""}`, want: ""},
+		{name: "no tagline", body: `{"id":438631,"title":"Dune"}`, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/movie/438631" {
+					t.Errorf("path = %q, want /movie/438631", r.URL.Path)
+				}
+				_, _ = w.Write([]byte(tt.body))
+			})
+
+			details, err := c.GetMovie(context.Background(), 438631)
+			if err != nil {
+				t.Fatalf("GetMovie: %v", err)
+			}
+			if details.Tagline != tt.want {
+				t.Errorf("Tagline = %q, want %q", details.Tagline, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetKeywords(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{
+			name: "keywords",
+			body: `{"id":438631,"keywords":[{"id":1,"name":"desert"},{"id":2,"name":"based on novel or book"}]}`,
+			want: []string{"desert", "based on novel or book"},
+		},
+		{name: "none", body: `{"id":438631,"keywords":[]}`, want: []string{}},
+		{name: "missing", body: `{"id":438631}`, want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/movie/438631/keywords" {
+					t.Errorf("path = %q, want /movie/438631/keywords", r.URL.Path)
+				}
+				_, _ = w.Write([]byte(tt.body))
+			})
+
+			keywords, err := c.GetKeywords(context.Background(), 438631)
+			if err != nil {
+				t.Fatalf("GetKeywords: %v", err)
+			}
+			if keywords == nil || !slices.Equal(keywords, tt.want) {
+				t.Errorf("keywords = %#v, want %#v", keywords, tt.want)
+			}
+		})
+	}
+}
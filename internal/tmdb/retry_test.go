@@ -0,0 +1,100 @@
+package tmdb
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client for a fake TMDB API served by handler, retrying with a
+// negligible backoff.
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	c := NewClient("test-key", WithRetry(3, time.Millisecond))
+	c.baseURL = server.URL
+	return c
+}
+
+func TestGetRetriesTransientFailures(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			_, _ = w.Write([]byte(`{"results":[{"id":438631,"title":"Dune","release_date":"2021-09-15"}]}`))
+		}
+	})
+
+	start := time.Now()
+	movies, err := c.SearchMovies(context.Background(), "Dune")
+	if err != nil {
+		t.Fatalf("SearchMovies: %v", err)
+	}
+	if len(movies) != 1 || movies[0].Title != "Dune" || movies[0].Year != 2021 {
+		t.Errorf("movies = %+v, want Dune (2021)", movies)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("made %d requests, want 3", n)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, want at least the 1s Retry-After", elapsed)
+	}
+}
+
+func TestGetGivesUp(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		wantCalls int32
+	}{
+		{name: "retryable status uses every attempt", status: http.StatusBadGateway, wantCalls: 3},
+		{name: "client error isn't retried", status: http.StatusNotFound, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			c := newTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+				calls.Add(1)
+				w.WriteHeader(tt.status)
+			})
+
+			if _, err := c.SearchMovies(context.Background(), "Dune"); err == nil {
+				t.Fatal("SearchMovies succeeded, want an error")
+			}
+			if n := calls.Load(); n != tt.wantCalls {
+				t.Errorf("made %d requests, want %d", n, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{value: "", want: 0, wantOK: false},
+		{value: "5", want: 5 * time.Second, wantOK: true},
+		{value: "-1", want: 0, wantOK: false},
+		{value: now.Add(30 * time.Second).Format(http.TimeFormat), want: 30 * time.Second, wantOK: true},
+		{value: now.Add(-time.Minute).Format(http.TimeFormat), want: 0, wantOK: true},
+		{value: "soon", want: 0, wantOK: false},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parseRetryAfter(%q) = %s, %v, want %s, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
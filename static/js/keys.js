@@ -0,0 +1,13 @@
+// Keyboard shortcuts for HTMX triggers. Trigger filters such as keyup[key=='Escape'] are
+// evaluated as JavaScript, which the Content-Security-Policy forbids, so keys are turned
+// into plain events here instead: pressing Escape fires "escapePressed" on the body,
+// for use as hx-trigger="escapePressed from:body".
+(function () {
+    "use strict";
+
+    document.addEventListener("keyup", function (event) {
+        if (event.key === "Escape") {
+            document.body.dispatchEvent(new CustomEvent("escapePressed", { bubbles: true }));
+        }
+    });
+})();
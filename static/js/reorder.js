@@ -0,0 +1,79 @@
+// Drag-and-drop reordering of lists rendered by the SortableLookups template.
+// Items carry data-lookup-id and are dragged by their data-drag-handle. When an item
+// is dropped in a new place, the new order is posted as repeated "lookup" values to the
+// data-reorder-url of the list, and the list is replaced with the response.
+(function () {
+    "use strict";
+
+    let dragged = null;
+    let startOrder = "";
+
+    function order(list) {
+        return Array.from(list.querySelectorAll(":scope > [data-lookup-id]"), function (item) {
+            return item.dataset.lookupId;
+        });
+    }
+
+    // Only the handle starts a drag, so text in the item stays selectable.
+    document.addEventListener("pointerdown", function (event) {
+        const handle = event.target.closest("[data-drag-handle]");
+        if (handle) {
+            handle.closest("[data-lookup-id]").draggable = true;
+        }
+    });
+
+    document.addEventListener("dragstart", function (event) {
+        const item = event.target.closest && event.target.closest("[data-lookup-id]");
+        if (!item || !item.draggable) {
+            return;
+        }
+        dragged = item;
+        startOrder = order(item.parentElement).join(",");
+        event.dataTransfer.effectAllowed = "move";
+        event.dataTransfer.setData("text/plain", item.dataset.lookupId);
+    });
+
+    document.addEventListener("dragover", function (event) {
+        if (!dragged) {
+            return;
+        }
+        const target = event.target.closest("[data-lookup-id]");
+        if (!target || target === dragged || target.parentElement !== dragged.parentElement) {
+            return;
+        }
+        event.preventDefault();
+        const box = target.getBoundingClientRect();
+        if (event.clientY < box.top + box.height / 2) {
+            target.before(dragged);
+        } else {
+            target.after(dragged);
+        }
+    });
+
+    document.addEventListener("drop", function (event) {
+        if (dragged) {
+            event.preventDefault();
+        }
+    });
+
+    document.addEventListener("dragend", function () {
+        if (!dragged) {
+            return;
+        }
+        const item = dragged;
+        dragged = null;
+        item.draggable = false;
+
+        const list = item.parentElement;
+        const ids = order(list);
+        if (ids.join(",") === startOrder || !list.dataset.reorderUrl) {
+            return;
+        }
+        htmx.ajax("POST", list.dataset.reorderUrl, {
+            source: list,
+            target: list,
+            swap: "innerHTML",
+            values: { lookup: ids },
+        });
+    });
+})();
@@ -0,0 +1,83 @@
+package templates
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// dateFormats maps the names accepted by --date-format to their layouts.
+var dateFormats = map[string]string{
+	"iso":   "2006-01-02",
+	"short": "Jan 2, 2006",
+	"long":  "January 2, 2006",
+	"dmy":   "02/01/2006",
+	"mdy":   "01/02/2006",
+}
+
+// dateFormatKey is the context key for the configured date layout.
+type dateFormatKey struct{}
+
+// DateFormatLayout returns the layout for a named date format.
+func DateFormatLayout(name string) (string, error) {
+	layout, ok := dateFormats[name]
+	if !ok {
+		names := make([]string, 0, len(dateFormats))
+		for n := range dateFormats {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("unknown date format %q (allowed: %s)", name, strings.Join(names, ", "))
+	}
+	return layout, nil
+}
+
+// WithDateFormat returns a context that makes templates display dates with layout.
+func WithDateFormat(ctx context.Context, layout string) context.Context {
+	return context.WithValue(ctx, dateFormatKey{}, layout)
+}
+
+// formatDate formats t with the date layout configured in ctx, or with fallback if there is none.
+func formatDate(ctx context.Context, t time.Time, fallback string) string {
+	if layout, ok := ctx.Value(dateFormatKey{}).(string); ok && layout != "" {
+		return t.Format(layout)
+	}
+	return t.Format(fallback)
+}
+
+// relativeDateDays is how many days back a date is still described relative to today.
+const relativeDateDays = 28
+
+// relativeDate describes the calendar day of t relative to the day of now, such as
+// "today", "3 days ago" or "last week". It returns "" for days in the future or
+// relativeDateDays or more ago, which read better as dates.
+func relativeDate(t, now time.Time) string {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	days := int(today.Sub(day).Hours() / 24)
+	switch {
+	case days < 0 || days >= relativeDateDays:
+		return ""
+	case days == 0:
+		return "today"
+	case days == 1:
+		return "yesterday"
+	case days < 7:
+		return fmt.Sprintf("%d days ago", days)
+	case days < 14:
+		return "last week"
+	default:
+		return fmt.Sprintf("%d weeks ago", days/7)
+	}
+}
+
+// cardDate formats a watched date for an entry card: relative to today when recent, and
+// otherwise with the date layout configured in ctx.
+func cardDate(ctx context.Context, t time.Time) string {
+	if relative := relativeDate(t, time.Now()); relative != "" {
+		return relative
+	}
+	return formatDate(ctx, t, "Jan 2, 2006")
+}
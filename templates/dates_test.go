@@ -0,0 +1,79 @@
+package templates
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRelativeDate(t *testing.T) {
+	now := time.Date(2024, time.June, 15, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		watched time.Time
+		name    string
+		want    string
+	}{
+		{name: "today", watched: time.Date(2024, time.June, 15, 0, 0, 0, 0, time.UTC), want: "today"},
+		{name: "yesterday", watched: time.Date(2024, time.June, 14, 0, 0, 0, 0, time.UTC), want: "yesterday"},
+		{name: "a few days ago", watched: time.Date(2024, time.June, 12, 0, 0, 0, 0, time.UTC), want: "3 days ago"},
+		{name: "a week ago", watched: time.Date(2024, time.June, 8, 0, 0, 0, 0, time.UTC), want: "last week"},
+		{name: "two weeks ago", watched: time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC), want: "2 weeks ago"},
+		{name: "a month ago", watched: time.Date(2024, time.May, 15, 0, 0, 0, 0, time.UTC), want: ""},
+		{name: "tomorrow", watched: time.Date(2024, time.June, 16, 0, 0, 0, 0, time.UTC), want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := relativeDate(tt.watched, now); got != tt.want {
+				t.Errorf("relativeDate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDateFormatLayout(t *testing.T) {
+	watched := time.Date(2024, time.March, 7, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "iso", want: "2024-03-07"},
+		{name: "short", want: "Mar 7, 2024"},
+		{name: "long", want: "March 7, 2024"},
+		{name: "dmy", want: "07/03/2024"},
+		{name: "mdy", want: "03/07/2024"},
+	}
+	if len(tests) != len(dateFormats) {
+		t.Fatalf("testing %d formats, but %d are allowed", len(tests), len(dateFormats))
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layout, err := DateFormatLayout(tt.name)
+			if err != nil {
+				t.Fatalf("DateFormatLayout(%q): %v", tt.name, err)
+			}
+			ctx := WithDateFormat(context.Background(), layout)
+			if got := formatDate(ctx, watched, "2006-01-02"); got != tt.want {
+				t.Errorf("formatDate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDateFormatLayoutUnknown(t *testing.T) {
+	_, err := DateFormatLayout("02.01.2006")
+	if err == nil {
+		t.Fatal("DateFormatLayout accepted an unknown format")
+	}
+	// The error lists the allowed names so the user can fix the flag
+	if !strings.Contains(err.Error(), "allowed: dmy, iso, long, mdy, short") {
+		t.Errorf("error = %q, want the allowed formats", err)
+	}
+}
+
+func TestFormatDateFallback(t *testing.T) {
+	watched := time.Date(2024, time.March, 7, 0, 0, 0, 0, time.UTC)
+	if got := formatDate(context.Background(), watched, "Jan 2, 2006"); got != "Mar 7, 2024" {
+		t.Errorf("formatDate() without a configured format = %q, want the fallback layout", got)
+	}
+}
@@ -0,0 +1,28 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+// evalAttribute matches HTMX attributes that only work with eval, which the default
+// Content-Security-Policy forbids: trigger filters, js: values and hx-on handlers.
+var evalAttribute = regexp.MustCompile(`hx-trigger="[^"]*\[|hx-vals='?"?(js|javascript):|hx-on[:-]`)
+
+func TestTemplatesAvoidEval(t *testing.T) {
+	files, err := filepath.Glob("*.templ")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("finding templates: %v", err)
+	}
+	for _, file := range files {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("reading %s: %v", file, err)
+		}
+		for _, match := range evalAttribute.FindAll(source, -1) {
+			t.Errorf("%s: %s needs eval, which the Content-Security-Policy forbids", file, match)
+		}
+	}
+}
@@ -1,7 +1,15 @@
 // Package templates provides template helpers and rendering utilities for the Movie Journal application.
 package templates
 
-import "github.com/pavelanni/movie-journal/internal/models"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pavelanni/movie-journal/internal/models"
+)
 
 func getWatchedDate(entry *models.DiaryEntry) string {
 	if entry != nil {
@@ -11,7 +19,7 @@ func getWatchedDate(entry *models.DiaryEntry) string {
 }
 
 func getMovieTitle(entry *models.DiaryEntry) string {
-	if entry != nil {
+	if entry != nil && entry.Movie != nil {
 		return entry.Movie.Title
 	}
 	return ""
@@ -24,6 +32,13 @@ func getWatchedLocation(entry *models.DiaryEntry) string {
 	return ""
 }
 
+func getFormat(entry *models.DiaryEntry) string {
+	if entry != nil {
+		return entry.Format
+	}
+	return ""
+}
+
 func getWatchedWith(entry *models.DiaryEntry) string {
 	if entry != nil {
 		return entry.WatchedWith
@@ -38,13 +53,147 @@ func getNotes(entry *models.DiaryEntry) string {
 	return ""
 }
 
-func getStarClass(rating int) string {
+// entryVersion returns when the entry was last changed, in Unix nanoseconds, for the edit
+// form to send back. It's empty for an entry that hasn't been saved.
+func entryVersion(entry *models.DiaryEntry) string {
+	if entry == nil || entry.UpdatedAt.IsZero() {
+		return ""
+	}
+	return strconv.FormatInt(entry.UpdatedAt.UnixNano(), 10)
+}
+
+// isLinkableURL reports whether a lookup URL is safe to render as a link.
+func isLinkableURL(raw string) bool {
+	return raw != "" && models.ValidateLookupURL(raw) == nil
+}
+
+// isAnswered reports whether a lookup has an answer; whitespace doesn't count.
+func isAnswered(lookup models.Lookup) bool {
+	return strings.TrimSpace(lookup.Answer) != ""
+}
+
+// countUnanswered returns how many of the lookups have no answer yet.
+func countUnanswered(lookups []models.Lookup) int {
+	n := 0
+	for _, l := range lookups {
+		if !isAnswered(l) {
+			n++
+		}
+	}
+	return n
+}
+
+// formatYear returns the release year, or "Year unknown" for movies stored without one.
+func formatYear(year int) string {
+	if year == 0 {
+		return "Year unknown"
+	}
+	return strconv.Itoa(year)
+}
+
+// placeholderPosterURL returns the image shown for movies without a poster.
+func placeholderPosterURL() string {
+	return "/static/img/poster-placeholder.svg"
+}
+
+// posterURL returns the movie's poster, or the placeholder when it has none.
+func posterURL(movie *models.Movie) string {
+	if movie == nil || movie.PosterURL == "" {
+		return placeholderPosterURL()
+	}
+	return movie.PosterURL
+}
+
+// externalLink is a link to a movie's page on another site.
+type externalLink struct {
+	Label string
+	URL   string
+}
+
+// isIMDbID reports whether id looks like an IMDb title ID, "tt" followed by digits.
+func isIMDbID(id string) bool {
+	digits, ok := strings.CutPrefix(id, "tt")
+	if !ok || digits == "" {
+		return false
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// externalLinks returns links to the movie's IMDb and Letterboxd pages, built from its
+// IMDb ID. It returns none when the ID is unknown or malformed.
+func externalLinks(movie *models.Movie) []externalLink {
+	if !isIMDbID(movie.IMDbID) {
+		return nil
+	}
+	return []externalLink{
+		{Label: "IMDb", URL: "https://www.imdb.com/title/" + movie.IMDbID + "/"},
+		{Label: "Letterboxd", URL: "https://letterboxd.com/imdb/" + movie.IMDbID + "/"},
+	}
+}
+
+// movieMeta joins a movie's year, director, and genre with dots, skipping missing ones.
+func movieMeta(movie *models.Movie) string {
+	parts := []string{formatYear(movie.Year)}
+	for _, part := range []string{movie.Director, movie.Genre} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, " · ")
+}
+
+// notesPreviewLength is the number of characters of notes shown on an entry card.
+const notesPreviewLength = 200
+
+// truncateRunes shortens s to at most n runes plus an ellipsis, preferring to cut at a space,
+// and reports whether anything was cut. Counting runes keeps multi-byte characters whole.
+func truncateRunes(s string, n int) (string, bool) {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s, false
+	}
+	cut := runes[:n]
+	if i := lastSpace(cut); i > n/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRightFunc(string(cut), unicode.IsSpace) + "…", true
+}
+
+// lastSpace returns the index of the last whitespace rune, or -1 if there is none.
+func lastSpace(runes []rune) int {
+	for i := len(runes) - 1; i >= 0; i-- {
+		if unicode.IsSpace(runes[i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// pluralize formats a count with the singular form of its unit when the count is one
+// and the plural form otherwise, e.g. "1 lookup" but "0 lookups".
+func pluralize(n int, singular, plural string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, singular)
+	}
+	return fmt.Sprintf("%d %s", n, plural)
+}
+
+// timeAgo describes how long before now t was, in whole years, months, or days.
+func timeAgo(t, now time.Time) string {
+	days := int(now.Sub(t).Hours() / 24)
 	switch {
-	case rating >= 4:
-		return "w-4 h-4 text-green-400"
-	case rating == 3:
-		return "w-4 h-4 text-yellow-400"
+	case days >= 365:
+		return pluralize(days/365, "year", "years") + " ago"
+	case days >= 30:
+		return pluralize(days/30, "month", "months") + " ago"
+	case days >= 1:
+		return pluralize(days, "day", "days") + " ago"
 	default:
-		return "w-4 h-4 text-red-400"
+		return "today"
 	}
 }
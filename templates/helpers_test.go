@@ -0,0 +1,244 @@
+package templates
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/a-h/templ"
+	"github.com/pavelanni/movie-journal/internal/models"
+)
+
+func TestPluralize(t *testing.T) {
+	tests := []struct {
+		want string
+		n    int
+	}{
+		{n: 0, want: "0 films"},
+		{n: 1, want: "1 film"},
+		{n: 2, want: "2 films"},
+		{n: 25, want: "25 films"},
+	}
+	for _, tt := range tests {
+		if got := pluralize(tt.n, "film", "films"); got != tt.want {
+			t.Errorf("pluralize(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestTruncateRunes(t *testing.T) {
+	tests := []struct {
+		name     string
+		s        string
+		want     string
+		n        int
+		wantTrim bool
+	}{
+		{name: "short", s: "Spice", n: 10, want: "Spice"},
+		{name: "exact length", s: "Spice", n: 5, want: "Spice"},
+		{name: "cut at a space", s: "The spice must flow", n: 12, want: "The spice…", wantTrim: true},
+		{name: "no space to cut at", s: "Supercalifragilistic", n: 5, want: "Super…", wantTrim: true},
+		{name: "space too early", s: "A longwordwithoutbreaks", n: 10, want: "A longword…", wantTrim: true},
+		// Each of these is several bytes, so a byte-based cut would split a character
+		{name: "accented", s: "Amélie était là", n: 8, want: "Amélie…", wantTrim: true},
+		{name: "cjk", s: "千と千尋の神隠し", n: 3, want: "千と千…", wantTrim: true},
+		{name: "emoji", s: "🎬🍿🎥🎞️", n: 2, want: "🎬🍿…", wantTrim: true},
+		{name: "empty", s: "", n: 5, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, truncated := truncateRunes(tt.s, tt.n)
+			if got != tt.want || truncated != tt.wantTrim {
+				t.Errorf("truncateRunes(%q, %d) = %q, %t, want %q, %t", tt.s, tt.n, got, truncated, tt.want, tt.wantTrim)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("truncateRunes(%q, %d) = %q is not valid UTF-8", tt.s, tt.n, got)
+			}
+		})
+	}
+}
+
+func TestTruncateRunesPreviewLength(t *testing.T) {
+	notes := strings.Repeat("ß", notesPreviewLength+1)
+
+	got, truncated := truncateRunes(notes, notesPreviewLength)
+
+	if !truncated {
+		t.Fatal("notes one character over the preview length weren't truncated")
+	}
+	if n := utf8.RuneCountInString(got); n != notesPreviewLength+1 {
+		t.Errorf("preview is %d runes, want %d plus the ellipsis", n, notesPreviewLength)
+	}
+}
+
+func TestFormatYear(t *testing.T) {
+	tests := []struct {
+		want string
+		year int
+	}{
+		{year: 2021, want: "2021"},
+		{year: 1927, want: "1927"},
+		{year: 0, want: "Year unknown"},
+	}
+	for _, tt := range tests {
+		if got := formatYear(tt.year); got != tt.want {
+			t.Errorf("formatYear(%d) = %q, want %q", tt.year, got, tt.want)
+		}
+	}
+}
+
+func TestMovieMeta(t *testing.T) {
+	tests := []struct {
+		want  string
+		movie models.Movie
+	}{
+		{movie: models.Movie{Year: 2021, Director: "Denis Villeneuve", Genre: "Science Fiction"}, want: "2021 · Denis Villeneuve · Science Fiction"},
+		{movie: models.Movie{Year: 2021}, want: "2021"},
+		{movie: models.Movie{Director: "Denis Villeneuve"}, want: "Year unknown · Denis Villeneuve"},
+	}
+	for _, tt := range tests {
+		if got := movieMeta(&tt.movie); got != tt.want {
+			t.Errorf("movieMeta(%+v) = %q, want %q", tt.movie, got, tt.want)
+		}
+	}
+}
+
+func TestUnknownYearRendering(t *testing.T) {
+	entry := models.DiaryEntry{
+		ID:          1,
+		Movie:       &models.Movie{ID: 1, Title: "Untitled Project"},
+		WatchedDate: time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC),
+		Rating:      3,
+	}
+	components := map[string]templ.Component{
+		"card":    MovieCard(entry),
+		"row":     MovieRow(entry),
+		"details": MovieDetails(entry, 0, 0),
+	}
+	for name, component := range components {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := component.Render(context.Background(), &buf); err != nil {
+				t.Fatalf("rendering: %v", err)
+			}
+			html := buf.String()
+			if strings.Contains(html, "(0)") || strings.Contains(html, ">0<") {
+				t.Errorf("a zero year is rendered as 0:\n%s", html)
+			}
+			if !strings.Contains(html, "Year unknown") {
+				t.Errorf("the unknown year isn't labeled:\n%s", html)
+			}
+		})
+	}
+}
+
+func TestExternalLinks(t *testing.T) {
+	tests := []struct {
+		imdbID string
+		want   []externalLink
+	}{
+		{imdbID: "tt1160419", want: []externalLink{
+			{Label: "IMDb", URL: "https://www.imdb.com/title/tt1160419/"},
+			{Label: "Letterboxd", URL: "https://letterboxd.com/imdb/tt1160419/"},
+		}},
+		{imdbID: ""},
+		{imdbID: "tt"},
+		{imdbID: "nm0898288"},
+		{imdbID: "tt116/../x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.imdbID, func(t *testing.T) {
+			got := externalLinks(&models.Movie{IMDbID: tt.imdbID})
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("externalLinks(%q) = %v, want %v", tt.imdbID, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoviePageExternalLinks(t *testing.T) {
+	tests := []struct {
+		name   string
+		imdbID string
+		want   bool
+	}{
+		{name: "known IMDb ID", imdbID: "tt1160419", want: true},
+		{name: "missing IMDb ID"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			movie := models.Movie{ID: 1, Title: "Dune", Year: 2021, IMDbID: tt.imdbID}
+			var buf bytes.Buffer
+			if err := MoviePage(movie, nil, nil).Render(context.Background(), &buf); err != nil {
+				t.Fatalf("rendering: %v", err)
+			}
+			html := buf.String()
+			for _, link := range []string{"https://www.imdb.com/title/", "https://letterboxd.com/imdb/"} {
+				if got := strings.Contains(html, link); got != tt.want {
+					t.Errorf("page links to %s: %t, want %t", link, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestIsAnswered(t *testing.T) {
+	tests := []struct {
+		answer string
+		want   bool
+	}{
+		{answer: "Wadi Rum", want: true},
+		{answer: "  Wadi Rum  ", want: true},
+		{answer: ""},
+		{answer: "   "},
+		{answer: "\t\n"},
+	}
+	for _, tt := range tests {
+		if got := isAnswered(models.Lookup{Answer: tt.answer}); got != tt.want {
+			t.Errorf("isAnswered(%q) = %t, want %t", tt.answer, got, tt.want)
+		}
+	}
+
+	lookups := []models.Lookup{{Answer: "Wadi Rum"}, {}, {Answer: " "}, {Answer: "Zendaya"}}
+	if got := countUnanswered(lookups); got != 2 {
+		t.Errorf("countUnanswered = %d, want 2", got)
+	}
+}
+
+func TestEditableLookupUnanswered(t *testing.T) {
+	tests := []struct {
+		name       string
+		answer     string
+		wantButton string
+		wantStyle  string
+		unanswered bool
+	}{
+		{name: "answered", answer: "Wadi Rum", wantButton: "Edit", wantStyle: "bg-blue-50"},
+		{name: "unanswered", answer: "", wantButton: "Answer", wantStyle: "border-dashed", unanswered: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			lookup := models.Lookup{ID: 7, Question: "Where was Arrakis filmed?", Answer: tt.answer}
+			if err := EditableLookup(lookup).Render(context.Background(), &buf); err != nil {
+				t.Fatalf("rendering: %v", err)
+			}
+			html := buf.String()
+
+			if !strings.Contains(html, tt.wantStyle) {
+				t.Errorf("lookup isn't styled with %s:\n%s", tt.wantStyle, html)
+			}
+			if got := strings.Contains(html, "Not answered yet"); got != tt.unanswered {
+				t.Errorf("says it's not answered yet: %t, want %t", got, tt.unanswered)
+			}
+			if button := html[strings.Index(html, "<button"):]; !strings.Contains(button, tt.wantButton) {
+				t.Errorf("button doesn't read %s:\n%s", tt.wantButton, button)
+			}
+		})
+	}
+}
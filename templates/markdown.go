@@ -0,0 +1,42 @@
+package templates
+
+import (
+	"bytes"
+	"context"
+	"html"
+
+	"github.com/yuin/goldmark"
+	gmhtml "github.com/yuin/goldmark/renderer/html"
+)
+
+// notesMarkdown converts notes from Markdown. Raw HTML in the notes isn't passed through
+// but replaced with a comment, and links and images with dangerous schemes such as
+// javascript: lose their URL, so the output carries no scripts or event handlers.
+var notesMarkdown = goldmark.New(
+	goldmark.WithRendererOptions(gmhtml.WithHardWraps()),
+)
+
+// notesMarkdownKey is the context key for the notes Markdown mode.
+type notesMarkdownKey struct{}
+
+// WithNotesMarkdown returns a context that makes detail views render entry notes as
+// Markdown. Cards and lists keep showing notes as plain text.
+func WithNotesMarkdown(ctx context.Context) context.Context {
+	return context.WithValue(ctx, notesMarkdownKey{}, true)
+}
+
+// notesAsMarkdown reports whether entry notes should be rendered as Markdown.
+func notesAsMarkdown(ctx context.Context) bool {
+	enabled, _ := ctx.Value(notesMarkdownKey{}).(bool)
+	return enabled
+}
+
+// renderMarkdown returns notes converted from Markdown to safe HTML. If conversion fails,
+// the notes are escaped as plain text instead.
+func renderMarkdown(notes string) string {
+	var buf bytes.Buffer
+	if err := notesMarkdown.Convert([]byte(notes), &buf); err != nil {
+		return "<p>" + html.EscapeString(notes) + "</p>"
+	}
+	return buf.String()
+}
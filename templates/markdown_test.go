@@ -0,0 +1,24 @@
+package templates
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	got := renderMarkdown("**Loved it**, see [the review](https://example.com/review).\n\n<script>alert(1)</script>\n\n[click](javascript:alert(1))")
+
+	for _, want := range []string{
+		"<strong>Loved it</strong>",
+		`<a href="https://example.com/review">the review</a>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output is missing %q:\n%s", want, got)
+		}
+	}
+	for _, unwanted := range []string{"<script", "alert(1)</script>", "javascript:"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("output contains %q:\n%s", unwanted, got)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package templates
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPageWindow(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    []int
+		current int
+		total   int
+	}{
+		{name: "single page", current: 1, total: 1, want: []int{1}},
+		{name: "everything fits", current: 3, total: 5, want: []int{1, 2, 3, 4, 5}},
+		{name: "first page", current: 1, total: 10, want: []int{1, 2, 3, 0, 10}},
+		{name: "middle page", current: 5, total: 10, want: []int{1, 2, 3, 4, 5, 6, 7, 0, 10}},
+		{name: "last page", current: 10, total: 10, want: []int{1, 0, 8, 9, 10}},
+		{name: "gaps on both sides", current: 10, total: 20, want: []int{1, 0, 8, 9, 10, 11, 12, 0, 20}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pageWindow(tt.current, tt.total); !slices.Equal(got, tt.want) {
+				t.Errorf("pageWindow(%d, %d) = %v, want %v", tt.current, tt.total, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPagination(t *testing.T) {
+	tests := []struct {
+		name      string
+		wantLinks []string
+		noLinks   []string
+		current   int
+	}{
+		{
+			name:      "first page",
+			current:   1,
+			wantLinks: []string{"/diary?page=2", "/diary?page=3", "/diary?page=10"},
+			noLinks:   []string{"/diary?page=1\"", "/diary?page=4"},
+		},
+		{
+			name:      "middle page",
+			current:   5,
+			wantLinks: []string{"/diary?page=1", "/diary?page=4", "/diary?page=6", "/diary?page=10"},
+			noLinks:   []string{"/diary?page=5\"", "/diary?page=8"},
+		},
+		{
+			name:      "last page",
+			current:   10,
+			wantLinks: []string{"/diary?page=1", "/diary?page=8", "/diary?page=9"},
+			noLinks:   []string{"/diary?page=10\"", "/diary?page=2\""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf strings.Builder
+			if err := Pagination(tt.current, 10, "/diary").Render(context.Background(), &buf); err != nil {
+				t.Fatalf("rendering: %v", err)
+			}
+			got := buf.String()
+			for _, link := range tt.wantLinks {
+				if !strings.Contains(got, `href="`+link+`"`) {
+					t.Errorf("missing link to %s:\n%s", link, got)
+				}
+			}
+			for _, link := range tt.noLinks {
+				if strings.Contains(got, `href="`+link) {
+					t.Errorf("unexpected link to %s:\n%s", strings.TrimSuffix(link, `"`), got)
+				}
+			}
+			if !strings.Contains(got, `aria-current="page"`) {
+				t.Errorf("current page isn't marked:\n%s", got)
+			}
+		})
+	}
+}
+
+func TestPaginationSinglePage(t *testing.T) {
+	var buf strings.Builder
+	if err := Pagination(1, 1, "/diary").Render(context.Background(), &buf); err != nil {
+		t.Fatalf("rendering: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("rendered %q, want nothing", buf.String())
+	}
+}
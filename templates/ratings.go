@@ -0,0 +1,159 @@
+package templates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RatingThreshold gives ratings of at least Min the CSS class Class.
+type RatingThreshold struct {
+	Class string
+	Min   int
+}
+
+// RatingColors maps a rating to the color class of its filled stars. A rating takes the
+// class of the first threshold it reaches, or Fallback if it reaches none.
+type RatingColors struct {
+	Fallback string
+	// Thresholds are ordered from the highest Min down.
+	Thresholds []RatingThreshold
+}
+
+// DefaultRatingColors returns green for 4 and up, yellow for 3, and red below that.
+func DefaultRatingColors() RatingColors {
+	return RatingColors{
+		Thresholds: []RatingThreshold{
+			{Min: 4, Class: "text-green-400"},
+			{Min: 3, Class: "text-yellow-400"},
+		},
+		Fallback: "text-red-400",
+	}
+}
+
+// ParseRatingColors parses a spec such as "4=text-green-400,3=text-yellow-400,text-red-400":
+// min=class pairs from the highest rating down, then the class for everything lower.
+func ParseRatingColors(spec string) (RatingColors, error) {
+	var rc RatingColors
+	parts := strings.Split(spec, ",")
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		minStr, class, ok := strings.Cut(part, "=")
+		if !ok {
+			if i != len(parts)-1 {
+				return RatingColors{}, fmt.Errorf("rating colors %q: only the last class may omit a minimum rating", spec)
+			}
+			rc.Fallback = part
+			continue
+		}
+		minRating, err := strconv.Atoi(strings.TrimSpace(minStr))
+		if err != nil {
+			return RatingColors{}, fmt.Errorf("rating colors %q: invalid minimum rating %q", spec, minStr)
+		}
+		rc.Thresholds = append(rc.Thresholds, RatingThreshold{Min: minRating, Class: strings.TrimSpace(class)})
+	}
+	if err := rc.Validate(); err != nil {
+		return RatingColors{}, fmt.Errorf("rating colors %q: %w", spec, err)
+	}
+	return rc, nil
+}
+
+// Validate checks that thresholds are between 1 and 5, strictly descending, and that
+// every class, including the fallback, is set.
+func (rc RatingColors) Validate() error {
+	if rc.Fallback == "" {
+		return errors.New("a fallback class is required")
+	}
+	for i, t := range rc.Thresholds {
+		if t.Min < 1 || t.Min > 5 {
+			return fmt.Errorf("minimum rating %d must be between 1 and 5", t.Min)
+		}
+		if t.Class == "" {
+			return fmt.Errorf("minimum rating %d has no class", t.Min)
+		}
+		if i > 0 && t.Min >= rc.Thresholds[i-1].Min {
+			return fmt.Errorf("minimum ratings must be in descending order, got %d after %d", t.Min, rc.Thresholds[i-1].Min)
+		}
+	}
+	return nil
+}
+
+// class returns the color class for the rating.
+func (rc RatingColors) class(rating int) string {
+	for _, t := range rc.Thresholds {
+		if rating >= t.Min {
+			return t.Class
+		}
+	}
+	return rc.Fallback
+}
+
+// ratingColorsKey is the context key for the configured rating colors.
+type ratingColorsKey struct{}
+
+// WithRatingColors returns a context that makes templates color stars with rc.
+func WithRatingColors(ctx context.Context, rc RatingColors) context.Context {
+	return context.WithValue(ctx, ratingColorsKey{}, rc)
+}
+
+// getStarClass returns the classes for a filled star, using the rating colors configured
+// in ctx or the defaults.
+func getStarClass(ctx context.Context, rating int) string {
+	rc, ok := ctx.Value(ratingColorsKey{}).(RatingColors)
+	if !ok {
+		rc = DefaultRatingColors()
+	}
+	return "w-4 h-4 " + rc.class(rating)
+}
+
+// hiddenRatingsKey is the context key for the private mode flag.
+type hiddenRatingsKey struct{}
+
+// WithHiddenRatings returns a context that makes templates leave out ratings, for
+// sharing the screen without showing them. The underlying data is unchanged.
+func WithHiddenRatings(ctx context.Context) context.Context {
+	return context.WithValue(ctx, hiddenRatingsKey{}, true)
+}
+
+// ratingsHidden reports whether ratings should be left out of the page.
+func ratingsHidden(ctx context.Context) bool {
+	hidden, _ := ctx.Value(hiddenRatingsKey{}).(bool)
+	return hidden
+}
+
+// RatingSymbol is the glyph used to draw each point of a rating.
+type RatingSymbol string
+
+// Rating symbols offered by the --rating-symbol option.
+const (
+	SymbolStar   RatingSymbol = "star"
+	SymbolCircle RatingSymbol = "circle"
+	SymbolSquare RatingSymbol = "square"
+)
+
+// ParseRatingSymbol returns the rating symbol with the given name.
+func ParseRatingSymbol(name string) (RatingSymbol, error) {
+	switch s := RatingSymbol(name); s {
+	case SymbolStar, SymbolCircle, SymbolSquare:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown rating symbol %q (allowed: %s, %s, %s)", name, SymbolStar, SymbolCircle, SymbolSquare)
+}
+
+// ratingSymbolKey is the context key for the configured rating symbol.
+type ratingSymbolKey struct{}
+
+// WithRatingSymbol returns a context that makes templates draw ratings with s.
+func WithRatingSymbol(ctx context.Context, s RatingSymbol) context.Context {
+	return context.WithValue(ctx, ratingSymbolKey{}, s)
+}
+
+// ratingSymbol returns the rating symbol configured in ctx, or stars by default.
+func ratingSymbol(ctx context.Context) RatingSymbol {
+	if s, ok := ctx.Value(ratingSymbolKey{}).(RatingSymbol); ok {
+		return s
+	}
+	return SymbolStar
+}
@@ -0,0 +1,141 @@
+package templates
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestGetStarClassDefaults(t *testing.T) {
+	tests := []struct {
+		want   string
+		rating int
+	}{
+		{rating: 5, want: "text-green-400"},
+		{rating: 4, want: "text-green-400"},
+		{rating: 3, want: "text-yellow-400"},
+		{rating: 2, want: "text-red-400"},
+		{rating: 1, want: "text-red-400"},
+	}
+	for _, tt := range tests {
+		if got := getStarClass(context.Background(), tt.rating); got != "w-4 h-4 "+tt.want {
+			t.Errorf("getStarClass(%d) = %q, want %q", tt.rating, got, tt.want)
+		}
+	}
+}
+
+func TestGetStarClassCustom(t *testing.T) {
+	rc, err := ParseRatingColors("5=text-purple-500, 2=text-gray-500, text-gray-300")
+	if err != nil {
+		t.Fatalf("ParseRatingColors: %v", err)
+	}
+	ctx := WithRatingColors(context.Background(), rc)
+
+	// Ratings on either side of each custom threshold, where the defaults would differ
+	tests := []struct {
+		want   string
+		rating int
+	}{
+		{rating: 5, want: "text-purple-500"},
+		{rating: 4, want: "text-gray-500"},
+		{rating: 3, want: "text-gray-500"},
+		{rating: 2, want: "text-gray-500"},
+		{rating: 1, want: "text-gray-300"},
+	}
+	for _, tt := range tests {
+		if got := getStarClass(ctx, tt.rating); got != "w-4 h-4 "+tt.want {
+			t.Errorf("getStarClass(%d) = %q, want %q", tt.rating, got, tt.want)
+		}
+	}
+}
+
+func TestParseRatingColorsMonochrome(t *testing.T) {
+	rc, err := ParseRatingColors("text-gray-700")
+	if err != nil {
+		t.Fatalf("ParseRatingColors: %v", err)
+	}
+	for rating := 1; rating <= 5; rating++ {
+		if got := rc.class(rating); got != "text-gray-700" {
+			t.Errorf("class(%d) = %q, want text-gray-700", rating, got)
+		}
+	}
+}
+
+func TestParseRatingColorsInvalid(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr string
+	}{
+		{spec: "3=text-yellow-400,4=text-green-400,text-red-400", wantErr: "descending order"},
+		{spec: "4=text-green-400,4=text-lime-400,text-red-400", wantErr: "descending order"},
+		{spec: "6=text-green-400,text-red-400", wantErr: "between 1 and 5"},
+		{spec: "4=text-green-400", wantErr: "fallback class is required"},
+		{spec: "text-red-400,4=text-green-400", wantErr: "only the last class"},
+		{spec: "four=text-green-400,text-red-400", wantErr: "invalid minimum rating"},
+		{spec: "4=,text-red-400", wantErr: "has no class"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			_, err := ParseRatingColors(tt.spec)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseRatingColors(%q) = %v, want an error containing %q", tt.spec, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseRatingSymbol(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    RatingSymbol
+		wantErr bool
+	}{
+		{name: "star", want: SymbolStar},
+		{name: "circle", want: SymbolCircle},
+		{name: "square", want: SymbolSquare},
+		{name: "heart", wantErr: true},
+		{name: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRatingSymbol(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseRatingSymbol(%q) = %q, %v, want %q (error: %t)", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestStarRatingSymbol(t *testing.T) {
+	tests := []struct {
+		ctx       context.Context
+		name      string
+		wantGlyph string
+	}{
+		{name: "default", ctx: context.Background(), wantGlyph: "<path"},
+		{name: "star", ctx: WithRatingSymbol(context.Background(), SymbolStar), wantGlyph: "<path"},
+		{name: "circle", ctx: WithRatingSymbol(context.Background(), SymbolCircle), wantGlyph: "<circle"},
+		{name: "square", ctx: WithRatingSymbol(context.Background(), SymbolSquare), wantGlyph: "<rect"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf strings.Builder
+			if err := StarRating(3).Render(tt.ctx, &buf); err != nil {
+				t.Fatalf("rendering: %v", err)
+			}
+			html := buf.String()
+
+			// Every point is drawn with the symbol, rated or not
+			if n := strings.Count(html, tt.wantGlyph); n != 5 {
+				t.Errorf("rating has %d %s glyphs, want 5:\n%s", n, tt.wantGlyph, html)
+			}
+			for _, other := range []string{"<path", "<circle", "<rect"} {
+				if other != tt.wantGlyph && strings.Contains(html, other) {
+					t.Errorf("rating also draws %s glyphs", other)
+				}
+			}
+			if n := strings.Count(html, "text-gray-300"); n != 2 {
+				t.Errorf("rating has %d unfilled glyphs, want 2", n)
+			}
+		})
+	}
+}